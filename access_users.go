@@ -123,6 +123,14 @@ type GetAccessUserLastSeenIdentityResult struct {
 	ServiceTokenStatus *bool                              `json:"service_token_status"`
 	UserUUID           string                             `json:"user_uuid"`
 	Version            int                                `json:"version"`
+	Groups             []AccessUserIdentityGroup          `json:"groups"`
+}
+
+// AccessUserIdentityGroup is an identity provider group a user belongs to.
+type AccessUserIdentityGroup struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 type AccessUserDevicePostureCheck struct {
@@ -54,6 +54,12 @@ type API struct {
 	retryPolicy       RetryPolicy
 	logger            Logger
 	Debug             bool
+
+	strictSplitTunnelValidation bool
+	strictDeviceDecoding        bool
+	fallbackDomainLimit         int
+	splitTunnelLimit            int
+	deviceRequestTimeout        time.Duration
 }
 
 // newClient provides shared logic for New and NewWithUserServiceKey.
@@ -206,6 +212,12 @@ func (api *API) makeRequestContextWithHeadersComplete(ctx context.Context, metho
 }
 
 func (api *API) makeRequestWithAuthTypeAndHeadersComplete(ctx context.Context, method, uri string, params interface{}, authType int, headers http.Header) (*APIResponse, error) {
+	if api.deviceRequestTimeout > 0 && isDeviceRequestURI(uri) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, api.deviceRequestTimeout)
+		defer cancel()
+	}
+
 	var err error
 	var resp *http.Response
 	var respErr error
@@ -263,7 +275,7 @@ func (api *API) makeRequestWithAuthTypeAndHeadersComplete(ctx context.Context, m
 		// assumes server operations are rolled back on failure
 		if respErr != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
 			if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
-				respErr = errors.New("exceeded available rate limit retries")
+				respErr = &rateLimitRetriesExceededError{retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
 			}
 
 			if respErr == nil {
@@ -350,6 +362,40 @@ func (api *API) makeRequestWithAuthTypeAndHeadersComplete(ctx context.Context, m
 	}, nil
 }
 
+// rateLimitRetriesExceededError is returned when requests are still rate
+// limited after all retries. It carries the delay the API asked for in its
+// last Retry-After header, if any.
+type rateLimitRetriesExceededError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitRetriesExceededError) Error() string {
+	return "exceeded available rate limit retries"
+}
+
+// parseRetryAfter returns the delay of a Retry-After header value, given
+// either in seconds or as an HTTP date. Zero is returned for missing or
+// invalid values.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
 // request makes a HTTP request to the given API endpoint, returning the raw
 // *http.Response, or an error if one occurred. The caller is responsible for
 // closing the response body.
@@ -418,9 +464,13 @@ func (api *API) request(ctx context.Context, method, uri string, reqBody io.Read
 
 // copyHeader copies all headers for `source` and sets them on `target`.
 // based on https://godoc.org/github.com/golang/gddo/httputil/header#Copy
+//
+// The values are copied as well, so that appending to a header of one request
+// (e.g. in a custom http.RoundTripper) can't write into the client's shared
+// headers while other requests are in flight.
 func copyHeader(target, source http.Header) {
 	for k, vs := range source {
-		target[k] = vs
+		target[k] = append([]string(nil), vs...)
 	}
 }
 
@@ -439,6 +489,33 @@ type Response struct {
 	Messages []ResponseInfo `json:"messages"`
 }
 
+// Warnings returns the text of the informational messages of the response.
+// These are non-fatal notices such as deprecations that the API reports next
+// to a successful result.
+func (r Response) Warnings() []string {
+	if len(r.Messages) == 0 {
+		return nil
+	}
+
+	warnings := make([]string, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		if m.Message != "" {
+			warnings = append(warnings, m.Message)
+		}
+	}
+
+	return warnings
+}
+
+// logResponseWarnings writes the warnings of a response to the configured
+// logger so that they aren't silently dropped by callers that only look at
+// the result.
+func (api *API) logResponseWarnings(method, uri string, r Response) {
+	for _, warning := range r.Warnings() {
+		api.logger.Printf("Warning for request %s %s: %s", method, uri, warning)
+	}
+}
+
 // ResultInfoCursors contains information about cursors.
 type ResultInfoCursors struct {
 	Before string `json:"before" url:"before,omitempty"`
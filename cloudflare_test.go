@@ -547,3 +547,38 @@ func TestCheckResultInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestResponse_Warnings(t *testing.T) {
+	assert.Nil(t, Response{}.Warnings())
+
+	r := Response{
+		Success: true,
+		Messages: []ResponseInfo{
+			{Code: 10000, Message: "field X is deprecated"},
+			{Code: 10001},
+			{Code: 10002, Message: "field Y will be removed"},
+		},
+	}
+	assert.Equal(t, []string{"field X is deprecated", "field Y will be removed"}, r.Warnings())
+}
+
+func TestCopyHeader_DoesNotAliasValues(t *testing.T) {
+	values := make([]string, 1, 4)
+	values[0] = "a"
+	source := http.Header{"X-Test": values}
+
+	target := make(http.Header)
+	copyHeader(target, source)
+	target["X-Test"] = append(target["X-Test"], "b")
+
+	assert.Equal(t, []string{"a"}, source["X-Test"])
+	assert.Equal(t, "", values[:2][1])
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
@@ -2,10 +2,15 @@ package cloudflare
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
-
-	"github.com/goccy/go-json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // DevicePostureIntegrationConfig contains authentication information
@@ -59,7 +64,7 @@ func (api *API) CreateDevicePostureIntegration(ctx context.Context, accountID st
 	}
 
 	var devicePostureIntegrationResponse DevicePostureIntegrationResponse
-	err = json.Unmarshal(res, &devicePostureIntegrationResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureIntegrationResponse)
 	if err != nil {
 		return DevicePostureIntegration{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -79,7 +84,7 @@ func (api *API) UpdateDevicePostureIntegration(ctx context.Context, accountID st
 	}
 
 	var devicePostureIntegrationResponse DevicePostureIntegrationResponse
-	err = json.Unmarshal(res, &devicePostureIntegrationResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureIntegrationResponse)
 	if err != nil {
 		return DevicePostureIntegration{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -99,7 +104,7 @@ func (api *API) DevicePostureIntegration(ctx context.Context, accountID, integra
 	}
 
 	var devicePostureIntegrationResponse DevicePostureIntegrationResponse
-	err = json.Unmarshal(res, &devicePostureIntegrationResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureIntegrationResponse)
 	if err != nil {
 		return DevicePostureIntegration{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -119,7 +124,7 @@ func (api *API) DevicePostureIntegrations(ctx context.Context, accountID string)
 	}
 
 	var devicePostureIntegrationListResponse DevicePostureIntegrationListResponse
-	err = json.Unmarshal(res, &devicePostureIntegrationListResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureIntegrationListResponse)
 	if err != nil {
 		return []DevicePostureIntegration{}, ResultInfo{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -146,6 +151,122 @@ func (api *API) DeleteDevicePostureIntegration(ctx context.Context, accountID, r
 	return nil
 }
 
+// Device posture rule types with dedicated input constructors.
+const (
+	DevicePostureRuleTypeClientCertificate   = "client_certificate"
+	DevicePostureRuleTypeClientCertificateV2 = "client_certificate_v2"
+	DevicePostureRuleTypeTanium              = "tanium_s2s"
+	DevicePostureRuleTypeKolide              = "kolide_s2s"
+	DevicePostureRuleTypeFirewall            = "firewall"
+	DevicePostureRuleTypeApplication         = "application"
+	DevicePostureRuleTypeSerialNumber        = "serial_number"
+	DevicePostureRuleTypeOSVersion           = "os_version"
+)
+
+// PostureOS is an operating system targeted by a device posture rule, as the
+// platform of its Match or the operating system of an OS version rule.
+type PostureOS string
+
+const (
+	PostureOSWindows  PostureOS = "windows"
+	PostureOSMac      PostureOS = "mac"
+	PostureOSLinux    PostureOS = "linux"
+	PostureOSIOS      PostureOS = "ios"
+	PostureOSAndroid  PostureOS = "android"
+	PostureOSChromeOS PostureOS = "chromeos"
+)
+
+// postureOperatingSystems are the PostureOS values accepted by the API.
+var postureOperatingSystems = []PostureOS{
+	PostureOSWindows,
+	PostureOSMac,
+	PostureOSLinux,
+	PostureOSIOS,
+	PostureOSAndroid,
+	PostureOSChromeOS,
+}
+
+// Valid reports whether o is an operating system known to the API. Values
+// read from the API are kept as is, so rules for operating systems added
+// later still decode.
+func (o PostureOS) Valid() bool {
+	for _, os := range postureOperatingSystems {
+		if o == os {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	// ErrDevicePostureRuleInUse is returned when deleting a device posture
+	// rule that is still referenced.
+	ErrDevicePostureRuleInUse = errors.New("device posture rule is in use")
+	// ErrDevicePostureIntegrationInUse is returned when deleting a device
+	// posture integration that device posture rules still depend on.
+	ErrDevicePostureIntegrationInUse = errors.New("device posture integration is in use")
+
+	ErrMissingDevicePostureCertificateID = errors.New("device posture client certificate rules require a certificate ID")
+	ErrInvalidDevicePostureCertificateID = errors.New("device posture client certificate ID must be a UUID")
+	ErrMissingDevicePostureConnectionID  = errors.New("device posture integration rules require a connection ID")
+	ErrMissingDevicePostureListID        = errors.New("device posture serial number rules require a list ID")
+	ErrMissingDevicePostureSerialNumbers = errors.New("device posture serial number rules require at least one serial number")
+)
+
+// devicePostureComparisonOperators are the operators accepted for score and
+// count comparisons in device posture rule inputs.
+var devicePostureComparisonOperators = map[string]bool{
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+	"==": true,
+}
+
+// devicePostureHashLengths are the lengths of the hex encoded hashes accepted
+// by application device posture rules.
+var devicePostureHashLengths = map[string]int{
+	"sha256":     64,
+	"thumbprint": 40,
+}
+
+// devicePostureCertificateIDPattern matches the UUIDs identifying Access mTLS
+// certificates.
+var devicePostureCertificateIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// devicePostureCertificateOperatingSystems, devicePostureExtendedKeyUsages and
+// devicePostureTrustStores are the values accepted by client_certificate_v2
+// device posture rules.
+var (
+	devicePostureCertificateOperatingSystems = map[string]bool{"windows": true, "linux": true, "mac": true}
+	devicePostureExtendedKeyUsages           = map[string]bool{"clientAuth": true, "emailProtection": true}
+	devicePostureTrustStores                 = map[string]bool{"system": true, "user": true}
+)
+
+// devicePostureIntegrationRuleTypes are the rule types evaluated by a third
+// party service through a device posture integration. They are polled at the
+// interval of the integration and don't support a schedule.
+var devicePostureIntegrationRuleTypes = map[string]bool{
+	"crowdstrike_s2s":           true,
+	"custom_s2s":                true,
+	"intune":                    true,
+	"sentinelone_s2s":           true,
+	"uptycs":                    true,
+	"workspace_one":             true,
+	DevicePostureRuleTypeKolide: true,
+	DevicePostureRuleTypeTanium: true,
+}
+
+// devicePostureTaniumRiskLevels are the Tanium risk levels accepted by the
+// API.
+var devicePostureTaniumRiskLevels = map[string]bool{
+	"low":      true,
+	"medium":   true,
+	"high":     true,
+	"critical": true,
+}
+
 // DevicePostureRule represents a device posture rule.
 type DevicePostureRule struct {
 	ID          string                   `json:"id,omitempty"`
@@ -160,7 +281,9 @@ type DevicePostureRule struct {
 
 // DevicePostureRuleMatch represents the conditions that the client must match to run the rule.
 type DevicePostureRuleMatch struct {
-	Platform string `json:"platform,omitempty"`
+	// Platform is kept as is when read, even if it isn't Valid, but must be
+	// Valid on create and update.
+	Platform PostureOS `json:"platform,omitempty"`
 }
 
 // DevicePostureRuleInput represents the value to be checked against.
@@ -200,6 +323,488 @@ type DevicePostureRuleInput struct {
 	RiskLevel        string   `json:"risk_level,omitempty"`
 	State            string   `json:"state,omitempty"`
 	LastSeen         string   `json:"last_seen,omitempty"`
+	CheckPrivateKey  bool     `json:"check_private_key,omitempty"`
+	ExtendedKeyUsage []string `json:"extended_key_usage,omitempty"`
+	OperatingSystem  string   `json:"operating_system,omitempty"`
+
+	Locations *DevicePostureRuleInputLocations `json:"locations,omitempty"`
+}
+
+// DevicePostureRuleInputLocations are the places a client_certificate_v2
+// device posture rule searches for the client certificate.
+type DevicePostureRuleInputLocations struct {
+	Paths       []string `json:"paths,omitempty"`
+	TrustStores []string `json:"trust_stores,omitempty"`
+}
+
+// NewClientCertificatePostureInput returns the input for a
+// `client_certificate` device posture rule checking for the presence of a
+// client certificate issued by the given certificate.
+func NewClientCertificatePostureInput(certificateID string) (DevicePostureRuleInput, error) {
+	if err := validateDevicePostureCertificateID(certificateID); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return DevicePostureRuleInput{CertificateID: certificateID}, nil
+}
+
+// ClientCertificateV2PostureInputParams holds the inputs of a
+// `client_certificate_v2` device posture rule, which checks that the device
+// holds a client certificate signed by an Access mTLS certificate.
+type ClientCertificateV2PostureInputParams struct {
+	// CertificateID is the UUID of the Access mTLS certificate the client
+	// certificate must be signed by.
+	CertificateID string
+	// CommonName the client certificate must have. The placeholders
+	// ${serial_number} and ${hostname} are replaced by the device values.
+	CommonName string
+	// CheckPrivateKey requires the private key of the certificate to be
+	// present on the device.
+	CheckPrivateKey bool
+	// ExtendedKeyUsage the certificate must have, each "clientAuth" or
+	// "emailProtection".
+	ExtendedKeyUsage []string
+	// OperatingSystem is one of "windows", "linux" or "mac".
+	OperatingSystem string
+	// Paths are the file paths searched for the certificate.
+	Paths []string
+	// TrustStores are the certificate stores searched, each "system" or
+	// "user".
+	TrustStores []string
+}
+
+// NewClientCertificateV2PostureInput returns the input for a
+// `client_certificate_v2` device posture rule.
+func NewClientCertificateV2PostureInput(params ClientCertificateV2PostureInputParams) (DevicePostureRuleInput, error) {
+	input := DevicePostureRuleInput{
+		CertificateID:    params.CertificateID,
+		CommonName:       params.CommonName,
+		CheckPrivateKey:  params.CheckPrivateKey,
+		ExtendedKeyUsage: params.ExtendedKeyUsage,
+		OperatingSystem:  params.OperatingSystem,
+	}
+	if len(params.Paths) > 0 || len(params.TrustStores) > 0 {
+		input.Locations = &DevicePostureRuleInputLocations{
+			Paths:       params.Paths,
+			TrustStores: params.TrustStores,
+		}
+	}
+
+	if err := validateClientCertificateV2PostureInput(input); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return input, nil
+}
+
+func validateDevicePostureCertificateID(certificateID string) error {
+	if certificateID == "" {
+		return ErrMissingDevicePostureCertificateID
+	}
+
+	if !devicePostureCertificateIDPattern.MatchString(certificateID) {
+		return fmt.Errorf("%w: %q", ErrInvalidDevicePostureCertificateID, certificateID)
+	}
+
+	return nil
+}
+
+func validateClientCertificateV2PostureInput(input DevicePostureRuleInput) error {
+	if err := validateDevicePostureCertificateID(input.CertificateID); err != nil {
+		return err
+	}
+
+	if !devicePostureCertificateOperatingSystems[input.OperatingSystem] {
+		return fmt.Errorf("invalid device posture client certificate operating system %q: must be one of windows, linux or mac", input.OperatingSystem)
+	}
+
+	for _, usage := range input.ExtendedKeyUsage {
+		if !devicePostureExtendedKeyUsages[usage] {
+			return fmt.Errorf("invalid device posture client certificate extended key usage %q: must be clientAuth or emailProtection", usage)
+		}
+	}
+
+	if input.Locations != nil {
+		for _, store := range input.Locations.TrustStores {
+			if !devicePostureTrustStores[store] {
+				return fmt.Errorf("invalid device posture client certificate trust store %q: must be system or user", store)
+			}
+		}
+	}
+
+	return checkDevicePostureInputFields(DevicePostureRuleTypeClientCertificateV2, input,
+		"certificate_id", "cn", "check_private_key", "extended_key_usage", "operating_system", "locations")
+}
+
+// TaniumPostureInputParams holds the inputs of a `tanium_s2s` device posture
+// rule. Only ConnectionID is required.
+type TaniumPostureInputParams struct {
+	// ConnectionID is the ID of the Tanium device posture integration.
+	ConnectionID string
+	// EidLastSeen is the maximum duration since the endpoint was last seen
+	// by Tanium, e.g. "1d".
+	EidLastSeen string
+	// RiskLevel is one of "low", "medium", "high" or "critical".
+	RiskLevel string
+	// ScoreOperator compares the device TotalScore and is one of "<", "<=",
+	// ">", ">=" or "==".
+	ScoreOperator string
+	TotalScore    int
+}
+
+// NewTaniumPostureInput returns the input for a `tanium_s2s` device posture
+// rule.
+func NewTaniumPostureInput(params TaniumPostureInputParams) (DevicePostureRuleInput, error) {
+	input := DevicePostureRuleInput{
+		ConnectionID:  params.ConnectionID,
+		EidLastSeen:   params.EidLastSeen,
+		RiskLevel:     params.RiskLevel,
+		ScoreOperator: params.ScoreOperator,
+		TotalScore:    params.TotalScore,
+	}
+
+	if err := validateTaniumPostureInput(input); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return input, nil
+}
+
+// NewKolidePostureInput returns the input for a `kolide_s2s` device posture
+// rule passing when the number of Kolide issues for the device compares to
+// issueCount using countOperator ("<", "<=", ">", ">=" or "==").
+func NewKolidePostureInput(connectionID, countOperator string, issueCount int) (DevicePostureRuleInput, error) {
+	if issueCount < 0 {
+		return DevicePostureRuleInput{}, fmt.Errorf("device posture issue count must not be negative: %d", issueCount)
+	}
+
+	input := DevicePostureRuleInput{
+		ConnectionID:  connectionID,
+		CountOperator: countOperator,
+		IssueCount:    strconv.Itoa(issueCount),
+	}
+
+	if err := validateKolidePostureInput(input); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return input, nil
+}
+
+// NewFirewallPostureInput returns the input for a `firewall` device posture
+// rule passing when the operating system firewall is enabled.
+func NewFirewallPostureInput() DevicePostureRuleInput {
+	return DevicePostureRuleInput{Enabled: true}
+}
+
+// ApplicationPostureInputParams holds the inputs of an `application` device
+// posture rule. Only Path is required.
+type ApplicationPostureInputParams struct {
+	// Path is the file path of the application binary.
+	Path string
+	// Sha256 is the hex encoded SHA-256 hash of the binary.
+	Sha256 string
+	// Thumbprint is the hex encoded SHA-1 thumbprint of the certificate the
+	// binary is signed with.
+	Thumbprint string
+	// Running requires the application to be running.
+	Running bool
+}
+
+// NewApplicationPostureInput returns the input for an `application` device
+// posture rule, e.g. checking that an antivirus agent is installed and
+// running.
+func NewApplicationPostureInput(params ApplicationPostureInputParams) (DevicePostureRuleInput, error) {
+	input := DevicePostureRuleInput{
+		Path:       params.Path,
+		Sha256:     strings.ToLower(params.Sha256),
+		Thumbprint: strings.ToLower(params.Thumbprint),
+		Running:    params.Running,
+	}
+
+	if err := validateApplicationPostureInput(input); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return input, nil
+}
+
+// OSVersionPostureInputParams holds the inputs of an `os_version` device
+// posture rule passing when the operating system version compares to Version
+// using Operator.
+type OSVersionPostureInputParams struct {
+	// OperatingSystem the rule applies to.
+	OperatingSystem PostureOS
+	// Version is the semantic version compared to, e.g. "13.4.0".
+	Version string
+	// Operator is one of "<", "<=", ">", ">=" or "==".
+	Operator string
+	// OSDistroName and OSDistroRevision restrict Linux rules to a
+	// distribution.
+	OSDistroName     string
+	OSDistroRevision string
+	// OSVersionExtra is the macOS Rapid Security Response version, e.g.
+	// "(a)".
+	OSVersionExtra string
+}
+
+// NewOSVersionPostureInput returns the input for an `os_version` device
+// posture rule. Unknown operating systems, such as "macos" instead of
+// PostureOSMac, are rejected.
+func NewOSVersionPostureInput(params OSVersionPostureInputParams) (DevicePostureRuleInput, error) {
+	if params.OperatingSystem == "" {
+		return DevicePostureRuleInput{}, errors.New("device posture OS version rules require an operating system")
+	}
+
+	input := DevicePostureRuleInput{
+		OperatingSystem:  string(params.OperatingSystem),
+		Version:          params.Version,
+		Operator:         params.Operator,
+		OsDistroName:     params.OSDistroName,
+		OsDistroRevision: params.OSDistroRevision,
+		OSVersionExtra:   params.OSVersionExtra,
+	}
+
+	if err := validateOSVersionPostureInput(input); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return input, nil
+}
+
+func validateOSVersionPostureInput(input DevicePostureRuleInput) error {
+	// Rules may leave the operating system to their Match platform instead.
+	if input.OperatingSystem != "" && !PostureOS(input.OperatingSystem).Valid() {
+		return fmt.Errorf("invalid device posture operating system %q: must be one of %s", input.OperatingSystem, postureOperatingSystemNames())
+	}
+
+	if input.Version == "" {
+		return errors.New("device posture OS version rules require a version")
+	}
+
+	if !devicePostureComparisonOperators[input.Operator] {
+		return fmt.Errorf("invalid device posture version operator: %q", input.Operator)
+	}
+
+	return checkDevicePostureInputFields(DevicePostureRuleTypeOSVersion, input,
+		"operating_system", "version", "operator", "os_distro_name", "os_distro_revision", "os_version_extra")
+}
+
+// NewSerialNumberPostureInput returns the input for a `serial_number` device
+// posture rule passing for devices whose serial number is in the Gateway list
+// listID. The list must be of type "SERIAL".
+func NewSerialNumberPostureInput(listID string) (DevicePostureRuleInput, error) {
+	input := DevicePostureRuleInput{ID: listID}
+
+	if err := validateSerialNumberPostureInput(input); err != nil {
+		return DevicePostureRuleInput{}, err
+	}
+
+	return input, nil
+}
+
+func validateSerialNumberPostureInput(input DevicePostureRuleInput) error {
+	if input.ID == "" {
+		return ErrMissingDevicePostureListID
+	}
+
+	return checkDevicePostureInputFields(DevicePostureRuleTypeSerialNumber, input, "id")
+}
+
+func validateFirewallPostureInput(input DevicePostureRuleInput) error {
+	if !input.Enabled {
+		return errors.New("device posture firewall rules require enabled to be set")
+	}
+
+	return checkDevicePostureInputFields(DevicePostureRuleTypeFirewall, input, "enabled")
+}
+
+func validateApplicationPostureInput(input DevicePostureRuleInput) error {
+	if input.Path == "" {
+		return errors.New("device posture application rules require a path")
+	}
+
+	for name, value := range map[string]string{"sha256": input.Sha256, "thumbprint": input.Thumbprint} {
+		if value == "" {
+			continue
+		}
+		if _, err := hex.DecodeString(value); err != nil || len(value) != devicePostureHashLengths[name] {
+			return fmt.Errorf("invalid device posture %s: %q", name, value)
+		}
+	}
+
+	return checkDevicePostureInputFields(DevicePostureRuleTypeApplication, input, "path", "sha256", "thumbprint", "running")
+}
+
+// checkDevicePostureInputFields returns an error naming the fields of input
+// that are set but not part of allowed, identified by their JSON names.
+func checkDevicePostureInputFields(ruleType string, input DevicePostureRuleInput, allowed ...string) error {
+	permitted := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		permitted[name] = true
+	}
+
+	var unexpected []string
+	v := reflect.ValueOf(input)
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if !permitted[name] && !v.Field(i).IsZero() {
+			unexpected = append(unexpected, name)
+		}
+	}
+
+	if len(unexpected) > 0 {
+		return fmt.Errorf("device posture input field(s) not supported by %s rules: %s", ruleType, strings.Join(unexpected, ", "))
+	}
+
+	return nil
+}
+
+func validateTaniumPostureInput(input DevicePostureRuleInput) error {
+	if input.ConnectionID == "" {
+		return ErrMissingDevicePostureConnectionID
+	}
+
+	if input.RiskLevel != "" && !devicePostureTaniumRiskLevels[input.RiskLevel] {
+		return fmt.Errorf("invalid device posture risk level: %q", input.RiskLevel)
+	}
+
+	if input.ScoreOperator != "" && !devicePostureComparisonOperators[input.ScoreOperator] {
+		return fmt.Errorf("invalid device posture score operator: %q", input.ScoreOperator)
+	}
+
+	return nil
+}
+
+func validateKolidePostureInput(input DevicePostureRuleInput) error {
+	if input.ConnectionID == "" {
+		return ErrMissingDevicePostureConnectionID
+	}
+
+	if !devicePostureComparisonOperators[input.CountOperator] {
+		return fmt.Errorf("invalid device posture count operator: %q", input.CountOperator)
+	}
+
+	if _, err := strconv.Atoi(input.IssueCount); err != nil {
+		return fmt.Errorf("invalid device posture issue count: %q", input.IssueCount)
+	}
+
+	return nil
+}
+
+// postureOperatingSystemNames lists the valid PostureOS values for error
+// messages.
+func postureOperatingSystemNames() string {
+	names := make([]string, 0, len(postureOperatingSystems))
+	for _, os := range postureOperatingSystems {
+		names = append(names, string(os))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// validateDevicePostureRule checks the match platforms of a rule, and the
+// input of rule types that have a dedicated constructor, before the rule is
+// sent to the API.
+func validateDevicePostureRule(rule DevicePostureRule) error {
+	for _, match := range rule.Match {
+		if !match.Platform.Valid() {
+			return fmt.Errorf("invalid device posture rule match platform %q: must be one of %s", match.Platform, postureOperatingSystemNames())
+		}
+	}
+
+	if rule.Schedule != "" && devicePostureIntegrationRuleTypes[rule.Type] {
+		return fmt.Errorf("device posture rules of type %s are evaluated at the interval of their integration and must not set a schedule", rule.Type)
+	}
+
+	switch rule.Type {
+	case DevicePostureRuleTypeClientCertificate:
+		return validateDevicePostureCertificateID(rule.Input.CertificateID)
+	case DevicePostureRuleTypeClientCertificateV2:
+		return validateClientCertificateV2PostureInput(rule.Input)
+	case DevicePostureRuleTypeTanium:
+		return validateTaniumPostureInput(rule.Input)
+	case DevicePostureRuleTypeKolide:
+		return validateKolidePostureInput(rule.Input)
+	case DevicePostureRuleTypeFirewall:
+		return validateFirewallPostureInput(rule.Input)
+	case DevicePostureRuleTypeApplication:
+		return validateApplicationPostureInput(rule.Input)
+	case DevicePostureRuleTypeSerialNumber:
+		return validateSerialNumberPostureInput(rule.Input)
+	case DevicePostureRuleTypeOSVersion:
+		return validateOSVersionPostureInput(rule.Input)
+	}
+
+	return nil
+}
+
+// PostureCheckCadence describes how often a device posture rule is
+// evaluated.
+//
+// Rules checked by the WARP client (e.g. file, application, firewall,
+// os_version) run on their Schedule, such as "5m" or "1h", or on the client
+// default when it is empty. Rules of integration types (e.g. tanium_s2s,
+// kolide_s2s, crowdstrike_s2s, intune) are polled by Cloudflare at the
+// Interval of the device posture integration they reference and can't have a
+// schedule.
+func PostureCheckCadence(rule DevicePostureRule) string {
+	if devicePostureIntegrationRuleTypes[rule.Type] {
+		if rule.Input.ConnectionID != "" {
+			return fmt.Sprintf("integration interval (integration %s)", rule.Input.ConnectionID)
+		}
+		return "integration interval"
+	}
+
+	if rule.Schedule != "" {
+		return "every " + rule.Schedule
+	}
+
+	return "client default"
+}
+
+// DevicePostureResult is the outcome of a device posture rule for a single
+// device, as last reported by the WARP client or an integration.
+type DevicePostureResult struct {
+	RuleID    string    `json:"rule_id"`
+	DeviceID  string    `json:"device_id"`
+	Success   bool      `json:"success"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IsPostureResultStale reports whether result is older than maxAge and
+// should no longer be trusted. A result without a timestamp is always stale;
+// a maxAge of zero or less disables the check.
+func IsPostureResultStale(result DevicePostureResult, maxAge time.Duration) bool {
+	return isPostureResultStaleAt(result, maxAge, time.Now())
+}
+
+func isPostureResultStaleAt(result DevicePostureResult, maxAge time.Duration, now time.Time) bool {
+	if result.Timestamp.IsZero() {
+		return true
+	}
+
+	if maxAge <= 0 {
+		return false
+	}
+
+	return now.Sub(result.Timestamp) > maxAge
+}
+
+// DevicePostureRuleExpiration returns the Expiration of rule as a duration,
+// for use as the maxAge of IsPostureResultStale. Zero is returned when the
+// rule has no expiration and its results stay valid until overwritten.
+func DevicePostureRuleExpiration(rule DevicePostureRule) (time.Duration, error) {
+	if rule.Expiration == "" {
+		return 0, nil
+	}
+
+	expiration, err := time.ParseDuration(rule.Expiration)
+	if err != nil {
+		return 0, fmt.Errorf("invalid device posture rule expiration %q: %w", rule.Expiration, err)
+	}
+
+	return expiration, nil
 }
 
 // DevicePostureRuleListResponse represents the response from the list
@@ -229,7 +834,7 @@ func (api *API) DevicePostureRules(ctx context.Context, accountID string) ([]Dev
 	}
 
 	var devicePostureRuleListResponse DevicePostureRuleListResponse
-	err = json.Unmarshal(res, &devicePostureRuleListResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureRuleListResponse)
 	if err != nil {
 		return []DevicePostureRule{}, ResultInfo{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -237,6 +842,65 @@ func (api *API) DevicePostureRules(ctx context.Context, accountID string) ([]Dev
 	return devicePostureRuleListResponse.Result, devicePostureRuleListResponse.ResultInfo, nil
 }
 
+type ListDevicePostureRulesParams struct {
+	ResultInfo
+
+	// Type only returns the rules of the given type, e.g. "disk_encryption".
+	// The API can't filter by type, so all rules are fetched and filtered
+	// client side.
+	Type string `url:"-"`
+}
+
+// ListDevicePostureRules returns the device posture rules of an account,
+// fetching every page unless a page or page size is given. The returned
+// ResultInfo describes the rules returned after filtering by Type, as a
+// single page.
+//
+// API reference: https://api.cloudflare.com/#device-posture-rules-list-device-posture-rules
+func (api *API) ListDevicePostureRules(ctx context.Context, rc *ResourceContainer, params ListDevicePostureRulesParams) ([]DevicePostureRule, *ResultInfo, error) {
+	if rc.Level != AccountRouteLevel {
+		return []DevicePostureRule{}, &ResultInfo{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	autoPaginate := true
+	if params.PerPage >= 1 || params.Page >= 1 {
+		autoPaginate = false
+	}
+
+	var rules []DevicePostureRule
+	for {
+		uri := buildURI(fmt.Sprintf("/%s/%s/devices/posture", rc.Level, rc.Identifier), params)
+		res, err := api.makeRequestContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return []DevicePostureRule{}, &ResultInfo{}, err
+		}
+
+		var r DevicePostureRuleListResponse
+		if err := api.unmarshalDeviceResponse(res, &r); err != nil {
+			return []DevicePostureRule{}, &ResultInfo{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
+		}
+
+		for _, rule := range r.Result {
+			if params.Type == "" || rule.Type == params.Type {
+				rules = append(rules, rule)
+			}
+		}
+
+		params.ResultInfo = r.ResultInfo.Next()
+		if params.ResultInfo.Done() || !autoPaginate {
+			break
+		}
+	}
+
+	return rules, &ResultInfo{
+		Page:       1,
+		PerPage:    len(rules),
+		TotalPages: 1,
+		Count:      len(rules),
+		Total:      len(rules),
+	}, nil
+}
+
 // DevicePostureRule returns a single device posture rule based on the rule ID.
 //
 // API reference: https://api.cloudflare.com/#device-posture-rules-device-posture-rules-details
@@ -254,7 +918,7 @@ func (api *API) DevicePostureRule(ctx context.Context, accountID, ruleID string)
 	}
 
 	var devicePostureRuleDetailResponse DevicePostureRuleDetailResponse
-	err = json.Unmarshal(res, &devicePostureRuleDetailResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureRuleDetailResponse)
 	if err != nil {
 		return DevicePostureRule{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -266,6 +930,10 @@ func (api *API) DevicePostureRule(ctx context.Context, accountID, ruleID string)
 //
 // API reference: https://api.cloudflare.com/#device-posture-rules-create-device-posture-rule
 func (api *API) CreateDevicePostureRule(ctx context.Context, accountID string, rule DevicePostureRule) (DevicePostureRule, error) {
+	if err := validateDevicePostureRule(rule); err != nil {
+		return DevicePostureRule{}, err
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/posture", AccountRouteRoot, accountID)
 
 	res, err := api.makeRequestContext(ctx, http.MethodPost, uri, rule)
@@ -274,11 +942,13 @@ func (api *API) CreateDevicePostureRule(ctx context.Context, accountID string, r
 	}
 
 	var devicePostureRuleDetailResponse DevicePostureRuleDetailResponse
-	err = json.Unmarshal(res, &devicePostureRuleDetailResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureRuleDetailResponse)
 	if err != nil {
 		return DevicePostureRule{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
+	api.logResponseWarnings(http.MethodPost, uri, devicePostureRuleDetailResponse.Response)
+
 	return devicePostureRuleDetailResponse.Result, nil
 }
 
@@ -290,6 +960,10 @@ func (api *API) UpdateDevicePostureRule(ctx context.Context, accountID string, r
 		return DevicePostureRule{}, fmt.Errorf("device posture rule ID cannot be empty")
 	}
 
+	if err := validateDevicePostureRule(rule); err != nil {
+		return DevicePostureRule{}, err
+	}
+
 	uri := fmt.Sprintf(
 		"/%s/%s/devices/posture/%s",
 		AccountRouteRoot,
@@ -303,11 +977,13 @@ func (api *API) UpdateDevicePostureRule(ctx context.Context, accountID string, r
 	}
 
 	var devicePostureRuleDetailResponse DevicePostureRuleDetailResponse
-	err = json.Unmarshal(res, &devicePostureRuleDetailResponse)
+	err = api.unmarshalDeviceResponse(res, &devicePostureRuleDetailResponse)
 	if err != nil {
 		return DevicePostureRule{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
+	api.logResponseWarnings(http.MethodPut, uri, devicePostureRuleDetailResponse.Response)
+
 	return devicePostureRuleDetailResponse.Result, nil
 }
 
@@ -329,3 +1005,286 @@ func (api *API) DeleteDevicePostureRule(ctx context.Context, accountID, ruleID s
 
 	return nil
 }
+
+// CheckDevicePostureRuleIntegration verifies that the device posture
+// integration referenced by the rule's connection ID exists. It can be used
+// before creating or updating a rule backed by a third party integration.
+//
+// API reference: https://api.cloudflare.com/#device-posture-integrations-device-posture-integration-details
+func (api *API) CheckDevicePostureRuleIntegration(ctx context.Context, accountID string, rule DevicePostureRule) error {
+	if rule.Input.ConnectionID == "" {
+		return ErrMissingDevicePostureConnectionID
+	}
+
+	_, err := api.DevicePostureIntegration(ctx, accountID, rule.Input.ConnectionID)
+	if err != nil {
+		var notFoundError *NotFoundError
+		if errors.As(err, &notFoundError) {
+			return fmt.Errorf("device posture integration %q does not exist: %w", rule.Input.ConnectionID, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DevicePostureRuleInUseError is returned by DeleteDevicePostureRuleSafe when
+// device settings policies still reference the rule. It matches
+// ErrDevicePostureRuleInUse with errors.Is.
+type DevicePostureRuleInUseError struct {
+	RuleID   string
+	Policies []DeviceSettingsPolicy
+}
+
+func (e *DevicePostureRuleInUseError) Error() string {
+	names := make([]string, 0, len(e.Policies))
+	for _, policy := range e.Policies {
+		names = append(names, deviceSettingsPolicyLabel(policy))
+	}
+
+	return fmt.Sprintf("%s: rule %s is referenced by device settings policies %s", ErrDevicePostureRuleInUse, e.RuleID, strings.Join(names, ", "))
+}
+
+func (e *DevicePostureRuleInUseError) Is(target error) bool {
+	return target == ErrDevicePostureRuleInUse
+}
+
+// DeleteDevicePostureRuleSafe deletes a device posture rule unless a device
+// settings policy references it, in which case a
+// *DevicePostureRuleInUseError listing the policies is returned. Setting
+// force skips the check.
+//
+// API reference: https://api.cloudflare.com/#device-posture-rules-delete-device-posture-rule
+func (api *API) DeleteDevicePostureRuleSafe(ctx context.Context, accountID, ruleID string, force bool) error {
+	if !force {
+		policies, err := api.DeviceSettingsPoliciesUsingPostureRule(ctx, AccountIdentifier(accountID), DeviceSettingsPoliciesUsingPostureRuleParams{RuleID: ruleID})
+		if err != nil {
+			return err
+		}
+
+		if len(policies) > 0 {
+			return &DevicePostureRuleInUseError{RuleID: ruleID, Policies: policies}
+		}
+	}
+
+	return api.DeleteDevicePostureRule(ctx, accountID, ruleID)
+}
+
+// DevicePostureRulesUsingIntegration returns the device posture rules
+// evaluated through the device posture integration, i.e. those whose
+// connection ID is integrationID. An empty list is returned when no rule uses
+// the integration.
+//
+// API reference: https://api.cloudflare.com/#device-posture-rules-list-device-posture-rules
+func (api *API) DevicePostureRulesUsingIntegration(ctx context.Context, accountID, integrationID string) ([]DevicePostureRule, error) {
+	rules, _, err := api.DevicePostureRules(ctx, accountID)
+	if err != nil {
+		return []DevicePostureRule{}, err
+	}
+
+	using := []DevicePostureRule{}
+	for _, rule := range rules {
+		if rule.Input.ConnectionID != "" && rule.Input.ConnectionID == integrationID {
+			using = append(using, rule)
+		}
+	}
+
+	return using, nil
+}
+
+// DevicePostureIntegrationInUseError is returned by
+// DeleteDevicePostureIntegrationSafe when device posture rules still depend
+// on the integration. It matches ErrDevicePostureIntegrationInUse with
+// errors.Is.
+type DevicePostureIntegrationInUseError struct {
+	IntegrationID string
+	Rules         []DevicePostureRule
+}
+
+func (e *DevicePostureIntegrationInUseError) Error() string {
+	names := make([]string, 0, len(e.Rules))
+	for _, rule := range e.Rules {
+		names = append(names, fmt.Sprintf("%q (%s)", rule.Name, rule.ID))
+	}
+
+	return fmt.Sprintf("%s: integration %s is used by device posture rules %s", ErrDevicePostureIntegrationInUse, e.IntegrationID, strings.Join(names, ", "))
+}
+
+func (e *DevicePostureIntegrationInUseError) Is(target error) bool {
+	return target == ErrDevicePostureIntegrationInUse
+}
+
+// DeleteDevicePostureIntegrationSafe deletes a device posture integration
+// unless a device posture rule uses it, in which case a
+// *DevicePostureIntegrationInUseError listing the rules is returned. Setting
+// force skips the check.
+//
+// API reference: https://api.cloudflare.com/#device-posture-integrations-delete-device-posture-integration
+func (api *API) DeleteDevicePostureIntegrationSafe(ctx context.Context, accountID, integrationID string, force bool) error {
+	if !force {
+		rules, err := api.DevicePostureRulesUsingIntegration(ctx, accountID, integrationID)
+		if err != nil {
+			return err
+		}
+
+		if len(rules) > 0 {
+			return &DevicePostureIntegrationInUseError{IntegrationID: integrationID, Rules: rules}
+		}
+	}
+
+	return api.DeleteDevicePostureIntegration(ctx, accountID, integrationID)
+}
+
+// AccessApplicationsUsingPostureRule returns the Access applications with a
+// policy that requires the device posture rule, either directly or through an
+// Access group, including nested groups. An empty list is returned when no
+// application uses the rule.
+//
+// Every application's policies are listed, so the call makes one request per
+// application and requires the "Access: Apps and Policies Read" permission in
+// addition to the device permissions.
+//
+// API reference: https://api.cloudflare.com/#access-policies-list-access-policies
+func (api *API) AccessApplicationsUsingPostureRule(ctx context.Context, accountID, ruleID string) ([]AccessApplication, error) {
+	rc := AccountIdentifier(accountID)
+
+	apps, _, err := api.ListAccessApplications(ctx, rc, ListAccessApplicationsParams{})
+	if err != nil {
+		return []AccessApplication{}, err
+	}
+
+	// groups caches whether an Access group uses the rule. Groups being
+	// resolved are recorded as false so that cyclic references end.
+	groups := make(map[string]bool)
+
+	using := []AccessApplication{}
+	for _, app := range apps {
+		policies, _, err := api.ListAccessPolicies(ctx, rc, ListAccessPoliciesParams{ApplicationID: app.ID})
+		if err != nil {
+			return []AccessApplication{}, err
+		}
+
+		for _, policy := range policies {
+			uses, err := api.accessRulesUsePostureRule(ctx, rc, ruleID, groups, policy.Include, policy.Exclude, policy.Require)
+			if err != nil {
+				return []AccessApplication{}, err
+			}
+			if uses {
+				using = append(using, app)
+				break
+			}
+		}
+	}
+
+	return using, nil
+}
+
+// accessRulesUsePostureRule reports whether any of the Access rules requires
+// the device posture rule, looking up the Access groups they reference.
+func (api *API) accessRulesUsePostureRule(ctx context.Context, rc *ResourceContainer, ruleID string, groups map[string]bool, rules ...[]interface{}) (bool, error) {
+	for _, list := range rules {
+		for _, rule := range list {
+			r, ok := rule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if posture, ok := r["device_posture"].(map[string]interface{}); ok && posture["integration_uid"] == ruleID {
+				return true, nil
+			}
+
+			group, ok := r["group"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			groupID, _ := group["id"].(string)
+			if groupID == "" {
+				continue
+			}
+
+			uses, seen := groups[groupID]
+			if !seen {
+				groups[groupID] = false
+
+				g, err := api.GetAccessGroup(ctx, rc, groupID)
+				if err != nil {
+					return false, err
+				}
+
+				uses, err = api.accessRulesUsePostureRule(ctx, rc, ruleID, groups, g.Include, g.Exclude, g.Require)
+				if err != nil {
+					return false, err
+				}
+				groups[groupID] = uses
+			}
+
+			if uses {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// SerialNumberPostureRuleParams describes a `serial_number` device posture
+// rule created from an allow-list of serial numbers.
+type SerialNumberPostureRuleParams struct {
+	Name        string
+	Description string
+	Schedule    string
+	Match       []DevicePostureRuleMatch
+	// SerialNumbers is the allow-list of device serial numbers. It must not
+	// be empty.
+	SerialNumbers []string
+}
+
+// CreateSerialNumberPostureRule creates a Gateway list of type "SERIAL" with
+// the given serial numbers and a `serial_number` device posture rule checking
+// against it. Blank and duplicate serial numbers are dropped. If the rule
+// can't be created, the list is deleted again.
+//
+// API reference: https://api.cloudflare.com/#device-posture-rules-create-device-posture-rule
+func (api *API) CreateSerialNumberPostureRule(ctx context.Context, accountID string, params SerialNumberPostureRuleParams) (DevicePostureRule, error) {
+	serials := make([]string, 0, len(params.SerialNumbers))
+	for _, serial := range params.SerialNumbers {
+		serials = append(serials, strings.TrimSpace(serial))
+	}
+	serials = uniqueDeviceBatchIDs(serials)
+	if len(serials) == 0 {
+		return DevicePostureRule{}, ErrMissingDevicePostureSerialNumbers
+	}
+
+	items := make([]TeamsListItem, 0, len(serials))
+	for _, serial := range serials {
+		items = append(items, TeamsListItem{Value: serial})
+	}
+
+	rc := AccountIdentifier(accountID)
+	list, err := api.CreateTeamsList(ctx, rc, CreateTeamsListParams{
+		Name:        params.Name + " serial numbers",
+		Type:        "SERIAL",
+		Description: params.Description,
+		Items:       items,
+	})
+	if err != nil {
+		return DevicePostureRule{}, err
+	}
+
+	rule, err := api.CreateDevicePostureRule(ctx, accountID, DevicePostureRule{
+		Type:        DevicePostureRuleTypeSerialNumber,
+		Name:        params.Name,
+		Description: params.Description,
+		Schedule:    params.Schedule,
+		Match:       params.Match,
+		Input:       DevicePostureRuleInput{ID: list.ID},
+	})
+	if err != nil {
+		if deleteErr := api.DeleteTeamsList(ctx, rc, list.ID); deleteErr != nil {
+			return DevicePostureRule{}, fmt.Errorf("%w (deleting serial number list %s also failed: %s)", err, list.ID, deleteErr)
+		}
+		return DevicePostureRule{}, err
+	}
+
+	return rule, nil
+}
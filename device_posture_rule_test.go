@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -786,3 +788,616 @@ func TestDeleteDevicePostureRule(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestNewClientCertificatePostureInput(t *testing.T) {
+	input, err := NewClientCertificatePostureInput("d2c04b78-3ba2-4294-8efa-4e85aef0777f")
+	if assert.NoError(t, err) {
+		assert.Equal(t, DevicePostureRuleInput{CertificateID: "d2c04b78-3ba2-4294-8efa-4e85aef0777f"}, input)
+	}
+
+	_, err = NewClientCertificatePostureInput("")
+	assert.ErrorIs(t, err, ErrMissingDevicePostureCertificateID)
+}
+
+func TestCreateDevicePostureRuleClientCertificateMissingID(t *testing.T) {
+	setup()
+	defer teardown()
+
+	_, err := client.CreateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		Name: "My rule name",
+		Type: DevicePostureRuleTypeClientCertificate,
+	})
+	assert.ErrorIs(t, err, ErrMissingDevicePostureCertificateID)
+
+	_, err = client.UpdateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		ID:   "480f4f69-1a28-4fdd-9240-1ed29f0ac1db",
+		Name: "My rule name",
+		Type: DevicePostureRuleTypeClientCertificate,
+	})
+	assert.ErrorIs(t, err, ErrMissingDevicePostureCertificateID)
+}
+
+func TestNewTaniumPostureInput(t *testing.T) {
+	input, err := NewTaniumPostureInput(TaniumPostureInputParams{
+		ConnectionID:  "bc7cbfbb-600a-42e4-8a23-45b5e85f804f",
+		EidLastSeen:   "1d",
+		RiskLevel:     "low",
+		ScoreOperator: "<",
+		TotalScore:    10,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DevicePostureRuleInput{
+			ConnectionID:  "bc7cbfbb-600a-42e4-8a23-45b5e85f804f",
+			EidLastSeen:   "1d",
+			RiskLevel:     "low",
+			ScoreOperator: "<",
+			TotalScore:    10,
+		}, input)
+	}
+
+	_, err = NewTaniumPostureInput(TaniumPostureInputParams{})
+	assert.ErrorIs(t, err, ErrMissingDevicePostureConnectionID)
+
+	_, err = NewTaniumPostureInput(TaniumPostureInputParams{ConnectionID: "bc7cbfbb-600a-42e4-8a23-45b5e85f804f", RiskLevel: "severe"})
+	assert.ErrorContains(t, err, "risk level")
+
+	_, err = NewTaniumPostureInput(TaniumPostureInputParams{ConnectionID: "bc7cbfbb-600a-42e4-8a23-45b5e85f804f", ScoreOperator: "=>"})
+	assert.ErrorContains(t, err, "score operator")
+}
+
+func TestNewKolidePostureInput(t *testing.T) {
+	input, err := NewKolidePostureInput("bc7cbfbb-600a-42e4-8a23-45b5e85f804f", "<=", 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DevicePostureRuleInput{
+			ConnectionID:  "bc7cbfbb-600a-42e4-8a23-45b5e85f804f",
+			CountOperator: "<=",
+			IssueCount:    "2",
+		}, input)
+	}
+
+	_, err = NewKolidePostureInput("", "<=", 2)
+	assert.ErrorIs(t, err, ErrMissingDevicePostureConnectionID)
+
+	_, err = NewKolidePostureInput("bc7cbfbb-600a-42e4-8a23-45b5e85f804f", "", 2)
+	assert.ErrorContains(t, err, "count operator")
+
+	_, err = NewKolidePostureInput("bc7cbfbb-600a-42e4-8a23-45b5e85f804f", "<", -1)
+	assert.ErrorContains(t, err, "issue count")
+}
+
+func TestNewFirewallAndApplicationPostureInput(t *testing.T) {
+	assert.Equal(t, DevicePostureRuleInput{Enabled: true}, NewFirewallPostureInput())
+
+	input, err := NewApplicationPostureInput(ApplicationPostureInputParams{
+		Path:       "/Applications/Antivirus.app",
+		Sha256:     "B5BB9D8014A0F9B1D61E21E796D78DCCDF1352F23CD32812F4850B878AE4944C",
+		Thumbprint: "0123456789abcdef0123456789abcdef01234567",
+		Running:    true,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DevicePostureRuleInput{
+			Path:       "/Applications/Antivirus.app",
+			Sha256:     "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+			Thumbprint: "0123456789abcdef0123456789abcdef01234567",
+			Running:    true,
+		}, input)
+	}
+
+	_, err = NewApplicationPostureInput(ApplicationPostureInputParams{})
+	assert.ErrorContains(t, err, "path")
+
+	_, err = NewApplicationPostureInput(ApplicationPostureInputParams{Path: "/bin/av", Sha256: "abc"})
+	assert.ErrorContains(t, err, "sha256")
+}
+
+func TestCreateDevicePostureRuleMismatchedInput(t *testing.T) {
+	setup()
+	defer teardown()
+
+	_, err := client.CreateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		Name:  "Firewall",
+		Type:  DevicePostureRuleTypeFirewall,
+		Input: DevicePostureRuleInput{Enabled: true, Path: "/bin/av"},
+	})
+	assert.ErrorContains(t, err, "not supported by firewall rules: path")
+
+	_, err = client.CreateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		Name:  "Firewall",
+		Type:  DevicePostureRuleTypeFirewall,
+		Input: DevicePostureRuleInput{},
+	})
+	assert.ErrorContains(t, err, "enabled")
+
+	_, err = client.CreateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		Name:  "Antivirus",
+		Type:  DevicePostureRuleTypeApplication,
+		Input: DevicePostureRuleInput{Path: "/bin/av", Enabled: true, CertificateID: "abc"},
+	})
+	assert.ErrorContains(t, err, "not supported by application rules: enabled, certificate_id")
+}
+
+func TestCheckDevicePostureRuleIntegration(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture/integration/bc7cbfbb-600a-42e4-8a23-45b5e85f804f", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "bc7cbfbb-600a-42e4-8a23-45b5e85f804f",
+				"type": "kolide",
+				"name": "My Kolide integration"
+			}
+		}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture/integration/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"success": false, "errors": [{"code": 1001, "message": "not found"}], "messages": []}`)
+	})
+
+	err := client.CheckDevicePostureRuleIntegration(context.Background(), testAccountID, DevicePostureRule{
+		Type:  DevicePostureRuleTypeKolide,
+		Input: DevicePostureRuleInput{ConnectionID: "bc7cbfbb-600a-42e4-8a23-45b5e85f804f"},
+	})
+	assert.NoError(t, err)
+
+	err = client.CheckDevicePostureRuleIntegration(context.Background(), testAccountID, DevicePostureRule{
+		Type:  DevicePostureRuleTypeKolide,
+		Input: DevicePostureRuleInput{ConnectionID: "missing"},
+	})
+	assert.ErrorContains(t, err, "does not exist")
+}
+
+func TestDeleteDevicePostureRuleSafe(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ruleID := "480f4f69-1a28-4fdd-9240-1ed29f0ac1db"
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"policy_id": "a", "name": "compliant", "match": "any(device_posture.checks.passed[*] in {\"%s\"})"},
+				{"policy_id": "b", "name": "other", "match": "identity.email == \"%s@example.com\""}
+			],
+			"result_info": {"page": 1, "per_page": 20, "count": 2, "total_count": 2, "total_pages": 1}
+		}`, ruleID, ruleID)
+	})
+
+	deleted := false
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture/"+ruleID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method, "Expected method 'DELETE', got %s", r.Method)
+		deleted = true
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": {"id": %q}}`, ruleID)
+	})
+
+	err := client.DeleteDevicePostureRuleSafe(context.Background(), testAccountID, ruleID, false)
+	assert.ErrorIs(t, err, ErrDevicePostureRuleInUse)
+	var inUse *DevicePostureRuleInUseError
+	if assert.ErrorAs(t, err, &inUse) {
+		if assert.Len(t, inUse.Policies, 1) {
+			assert.Equal(t, "a", *inUse.Policies[0].PolicyID)
+		}
+	}
+	assert.False(t, deleted)
+
+	assert.NoError(t, client.DeleteDevicePostureRuleSafe(context.Background(), testAccountID, ruleID, true))
+	assert.True(t, deleted)
+
+	_, err = client.DeviceSettingsPoliciesUsingPostureRule(context.Background(), AccountIdentifier(testAccountID), DeviceSettingsPoliciesUsingPostureRuleParams{})
+	assert.ErrorIs(t, err, ErrMissingResourceIdentifier)
+
+	_, err = client.DeviceSettingsPoliciesUsingPostureRule(context.Background(), ZoneIdentifier(testZoneID), DeviceSettingsPoliciesUsingPostureRuleParams{RuleID: ruleID})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+}
+
+func TestDeleteDevicePostureIntegrationSafe(t *testing.T) {
+	setup()
+	defer teardown()
+
+	integrationID := "bc7cbfbb-600a-42e4-8a23-45b5e85f804f"
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"id": "a", "name": "crowdstrike score", "type": "crowdstrike_s2s", "input": {"connection_id": "%s", "overall": "80"}},
+				{"id": "b", "name": "firewall", "type": "firewall", "input": {"enabled": true}}
+			]
+		}`, integrationID)
+	})
+
+	deleted := false
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture/integration/"+integrationID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method, "Expected method 'DELETE', got %s", r.Method)
+		deleted = true
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": null}`)
+	})
+
+	rules, err := client.DevicePostureRulesUsingIntegration(context.Background(), testAccountID, "unused")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []DevicePostureRule{}, rules)
+	}
+
+	err = client.DeleteDevicePostureIntegrationSafe(context.Background(), testAccountID, integrationID, false)
+	assert.ErrorIs(t, err, ErrDevicePostureIntegrationInUse)
+	var inUse *DevicePostureIntegrationInUseError
+	if assert.ErrorAs(t, err, &inUse) {
+		if assert.Len(t, inUse.Rules, 1) {
+			assert.Equal(t, "a", inUse.Rules[0].ID)
+		}
+	}
+	assert.False(t, deleted)
+
+	assert.NoError(t, client.DeleteDevicePostureIntegrationSafe(context.Background(), testAccountID, integrationID, true))
+	assert.True(t, deleted)
+}
+
+func TestPostureCheckCadence(t *testing.T) {
+	assert.Equal(t, "every 1h", PostureCheckCadence(DevicePostureRule{Type: "file", Schedule: "1h"}))
+	assert.Equal(t, "client default", PostureCheckCadence(DevicePostureRule{Type: DevicePostureRuleTypeFirewall}))
+	assert.Equal(t, "integration interval (integration abc)", PostureCheckCadence(DevicePostureRule{
+		Type:  DevicePostureRuleTypeTanium,
+		Input: DevicePostureRuleInput{ConnectionID: "abc"},
+	}))
+	assert.Equal(t, "integration interval", PostureCheckCadence(DevicePostureRule{Type: "intune"}))
+}
+
+func TestCreateDevicePostureRuleIntegrationWithSchedule(t *testing.T) {
+	setup()
+	defer teardown()
+
+	_, err := client.CreateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		Name:     "Tanium",
+		Type:     DevicePostureRuleTypeTanium,
+		Schedule: "1h",
+		Input:    DevicePostureRuleInput{ConnectionID: "abc"},
+	})
+	assert.ErrorContains(t, err, "must not set a schedule")
+}
+
+func TestDevicePostureRuleMatchPlatform(t *testing.T) {
+	setup()
+	defer teardown()
+
+	_, err := client.CreateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		Name:  "Firewall",
+		Type:  DevicePostureRuleTypeFirewall,
+		Match: []DevicePostureRuleMatch{{Platform: PostureOSWindows}, {Platform: "macos"}},
+	})
+	assert.ErrorContains(t, err, `invalid device posture rule match platform "macos"`)
+
+	_, err = client.UpdateDevicePostureRule(context.Background(), testAccountID, DevicePostureRule{
+		ID:    "rule",
+		Name:  "Firewall",
+		Type:  DevicePostureRuleTypeFirewall,
+		Match: []DevicePostureRuleMatch{{}},
+	})
+	assert.ErrorContains(t, err, "invalid device posture rule match platform")
+
+	// Platforms unknown to this library are kept when read.
+	var rule DevicePostureRule
+	assert.NoError(t, json.Unmarshal([]byte(`{"match": [{"platform": "visionos"}]}`), &rule))
+	assert.Equal(t, []DevicePostureRuleMatch{{Platform: "visionos"}}, rule.Match)
+	assert.False(t, rule.Match[0].Platform.Valid())
+}
+
+func TestCreateSerialNumberPostureRule(t *testing.T) {
+	setup()
+	defer teardown()
+
+	listID := "971fc4e8-388e-4ab9-b377-16430c0fc018"
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/gateway/lists", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		var body CreateTeamsListParams
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "SERIAL", body.Type)
+		assert.Equal(t, []TeamsListItem{{Value: "C02ABC"}, {Value: "C02DEF"}}, body.Items)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {"id": %q, "name": "Corporate serial numbers", "type": "SERIAL", "count": 2}
+		}`, listID)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		var body DevicePostureRule
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, DevicePostureRuleTypeSerialNumber, body.Type)
+		assert.Equal(t, listID, body.Input.ID)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {"id": "rule-1", "type": "serial_number", "name": "Corporate", "input": {"id": %q}}
+		}`, listID)
+	})
+
+	rule, err := client.CreateSerialNumberPostureRule(context.Background(), testAccountID, SerialNumberPostureRuleParams{
+		Name:          "Corporate",
+		SerialNumbers: []string{"C02ABC", " C02DEF ", "C02ABC", ""},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "rule-1", rule.ID)
+		assert.Equal(t, listID, rule.Input.ID)
+	}
+
+	_, err = client.CreateSerialNumberPostureRule(context.Background(), testAccountID, SerialNumberPostureRuleParams{Name: "Empty", SerialNumbers: []string{" "}})
+	assert.ErrorIs(t, err, ErrMissingDevicePostureSerialNumbers)
+
+	_, err = NewSerialNumberPostureInput("")
+	assert.ErrorIs(t, err, ErrMissingDevicePostureListID)
+}
+
+func TestIsPostureResultStale(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	fresh := DevicePostureResult{RuleID: "rule", DeviceID: "device", Success: true, Timestamp: now.Add(-time.Hour)}
+	old := DevicePostureResult{RuleID: "rule", DeviceID: "device", Success: true, Timestamp: now.Add(-7 * 24 * time.Hour)}
+
+	assert.False(t, isPostureResultStaleAt(fresh, 24*time.Hour, now))
+	assert.True(t, isPostureResultStaleAt(old, 24*time.Hour, now))
+	assert.False(t, isPostureResultStaleAt(old, 0, now))
+	assert.True(t, isPostureResultStaleAt(DevicePostureResult{Success: true}, 0, now))
+	assert.True(t, IsPostureResultStale(old, 24*time.Hour))
+
+	expiration, err := DevicePostureRuleExpiration(DevicePostureRule{Expiration: "1h"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Hour, expiration)
+	}
+
+	expiration, err = DevicePostureRuleExpiration(DevicePostureRule{})
+	if assert.NoError(t, err) {
+		assert.Zero(t, expiration)
+	}
+
+	_, err = DevicePostureRuleExpiration(DevicePostureRule{Expiration: "1 week"})
+	assert.Error(t, err)
+}
+
+func TestNewClientCertificateV2PostureInput(t *testing.T) {
+	input, err := NewClientCertificateV2PostureInput(ClientCertificateV2PostureInputParams{
+		CertificateID:    "d2c04b78-3ba2-4294-8efa-4e85aef0777f",
+		CommonName:       "${hostname}",
+		CheckPrivateKey:  true,
+		ExtendedKeyUsage: []string{"clientAuth"},
+		OperatingSystem:  "windows",
+		TrustStores:      []string{"system"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DevicePostureRuleInput{
+			CertificateID:    "d2c04b78-3ba2-4294-8efa-4e85aef0777f",
+			CommonName:       "${hostname}",
+			CheckPrivateKey:  true,
+			ExtendedKeyUsage: []string{"clientAuth"},
+			OperatingSystem:  "windows",
+			Locations:        &DevicePostureRuleInputLocations{TrustStores: []string{"system"}},
+		}, input)
+	}
+
+	_, err = NewClientCertificateV2PostureInput(ClientCertificateV2PostureInputParams{OperatingSystem: "mac"})
+	assert.ErrorIs(t, err, ErrMissingDevicePostureCertificateID)
+
+	_, err = NewClientCertificateV2PostureInput(ClientCertificateV2PostureInputParams{
+		CertificateID:   "not-a-certificate",
+		OperatingSystem: "mac",
+	})
+	assert.ErrorIs(t, err, ErrInvalidDevicePostureCertificateID)
+
+	_, err = NewClientCertificateV2PostureInput(ClientCertificateV2PostureInputParams{
+		CertificateID:   "d2c04b78-3ba2-4294-8efa-4e85aef0777f",
+		OperatingSystem: "ios",
+	})
+	assert.ErrorContains(t, err, "operating system")
+
+	_, err = NewClientCertificateV2PostureInput(ClientCertificateV2PostureInputParams{
+		CertificateID:    "d2c04b78-3ba2-4294-8efa-4e85aef0777f",
+		OperatingSystem:  "linux",
+		ExtendedKeyUsage: []string{"serverAuth"},
+	})
+	assert.ErrorContains(t, err, "extended key usage")
+
+	_, err = NewClientCertificatePostureInput("d2c04b78")
+	assert.ErrorIs(t, err, ErrInvalidDevicePostureCertificateID)
+}
+
+func TestAccessApplicationsUsingPostureRule(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ruleID := "480f4f69-1a28-4fdd-9240-1ed29f0ac1db"
+
+	respond := func(w http.ResponseWriter, result string) {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": %s,
+			"result_info": {"page": 1, "per_page": 25, "count": 1, "total_count": 1, "total_pages": 1}
+		}`, result)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		respond(w, `[{"id": "direct", "name": "Direct"}, {"id": "grouped", "name": "Grouped"}, {"id": "unrelated", "name": "Unrelated"}]`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps/direct/policies", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, fmt.Sprintf(`[{"id": "p1", "include": [{"everyone": {}}], "require": [{"device_posture": {"integration_uid": %q}}]}]`, ruleID))
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps/grouped/policies", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, `[{"id": "p2", "include": [{"group": {"id": "outer"}}]}]`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps/unrelated/policies", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, `[{"id": "p3", "include": [{"email": {"email": "user@example.com"}}], "exclude": [{"group": {"id": "cyclic"}}]}]`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/groups/outer", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, `{"id": "outer", "include": [{"group": {"id": "inner"}}]}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/groups/inner", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, fmt.Sprintf(`{"id": "inner", "include": [{"device_posture": {"integration_uid": %q}}]}`, ruleID))
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/groups/cyclic", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, `{"id": "cyclic", "include": [{"group": {"id": "cyclic"}}]}`)
+	})
+
+	apps, err := client.AccessApplicationsUsingPostureRule(context.Background(), testAccountID, ruleID)
+	if assert.NoError(t, err) {
+		var ids []string
+		for _, app := range apps {
+			ids = append(ids, app.ID)
+		}
+		assert.Equal(t, []string{"direct", "grouped"}, ids)
+	}
+
+	apps, err = client.AccessApplicationsUsingPostureRule(context.Background(), testAccountID, "unused")
+	if assert.NoError(t, err) {
+		assert.NotNil(t, apps)
+		assert.Empty(t, apps)
+	}
+}
+
+func TestListDevicePostureRules(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [{"id": "r3", "type": "disk_encryption", "name": "Linux disks"}],
+				"result_info": {"page": 2, "per_page": 2, "count": 1, "total_count": 3, "total_pages": 2}
+			}`)
+			return
+		}
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"id": "r1", "type": "disk_encryption", "name": "Mac disks"},
+				{"id": "r2", "type": "firewall", "name": "Firewall"}
+			],
+			"result_info": {"page": 1, "per_page": 2, "count": 2, "total_count": 3, "total_pages": 2}
+		}`)
+	})
+
+	rules, resultInfo, err := client.ListDevicePostureRules(context.Background(), AccountIdentifier(testAccountID), ListDevicePostureRulesParams{
+		Type: "disk_encryption",
+	})
+	if assert.NoError(t, err) {
+		var ids []string
+		for _, rule := range rules {
+			ids = append(ids, rule.ID)
+		}
+		assert.Equal(t, []string{"r1", "r3"}, ids)
+		assert.Equal(t, &ResultInfo{Page: 1, PerPage: 2, TotalPages: 1, Count: 2, Total: 2}, resultInfo)
+	}
+
+	rules, _, err = client.ListDevicePostureRules(context.Background(), AccountIdentifier(testAccountID), ListDevicePostureRulesParams{})
+	if assert.NoError(t, err) {
+		assert.Len(t, rules, 3)
+	}
+}
+
+func TestNewOSVersionPostureInput(t *testing.T) {
+	setup()
+	defer teardown()
+
+	input, err := NewOSVersionPostureInput(OSVersionPostureInputParams{
+		OperatingSystem: PostureOSMac,
+		Version:         "13.4.0",
+		Operator:        ">=",
+		OSVersionExtra:  "(a)",
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DevicePostureRuleInput{OperatingSystem: "mac", Version: "13.4.0", Operator: ">=", OSVersionExtra: "(a)"}, input)
+	}
+
+	_, err = NewOSVersionPostureInput(OSVersionPostureInputParams{OperatingSystem: "macos", Version: "13.4.0", Operator: ">="})
+	assert.ErrorContains(t, err, `invalid device posture operating system "macos"`)
+
+	_, err = NewOSVersionPostureInput(OSVersionPostureInputParams{Version: "13.4.0", Operator: ">="})
+	assert.Error(t, err)
+
+	_, err = NewOSVersionPostureInput(OSVersionPostureInputParams{OperatingSystem: PostureOSLinux, Version: "6.1", Operator: "~"})
+	assert.Error(t, err)
+
+	assert.True(t, PostureOSChromeOS.Valid())
+	assert.False(t, PostureOS("macos").Valid())
+
+	// Unknown operating systems are only rejected when creating rules.
+	var rule DevicePostureRule
+	err = json.Unmarshal([]byte(`{"type": "os_version", "input": {"operating_system": "visionos", "version": "1.0", "operator": ">="}}`), &rule)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "visionos", rule.Input.OperatingSystem)
+	}
+
+	_, err = client.CreateDevicePostureRule(context.Background(), testAccountID, rule)
+	assert.ErrorContains(t, err, "invalid device posture operating system")
+}
+
+func TestDevicePostureRuleRoundTrip(t *testing.T) {
+	setup()
+	defer teardown()
+
+	fixture := loadFixture("device_posture_rule", "single_full")
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture/480f4f69-1a28-4fdd-9240-1ed29f0ac1db", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, fixture)
+	})
+
+	rule, err := client.DevicePostureRule(context.Background(), testAccountID, "480f4f69-1a28-4fdd-9240-1ed29f0ac1db")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, DevicePostureRule{
+		ID:          "480f4f69-1a28-4fdd-9240-1ed29f0ac1db",
+		Type:        "os_version",
+		Name:        "macOS up to date",
+		Description: "Requires macOS 13.4 or later, including rapid security responses.",
+		Schedule:    "1h",
+		Match:       []DevicePostureRuleMatch{{Platform: "mac"}},
+		Input:       DevicePostureRuleInput{Version: "13.4.0", Operator: ">=", OSVersionExtra: "(a)"},
+		Expiration:  "24h",
+	}, rule)
+
+	// The rule encodes back to the definition it was read from, so it can be
+	// kept under version control without spurious diffs.
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if assert.NoError(t, json.Unmarshal([]byte(fixture), &response)) {
+		encoded, err := json.Marshal(rule)
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, string(response.Result), string(encoded))
+		}
+	}
+}
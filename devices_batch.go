@@ -0,0 +1,105 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultDeviceBatchConcurrency is the number of concurrent requests made by
+// batch device operations when no concurrency is provided.
+const defaultDeviceBatchConcurrency = 4
+
+// DeviceBatchError is returned by batch device operations when the operation
+// failed for one or more items. Errors is keyed by the ID of the failed item.
+type DeviceBatchError struct {
+	Errors map[string]error
+}
+
+func (e *DeviceBatchError) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", id, e.Errors[id]))
+	}
+
+	return fmt.Sprintf("%d batch device operation(s) failed: %s", len(ids), strings.Join(msgs, "; "))
+}
+
+// newDeviceBatchError returns a *DeviceBatchError for errs, or nil when errs
+// is empty.
+func newDeviceBatchError(errs map[string]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &DeviceBatchError{Errors: errs}
+}
+
+// uniqueDeviceBatchIDs returns ids without empty values and duplicates,
+// preserving the order of first occurrence.
+func uniqueDeviceBatchIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+
+	return unique
+}
+
+// runDeviceBatch calls fn for every ID with at most concurrency calls in
+// flight and returns the errors keyed by ID. Once the context is done, IDs that
+// have not been started yet fail with the context error.
+func runDeviceBatch(ctx context.Context, ids []string, concurrency int, fn func(ctx context.Context, id string) error) map[string]error {
+	if concurrency < 1 {
+		concurrency = defaultDeviceBatchConcurrency
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[string]error)
+		sem  = make(chan struct{}, concurrency)
+	)
+
+	recordError := func(id string, err error) {
+		mu.Lock()
+		errs[id] = err
+		mu.Unlock()
+	}
+
+	for _, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			recordError(id, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(ctx, id); err != nil {
+				recordError(id, err)
+			}
+		}(id)
+	}
+
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,69 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueDeviceBatchIDs(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, uniqueDeviceBatchIDs([]string{"a", "b", "", "a", "c", "b"}))
+	assert.Equal(t, []string{}, uniqueDeviceBatchIDs(nil))
+}
+
+func TestRunDeviceBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	errs := runDeviceBatch(context.Background(), []string{"a", "b", "c", "d", "e"}, 2, func(ctx context.Context, id string) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+
+		if id == "c" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	assert.LessOrEqual(t, maxInFlight, int32(2))
+	assert.Equal(t, map[string]error{"c": errors.New("boom")}, errs)
+}
+
+func TestRunDeviceBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int32
+	errs := runDeviceBatch(ctx, []string{"a", "b"}, 1, func(ctx context.Context, id string) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	// The first ID may already be started before cancellation is observed.
+	assert.LessOrEqual(t, int(calls)+len(errs), 2)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestDeviceBatchError(t *testing.T) {
+	assert.Nil(t, newDeviceBatchError(map[string]error{}))
+
+	err := newDeviceBatchError(map[string]error{
+		"b": errors.New("not found"),
+		"a": errors.New("forbidden"),
+	})
+
+	var batchErr *DeviceBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Len(t, batchErr.Errors, 2)
+	}
+	assert.EqualError(t, err, "2 batch device operation(s) failed: a: forbidden; b: not found")
+}
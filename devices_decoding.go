@@ -0,0 +1,49 @@
+package cloudflare
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/goccy/go-json"
+)
+
+// unmarshalDeviceResponse decodes the response of a device endpoint into v,
+// a pointer to a response struct. With strict device decoding enabled, the
+// result is decoded a second time rejecting fields the result type doesn't
+// model, so that API changes are noticed. Fields of the response envelope,
+// such as result_info, are never checked.
+func (api *API) unmarshalDeviceResponse(res []byte, v interface{}) error {
+	if err := json.Unmarshal(res, v); err != nil {
+		return err
+	}
+
+	if !api.strictDeviceDecoding {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	field, ok := rv.Elem().Type().FieldByName("Result")
+	if !ok {
+		return nil
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(res, &envelope); err != nil || isJSONNull(envelope.Result) {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(envelope.Result))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(reflect.New(field.Type).Interface()); err != nil {
+		return fmt.Errorf("strict device decoding: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+var (
+	ErrMissingDeviceEnrollmentApplication = errors.New("no device enrollment (warp) Access application found for the account")
+	ErrMissingDeviceEnrollmentPolicyID    = errors.New("device enrollment policy ID must not be empty")
+	ErrMissingDeviceEnrollmentTokenID     = errors.New("device enrollment token ID must not be empty")
+	ErrDeviceEnrollmentTokenNotUsed       = errors.New("service token is not allowed to enroll devices")
+)
+
+// DeviceEnrollmentApprovalGroups holds the approval configuration of a single
+// device enrollment permission policy.
+//
+// Device enrollment permissions are Access policies attached to the account's
+// `warp` Access application. Requiring approval on one of these policies
+// delegates enrollment approval to the listed approval groups.
+type DeviceEnrollmentApprovalGroups struct {
+	ApplicationID    string                `json:"application_id"`
+	PolicyID         string                `json:"policy_id"`
+	Name             string                `json:"name"`
+	ApprovalRequired bool                  `json:"approval_required"`
+	Groups           []AccessApprovalGroup `json:"approval_groups"`
+}
+
+type GetDeviceEnrollmentApprovalGroupsParams struct{}
+
+type UpdateDeviceEnrollmentApprovalGroupsParams struct {
+	// PolicyID is the device enrollment permission policy to update.
+	PolicyID string
+	// Groups replaces the approval groups of the policy. An empty list
+	// disables approvals.
+	Groups []AccessApprovalGroup
+}
+
+// deviceEnrollmentApplication returns the `warp` Access application which
+// holds the device enrollment permissions of an account.
+func (api *API) deviceEnrollmentApplication(ctx context.Context, rc *ResourceContainer) (AccessApplication, error) {
+	apps, _, err := api.ListAccessApplications(ctx, rc, ListAccessApplicationsParams{})
+	if err != nil {
+		return AccessApplication{}, err
+	}
+
+	for _, app := range apps {
+		if app.Type == Warp {
+			return app, nil
+		}
+	}
+
+	return AccessApplication{}, ErrMissingDeviceEnrollmentApplication
+}
+
+// GetDeviceEnrollmentApprovalGroups returns the approval groups of every
+// device enrollment permission policy.
+//
+// API reference: https://developers.cloudflare.com/api/operations/access-policies-list-access-policies
+func (api *API) GetDeviceEnrollmentApprovalGroups(ctx context.Context, rc *ResourceContainer, params GetDeviceEnrollmentApprovalGroupsParams) ([]DeviceEnrollmentApprovalGroups, error) {
+	if rc.Level != AccountRouteLevel {
+		return []DeviceEnrollmentApprovalGroups{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	app, err := api.deviceEnrollmentApplication(ctx, rc)
+	if err != nil {
+		return []DeviceEnrollmentApprovalGroups{}, err
+	}
+
+	policies, _, err := api.ListAccessPolicies(ctx, rc, ListAccessPoliciesParams{ApplicationID: app.ID})
+	if err != nil {
+		return []DeviceEnrollmentApprovalGroups{}, err
+	}
+
+	groups := make([]DeviceEnrollmentApprovalGroups, 0, len(policies))
+	for _, policy := range policies {
+		groups = append(groups, DeviceEnrollmentApprovalGroups{
+			ApplicationID:    app.ID,
+			PolicyID:         policy.ID,
+			Name:             policy.Name,
+			ApprovalRequired: policy.ApprovalRequired != nil && *policy.ApprovalRequired,
+			Groups:           policy.ApprovalGroups,
+		})
+	}
+
+	return groups, nil
+}
+
+// UpdateDeviceEnrollmentApprovalGroups replaces the approval groups of a
+// device enrollment permission policy. Approval is required whenever at least
+// one group is provided. The remaining policy settings are left unchanged.
+//
+// API reference: https://developers.cloudflare.com/api/operations/access-policies-update-an-access-policy
+func (api *API) UpdateDeviceEnrollmentApprovalGroups(ctx context.Context, rc *ResourceContainer, params UpdateDeviceEnrollmentApprovalGroupsParams) (DeviceEnrollmentApprovalGroups, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceEnrollmentApprovalGroups{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if params.PolicyID == "" {
+		return DeviceEnrollmentApprovalGroups{}, ErrMissingDeviceEnrollmentPolicyID
+	}
+
+	for _, group := range params.Groups {
+		for _, email := range group.EmailAddresses {
+			if _, err := mail.ParseAddress(email); err != nil {
+				return DeviceEnrollmentApprovalGroups{}, fmt.Errorf("invalid approval group email address %q: %w", email, err)
+			}
+		}
+	}
+
+	app, err := api.deviceEnrollmentApplication(ctx, rc)
+	if err != nil {
+		return DeviceEnrollmentApprovalGroups{}, err
+	}
+
+	policy, err := api.GetAccessPolicy(ctx, rc, GetAccessPolicyParams{ApplicationID: app.ID, PolicyID: params.PolicyID})
+	if err != nil {
+		return DeviceEnrollmentApprovalGroups{}, err
+	}
+
+	approvalRequired := len(params.Groups) > 0
+	updated, err := api.UpdateAccessPolicy(ctx, rc, UpdateAccessPolicyParams{
+		ApplicationID:                app.ID,
+		PolicyID:                     policy.ID,
+		Precedence:                   policy.Precedence,
+		Decision:                     policy.Decision,
+		Name:                         policy.Name,
+		IsolationRequired:            policy.IsolationRequired,
+		SessionDuration:              policy.SessionDuration,
+		PurposeJustificationRequired: policy.PurposeJustificationRequired,
+		PurposeJustificationPrompt:   policy.PurposeJustificationPrompt,
+		ApprovalRequired:             &approvalRequired,
+		ApprovalGroups:               params.Groups,
+		Include:                      policy.Include,
+		Exclude:                      policy.Exclude,
+		Require:                      policy.Require,
+	})
+	if err != nil {
+		return DeviceEnrollmentApprovalGroups{}, err
+	}
+
+	return DeviceEnrollmentApprovalGroups{
+		ApplicationID:    app.ID,
+		PolicyID:         updated.ID,
+		Name:             updated.Name,
+		ApprovalRequired: updated.ApprovalRequired != nil && *updated.ApprovalRequired,
+		Groups:           updated.ApprovalGroups,
+	}, nil
+}
+
+type RotateDeviceEnrollmentTokenParams struct {
+	// TokenID is the ID of the Access service token used to enroll devices.
+	TokenID string
+}
+
+// RotateDeviceEnrollmentToken rotates the client secret of an Access service
+// token used for headless device enrollment and returns the new secret. The
+// secret is only ever returned by this call; listing service tokens never
+// includes it, so it must be stored by the caller.
+//
+// The token must be allowed to enroll devices by one of the device enrollment
+// permission policies, either by ID or through a policy accepting any valid
+// service token. Otherwise ErrDeviceEnrollmentTokenNotUsed is returned and
+// nothing is rotated.
+//
+// API reference: https://api.cloudflare.com/#access-service-tokens-rotate-a-service-token
+func (api *API) RotateDeviceEnrollmentToken(ctx context.Context, rc *ResourceContainer, params RotateDeviceEnrollmentTokenParams) (AccessServiceTokenRotateResponse, error) {
+	if rc.Level != AccountRouteLevel {
+		return AccessServiceTokenRotateResponse{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if params.TokenID == "" {
+		return AccessServiceTokenRotateResponse{}, ErrMissingDeviceEnrollmentTokenID
+	}
+
+	app, err := api.deviceEnrollmentApplication(ctx, rc)
+	if err != nil {
+		return AccessServiceTokenRotateResponse{}, err
+	}
+
+	policies, _, err := api.ListAccessPolicies(ctx, rc, ListAccessPoliciesParams{ApplicationID: app.ID})
+	if err != nil {
+		return AccessServiceTokenRotateResponse{}, err
+	}
+
+	used := false
+	for _, policy := range policies {
+		if accessRulesAllowServiceToken(policy.Include, params.TokenID) {
+			used = true
+			break
+		}
+	}
+	if !used {
+		return AccessServiceTokenRotateResponse{}, fmt.Errorf("%w: %s", ErrDeviceEnrollmentTokenNotUsed, params.TokenID)
+	}
+
+	return api.RotateAccessServiceToken(ctx, rc, params.TokenID)
+}
+
+// accessRulesAllowServiceToken reports whether Access policy rules include the
+// service token with the given ID, or any valid service token.
+func accessRulesAllowServiceToken(rules []interface{}, tokenID string) bool {
+	for _, rule := range rules {
+		r, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := r["any_valid_service_token"]; ok {
+			return true
+		}
+
+		if token, ok := r["service_token"].(map[string]interface{}); ok && token["token_id"] == tokenID {
+			return true
+		}
+	}
+
+	return false
+}
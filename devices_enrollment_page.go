@@ -0,0 +1,138 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrInvalidDeviceEnrollmentPageURL is returned when a link of the device
+// enrollment page isn't an absolute http or https URL.
+var ErrInvalidDeviceEnrollmentPageURL = errors.New("device enrollment page links must be absolute http or https URLs")
+
+// DeviceEnrollmentPage is the branding of the page users log in on to enroll
+// a device with the WARP client. It is part of the Access organization of
+// the account, which GetAccessOrganization returns in full.
+//
+// The API has no dedicated terms of service link; it is usually added to
+// FooterText.
+type DeviceEnrollmentPage struct {
+	OrganizationName string
+	LogoURL          string
+	BackgroundColor  string
+	TextColor        string
+	HeaderText       string
+	FooterText       string
+}
+
+type GetDeviceEnrollmentPageParams struct{}
+
+// UpdateDeviceEnrollmentPageParams holds the enrollment page fields to
+// change. Fields left nil keep their current value.
+type UpdateDeviceEnrollmentPageParams struct {
+	OrganizationName *string
+	// LogoURL must be an absolute http or https URL, or empty to remove the
+	// logo.
+	LogoURL         *string
+	BackgroundColor *string
+	TextColor       *string
+	HeaderText      *string
+	FooterText      *string
+}
+
+// GetDeviceEnrollmentPage returns the device enrollment page customization of
+// an account.
+//
+// API reference: https://api.cloudflare.com/#access-organizations-access-organization-details
+func (api *API) GetDeviceEnrollmentPage(ctx context.Context, rc *ResourceContainer, params GetDeviceEnrollmentPageParams) (DeviceEnrollmentPage, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceEnrollmentPage{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	org, _, err := api.GetAccessOrganization(ctx, rc, GetAccessOrganizationParams{})
+	if err != nil {
+		return DeviceEnrollmentPage{}, err
+	}
+
+	return deviceEnrollmentPageFromOrganization(org), nil
+}
+
+// UpdateDeviceEnrollmentPage updates the device enrollment page customization
+// of an account and returns the result. As the Access organization can only
+// be replaced as a whole, it is read first and written back with the changed
+// fields; the other organization settings are kept.
+//
+// API reference: https://api.cloudflare.com/#access-organizations-update-access-organization
+func (api *API) UpdateDeviceEnrollmentPage(ctx context.Context, rc *ResourceContainer, params UpdateDeviceEnrollmentPageParams) (DeviceEnrollmentPage, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceEnrollmentPage{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if params.LogoURL != nil && *params.LogoURL != "" {
+		if err := validateDeviceEnrollmentPageURL(*params.LogoURL); err != nil {
+			return DeviceEnrollmentPage{}, err
+		}
+	}
+
+	org, _, err := api.GetAccessOrganization(ctx, rc, GetAccessOrganizationParams{})
+	if err != nil {
+		return DeviceEnrollmentPage{}, err
+	}
+
+	design := org.LoginDesign
+	for _, field := range []struct {
+		value *string
+		dest  *string
+	}{
+		{params.OrganizationName, &org.Name},
+		{params.LogoURL, &design.LogoPath},
+		{params.BackgroundColor, &design.BackgroundColor},
+		{params.TextColor, &design.TextColor},
+		{params.HeaderText, &design.HeaderText},
+		{params.FooterText, &design.FooterText},
+	} {
+		if field.value != nil {
+			*field.dest = *field.value
+		}
+	}
+
+	updated, err := api.UpdateAccessOrganization(ctx, rc, UpdateAccessOrganizationParams{
+		Name:                           org.Name,
+		AuthDomain:                     org.AuthDomain,
+		LoginDesign:                    design,
+		IsUIReadOnly:                   org.IsUIReadOnly,
+		UIReadOnlyToggleReason:         org.UIReadOnlyToggleReason,
+		UserSeatExpirationInactiveTime: org.UserSeatExpirationInactiveTime,
+		AutoRedirectToIdentity:         org.AutoRedirectToIdentity,
+		SessionDuration:                org.SessionDuration,
+		CustomPages:                    org.CustomPages,
+		WarpAuthSessionDuration:        org.WarpAuthSessionDuration,
+		AllowAuthenticateViaWarp:       org.AllowAuthenticateViaWarp,
+	})
+	if err != nil {
+		return DeviceEnrollmentPage{}, err
+	}
+
+	return deviceEnrollmentPageFromOrganization(updated), nil
+}
+
+func deviceEnrollmentPageFromOrganization(org AccessOrganization) DeviceEnrollmentPage {
+	return DeviceEnrollmentPage{
+		OrganizationName: org.Name,
+		LogoURL:          org.LoginDesign.LogoPath,
+		BackgroundColor:  org.LoginDesign.BackgroundColor,
+		TextColor:        org.LoginDesign.TextColor,
+		HeaderText:       org.LoginDesign.HeaderText,
+		FooterText:       org.LoginDesign.FooterText,
+	}
+}
+
+func validateDeviceEnrollmentPageURL(link string) error {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidDeviceEnrollmentPageURL, link)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateDeviceEnrollmentPage(t *testing.T) {
+	setup()
+	defer teardown()
+
+	org := `{
+		"name": "Widget Corps",
+		"auth_domain": "widgetcorps.cloudflareaccess.com",
+		"login_design": {
+			"background_color": "#c5ed1b",
+			"logo_path": "https://example.com/logo.png",
+			"text_color": "#c5ed1b",
+			"header_text": "Widget Corps",
+			"footer_text": "Widget Corps"
+		},
+		"auto_redirect_to_identity": true
+	}`
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/organizations", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s}`, org)
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+
+			var params UpdateAccessOrganizationParams
+			if assert.NoError(t, json.Unmarshal(body, &params)) {
+				assert.Equal(t, "Widget Brand", params.Name)
+				assert.Equal(t, "widgetcorps.cloudflareaccess.com", params.AuthDomain)
+				assert.Equal(t, "https://brand.example.com/logo.svg", params.LoginDesign.LogoPath)
+				assert.Equal(t, "#c5ed1b", params.LoginDesign.BackgroundColor)
+				assert.Equal(t, BoolPtr(true), params.AutoRedirectToIdentity)
+			}
+			fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s}`, body)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+
+	page, err := client.GetDeviceEnrollmentPage(context.Background(), AccountIdentifier(testAccountID), GetDeviceEnrollmentPageParams{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Widget Corps", page.OrganizationName)
+		assert.Equal(t, "https://example.com/logo.png", page.LogoURL)
+	}
+
+	page, err = client.UpdateDeviceEnrollmentPage(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceEnrollmentPageParams{
+		OrganizationName: StringPtr("Widget Brand"),
+		LogoURL:          StringPtr("https://brand.example.com/logo.svg"),
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DeviceEnrollmentPage{
+			OrganizationName: "Widget Brand",
+			LogoURL:          "https://brand.example.com/logo.svg",
+			BackgroundColor:  "#c5ed1b",
+			TextColor:        "#c5ed1b",
+			HeaderText:       "Widget Corps",
+			FooterText:       "Widget Corps",
+		}, page)
+	}
+
+	_, err = client.UpdateDeviceEnrollmentPage(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceEnrollmentPageParams{
+		LogoURL: StringPtr("brand.example.com/logo.svg"),
+	})
+	assert.ErrorIs(t, err, ErrInvalidDeviceEnrollmentPageURL)
+
+	_, err = client.GetDeviceEnrollmentPage(context.Background(), ZoneIdentifier(testZoneID), GetDeviceEnrollmentPageParams{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+)
+
+const deviceEnrollmentAppsJSON = `{
+	"success": true,
+	"errors": [],
+	"messages": [],
+	"result": [
+		{"id": "480f4f69-1a28-4fdd-9240-1ed29f0ac1db", "name": "Admin", "type": "self_hosted"},
+		{"id": "f174e90a-fafe-4643-bbbc-4a0ed4fc8415", "name": "Warp Login App", "type": "warp"}
+	],
+	"result_info": {"page": 1, "per_page": 25, "count": 2, "total_count": 2}
+}`
+
+func TestGetDeviceEnrollmentApprovalGroups(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, deviceEnrollmentAppsJSON)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps/f174e90a-fafe-4643-bbbc-4a0ed4fc8415/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "699d98642c564d2e855e9661899b7252",
+					"name": "Contractors",
+					"decision": "allow",
+					"approval_required": true,
+					"approval_groups": [
+						{"email_addresses": ["it-admin@example.com"], "approvals_needed": 1}
+					]
+				}
+			],
+			"result_info": {"page": 1, "per_page": 25, "count": 1, "total_count": 1}
+		}`)
+	})
+
+	want := []DeviceEnrollmentApprovalGroups{{
+		ApplicationID:    "f174e90a-fafe-4643-bbbc-4a0ed4fc8415",
+		PolicyID:         "699d98642c564d2e855e9661899b7252",
+		Name:             "Contractors",
+		ApprovalRequired: true,
+		Groups: []AccessApprovalGroup{
+			{EmailAddresses: []string{"it-admin@example.com"}, ApprovalsNeeded: 1},
+		},
+	}}
+
+	actual, err := client.GetDeviceEnrollmentApprovalGroups(context.Background(), testAccountRC, GetDeviceEnrollmentApprovalGroupsParams{})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, actual)
+	}
+}
+
+func TestUpdateDeviceEnrollmentApprovalGroups(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, deviceEnrollmentAppsJSON)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps/f174e90a-fafe-4643-bbbc-4a0ed4fc8415/policies/699d98642c564d2e855e9661899b7252", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "699d98642c564d2e855e9661899b7252",
+					"name": "Contractors",
+					"decision": "allow",
+					"precedence": 1,
+					"include": [{"email_domain": {"domain": "example.com"}}]
+				}
+			}`)
+		case http.MethodPut:
+			var body UpdateAccessPolicyParams
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "Contractors", body.Name)
+			assert.Equal(t, 1, body.Precedence)
+			assert.Equal(t, BoolPtr(true), body.ApprovalRequired)
+			assert.Len(t, body.Include, 1)
+			fmt.Fprint(w, `{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "699d98642c564d2e855e9661899b7252",
+					"name": "Contractors",
+					"decision": "allow",
+					"precedence": 1,
+					"approval_required": true,
+					"approval_groups": [
+						{"email_addresses": ["it-admin@example.com"], "approvals_needed": 1}
+					]
+				}
+			}`)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+
+	groups := []AccessApprovalGroup{{EmailAddresses: []string{"it-admin@example.com"}, ApprovalsNeeded: 1}}
+
+	actual, err := client.UpdateDeviceEnrollmentApprovalGroups(context.Background(), testAccountRC, UpdateDeviceEnrollmentApprovalGroupsParams{
+		PolicyID: "699d98642c564d2e855e9661899b7252",
+		Groups:   groups,
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, DeviceEnrollmentApprovalGroups{
+			ApplicationID:    "f174e90a-fafe-4643-bbbc-4a0ed4fc8415",
+			PolicyID:         "699d98642c564d2e855e9661899b7252",
+			Name:             "Contractors",
+			ApprovalRequired: true,
+			Groups:           groups,
+		}, actual)
+	}
+
+	_, err = client.UpdateDeviceEnrollmentApprovalGroups(context.Background(), testAccountRC, UpdateDeviceEnrollmentApprovalGroupsParams{
+		PolicyID: "699d98642c564d2e855e9661899b7252",
+		Groups:   []AccessApprovalGroup{{EmailAddresses: []string{"not an email"}}},
+	})
+	assert.ErrorContains(t, err, "invalid approval group email address")
+}
+
+func TestRotateDeviceEnrollmentToken(t *testing.T) {
+	setup()
+	defer teardown()
+
+	tokenID := "a0b1c2d3-e4f5-4a6b-8c9d-0e1f2a3b4c5d"
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, deviceEnrollmentAppsJSON)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/apps/f174e90a-fafe-4643-bbbc-4a0ed4fc8415/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "699d98642c564d2e855e9661899b7252",
+					"name": "Headless enrollment",
+					"decision": "non_identity",
+					"include": [{"service_token": {"token_id": %q}}]
+				}
+			],
+			"result_info": {"page": 1, "per_page": 25, "count": 1, "total_count": 1}
+		}`, tokenID)
+	})
+
+	rotated := false
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/service_tokens/"+tokenID+"/rotate", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		rotated = true
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {"id": %q, "name": "enrollment", "client_id": "client.access", "client_secret": "new-secret"}
+		}`, tokenID)
+	})
+
+	actual, err := client.RotateDeviceEnrollmentToken(context.Background(), testAccountRC, RotateDeviceEnrollmentTokenParams{TokenID: tokenID})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "new-secret", actual.ClientSecret)
+		assert.True(t, rotated)
+	}
+
+	rotated = false
+	_, err = client.RotateDeviceEnrollmentToken(context.Background(), testAccountRC, RotateDeviceEnrollmentTokenParams{TokenID: "other"})
+	assert.ErrorIs(t, err, ErrDeviceEnrollmentTokenNotUsed)
+	assert.False(t, rotated)
+
+	_, err = client.RotateDeviceEnrollmentToken(context.Background(), testAccountRC, RotateDeviceEnrollmentTokenParams{})
+	assert.ErrorIs(t, err, ErrMissingDeviceEnrollmentTokenID)
+}
@@ -0,0 +1,40 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDeviceListLimitExceeded is matched by errors reporting a fallback domain
+// or split tunnel list longer than the limit set with UsingDeviceListLimits.
+var ErrDeviceListLimitExceeded = errors.New("device list exceeds the configured limit")
+
+// DeviceListLimitError is returned when an update would write more fallback
+// domains or split tunnel entries than the configured limit. No request is
+// made, so the existing list is left untouched. It matches
+// ErrDeviceListLimitExceeded with errors.Is.
+type DeviceListLimitError struct {
+	// List is the kind of list, "fallback domains" or
+	// "split tunnel entries".
+	List  string
+	Count int
+	Limit int
+}
+
+func (e *DeviceListLimitError) Error() string {
+	return fmt.Sprintf("%d %s exceed the limit of %d", e.Count, e.List, e.Limit)
+}
+
+func (e *DeviceListLimitError) Is(target error) bool {
+	return target == ErrDeviceListLimitExceeded
+}
+
+// checkDeviceListLimit returns a *DeviceListLimitError if count exceeds
+// limit. A limit of zero or less disables the check.
+func checkDeviceListLimit(list string, count, limit int) error {
+	if limit > 0 && count > limit {
+		return &DeviceListLimitError{List: list, Count: count, Limit: limit}
+	}
+
+	return nil
+}
@@ -1,13 +1,48 @@
 package cloudflare
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/goccy/go-json"
 )
 
+var (
+	// ErrMissingDeviceSettingsPolicyID is returned when creating a device
+	// settings policy succeeded but the response doesn't identify the new
+	// policy.
+	ErrMissingDeviceSettingsPolicyID = errors.New("device settings policy was created but the response contains no policy ID")
+
+	// ErrInsufficientDevicePermissions is returned when the credentials in use
+	// are not permitted to read the device settings of an account.
+	ErrInsufficientDevicePermissions = errors.New("insufficient permissions for device operations: the API token is likely missing the \"Zero Trust Read\" (or \"Zero Trust Edit\") account permission")
+
+	// ErrMissingDeviceSettingsPolicyMatch is returned when creating or
+	// updating a custom device settings policy without a match expression,
+	// which would make the policy match no device.
+	ErrMissingDeviceSettingsPolicyMatch = errors.New("custom device settings policy requires a non-empty match expression; set AllowEmptyMatch for an intentional placeholder")
+
+	// ErrMissingDeviceSettingsPolicyName is returned when upserting a device
+	// settings policy without a name.
+	ErrMissingDeviceSettingsPolicyName = errors.New("missing required device settings policy name")
+
+	// ErrAmbiguousDeviceSettingsPolicyName is returned when upserting a
+	// device settings policy whose name is used by several policies.
+	ErrAmbiguousDeviceSettingsPolicyName = errors.New("device settings policy name is used by several policies")
+)
+
 type Enabled struct {
 	Enabled bool `json:"enabled"`
 }
@@ -35,6 +70,62 @@ type ServiceModeV2 struct {
 	Port int         `json:"port,omitempty"`
 }
 
+// deviceSettingsPolicyModeIgnoredFields are the fields of a policy that have
+// no effect in each service mode:
+//
+//   - posture_only: devices only report their posture. No traffic goes
+//     through WARP and its DNS resolver isn't used, so the split tunnel lists
+//     and the fallback domains are ignored.
+//   - warp_tunnel_only: traffic is tunneled but DNS queries go to the local
+//     resolver, so the fallback domains are ignored.
+//   - 1dot1: only DNS queries go through WARP, so the split tunnel lists are
+//     ignored.
+var deviceSettingsPolicyModeIgnoredFields = map[ServiceMode][]string{
+	postureOnly:    {"include", "exclude", "fallback_domains"},
+	warpTunnelOnly: {"fallback_domains"},
+	oneDotOne:      {"include", "exclude"},
+}
+
+// DeviceSettingsPolicyModeWarning reports a field of a policy that is set but
+// ignored in the service mode of the policy.
+type DeviceSettingsPolicyModeWarning struct {
+	Mode ServiceMode
+	// Field is the JSON name of the field, e.g. "fallback_domains".
+	Field   string
+	Message string
+}
+
+// ValidateDeviceSettingsPolicyMode reports the split tunnel lists and fallback
+// domains that policy sets although its service mode ignores them, e.g.
+// fallback domains for a posture_only policy. The policy is otherwise valid,
+// as the API accepts these combinations, but the settings have no effect on
+// devices until the mode changes.
+func ValidateDeviceSettingsPolicyMode(policy DeviceSettingsPolicy) []DeviceSettingsPolicyModeWarning {
+	if policy.ServiceModeV2 == nil {
+		return nil
+	}
+
+	mode := policy.ServiceModeV2.Mode
+	set := map[string]bool{
+		"include":          policy.Include != nil && len(*policy.Include) > 0,
+		"exclude":          policy.Exclude != nil && len(*policy.Exclude) > 0,
+		"fallback_domains": policy.FallbackDomains != nil && len(*policy.FallbackDomains) > 0,
+	}
+
+	var warnings []DeviceSettingsPolicyModeWarning
+	for _, field := range deviceSettingsPolicyModeIgnoredFields[mode] {
+		if set[field] {
+			warnings = append(warnings, DeviceSettingsPolicyModeWarning{
+				Mode:    mode,
+				Field:   field,
+				Message: fmt.Sprintf("%s is ignored in %s mode", field, mode),
+			})
+		}
+	}
+
+	return warnings
+}
+
 type DeviceSettingsPolicy struct {
 	ServiceModeV2       *ServiceModeV2    `json:"service_mode_v2"`
 	DisableAutoFallback *bool             `json:"disable_auto_fallback"`
@@ -59,6 +150,9 @@ type DeviceSettingsPolicy struct {
 	Description         *string           `json:"description"`
 	LANAllowMinutes     *uint             `json:"lan_allow_minutes"`
 	LANAllowSubnetSize  *uint             `json:"lan_allow_subnet_size"`
+	// RegisterInterfaceIPWithDNS registers the WARP interface IP address of
+	// the device with the local DNS server.
+	RegisterInterfaceIPWithDNS *bool `json:"register_interface_ip_with_dns"`
 }
 
 type DeviceSettingsPolicyResponse struct {
@@ -89,6 +183,12 @@ type CreateDeviceSettingsPolicyParams struct {
 	Description         *string        `json:"description,omitempty"`
 	LANAllowMinutes     *uint          `json:"lan_allow_minutes,omitempty"`
 	LANAllowSubnetSize  *uint          `json:"lan_allow_subnet_size,omitempty"`
+
+	RegisterInterfaceIPWithDNS *bool `json:"register_interface_ip_with_dns,omitempty"`
+
+	// AllowEmptyMatch creates the policy without a match expression, e.g. as
+	// a placeholder. Such a policy matches no device.
+	AllowEmptyMatch bool `json:"-"`
 }
 
 type UpdateDefaultDeviceSettingsPolicyParams struct {
@@ -109,6 +209,12 @@ type UpdateDefaultDeviceSettingsPolicyParams struct {
 	Description         *string        `json:"description,omitempty"`
 	LANAllowMinutes     *uint          `json:"lan_allow_minutes,omitempty"`
 	LANAllowSubnetSize  *uint          `json:"lan_allow_subnet_size,omitempty"`
+
+	RegisterInterfaceIPWithDNS *bool `json:"register_interface_ip_with_dns,omitempty"`
+
+	// Reset lists the fields to reset to their server default. They must not
+	// be set in the same request.
+	Reset []DeviceSettingsPolicyResettableField `json:"-"`
 }
 
 type UpdateDeviceSettingsPolicyParams struct {
@@ -130,6 +236,193 @@ type UpdateDeviceSettingsPolicyParams struct {
 	Description         *string        `json:"description,omitempty"`
 	LANAllowMinutes     *uint          `json:"lan_allow_minutes,omitempty"`
 	LANAllowSubnetSize  *uint          `json:"lan_allow_subnet_size,omitempty"`
+
+	RegisterInterfaceIPWithDNS *bool `json:"register_interface_ip_with_dns,omitempty"`
+
+	// Reset lists the fields to reset to their server default. They must not
+	// be set in the same request.
+	Reset []DeviceSettingsPolicyResettableField `json:"-"`
+
+	// AllowEmptyMatch allows setting Match to an empty string, which makes
+	// the policy match no device.
+	AllowEmptyMatch bool `json:"-"`
+
+	// OnChange is called after a successful update with the fields that
+	// changed, as reported by DiffDeviceSettingsPolicies. Setting it makes
+	// the update fetch the policy first to know its prior state.
+	OnChange func(changes []DeviceSettingsPolicyFieldChange) `json:"-"`
+}
+
+// DeviceSettingsPolicyResettableField is a device settings policy field that
+// can be reset to its server default in an update by sending it as null.
+type DeviceSettingsPolicyResettableField string
+
+// The fields that can be listed in the Reset of an update. Any other field is
+// either required or has no server default to go back to, so it has to be
+// given an explicit value instead.
+const (
+	DeviceSettingsPolicyResetSupportURL         DeviceSettingsPolicyResettableField = "support_url"
+	DeviceSettingsPolicyResetCaptivePortal      DeviceSettingsPolicyResettableField = "captive_portal"
+	DeviceSettingsPolicyResetAutoConnect        DeviceSettingsPolicyResettableField = "auto_connect"
+	DeviceSettingsPolicyResetDescription        DeviceSettingsPolicyResettableField = "description"
+	DeviceSettingsPolicyResetLANAllowMinutes    DeviceSettingsPolicyResettableField = "lan_allow_minutes"
+	DeviceSettingsPolicyResetLANAllowSubnetSize DeviceSettingsPolicyResettableField = "lan_allow_subnet_size"
+)
+
+var deviceSettingsPolicyResettableFields = map[DeviceSettingsPolicyResettableField]bool{
+	DeviceSettingsPolicyResetSupportURL:         true,
+	DeviceSettingsPolicyResetCaptivePortal:      true,
+	DeviceSettingsPolicyResetAutoConnect:        true,
+	DeviceSettingsPolicyResetDescription:        true,
+	DeviceSettingsPolicyResetLANAllowMinutes:    true,
+	DeviceSettingsPolicyResetLANAllowSubnetSize: true,
+}
+
+// MarshalJSON sends the fields listed in Reset as null.
+func (p UpdateDefaultDeviceSettingsPolicyParams) MarshalJSON() ([]byte, error) {
+	type params UpdateDefaultDeviceSettingsPolicyParams
+	return marshalDeviceSettingsPolicyReset(params(p), p.Reset)
+}
+
+// MarshalJSON sends the fields listed in Reset as null.
+func (p UpdateDeviceSettingsPolicyParams) MarshalJSON() ([]byte, error) {
+	type params UpdateDeviceSettingsPolicyParams
+	return marshalDeviceSettingsPolicyReset(params(p), p.Reset)
+}
+
+// marshalDeviceSettingsPolicyReset marshals the update params v and adds a
+// null value for every field in reset. Fields that can't be reset or that
+// also have a value in v are rejected.
+func marshalDeviceSettingsPolicyReset(v interface{}, reset []DeviceSettingsPolicyResettableField) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil || len(reset) == 0 {
+		return body, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, field := range reset {
+		if !deviceSettingsPolicyResettableFields[field] {
+			return nil, fmt.Errorf("device settings policy field %q can't be reset", field)
+		}
+		if value, ok := fields[string(field)]; ok && string(value) != "null" {
+			return nil, fmt.Errorf("device settings policy field %q is both set and reset", field)
+		}
+		fields[string(field)] = json.RawMessage("null")
+	}
+
+	return json.Marshal(fields)
+}
+
+// ToCreateParams returns the params creating a copy of the policy. Fields set
+// by the server, such as the policy ID, and the split tunnel and fallback
+// domain lists, which are managed through their own endpoints, are left out.
+func (p DeviceSettingsPolicy) ToCreateParams() CreateDeviceSettingsPolicyParams {
+	var params CreateDeviceSettingsPolicyParams
+	copyDeviceSettingsPolicyFields(p, &params)
+	return params
+}
+
+// ToUpdateParams returns the params updating the policy with its current
+// settings, so that a policy can be read, changed and written back. Like
+// ToCreateParams, it leaves out the fields that can't be updated.
+func (p DeviceSettingsPolicy) ToUpdateParams() UpdateDeviceSettingsPolicyParams {
+	var params UpdateDeviceSettingsPolicyParams
+	copyDeviceSettingsPolicyFields(p, &params)
+	if p.PolicyID != nil {
+		params.PolicyID = StringPtr(*p.PolicyID)
+	}
+	return params
+}
+
+// ToUpdateDefaultParams is ToUpdateParams for the default policy.
+func (p DeviceSettingsPolicy) ToUpdateDefaultParams() UpdateDefaultDeviceSettingsPolicyParams {
+	var params UpdateDefaultDeviceSettingsPolicyParams
+	copyDeviceSettingsPolicyFields(p, &params)
+	return params
+}
+
+// copyDeviceSettingsPolicyFields copies the fields of policy into the params
+// with the same JSON name. Going through JSON makes a deep copy, so changing
+// the params never modifies the policy, and picks up new fields without
+// having to list them.
+func copyDeviceSettingsPolicyFields(policy DeviceSettingsPolicy, params interface{}) {
+	b, _ := json.Marshal(policy)
+	_ = json.Unmarshal(b, params)
+}
+
+// MinimalDeviceSettingsPolicyPatch returns the update params changing current
+// into desired, with only the fields that differ set and every other field
+// left nil, so that the update is minimal and can be reviewed field by field.
+//
+// Fields set in desired replace those of current when their values differ.
+// Fields unset (nil) in desired are kept as is, unless they can be reset to
+// their server default, in which case they are listed in Reset. The policies
+// are normalized like DeviceSettingsPolicyEquateEmpty and
+// DeviceSettingsPolicyNormalizeFallbackDomains before being compared.
+// The split tunnel and fallback domain lists are managed through their own
+// endpoints and are ignored. ExcludeOfficeIps is always sent by the update
+// params, so it is set to its desired or current value even when unchanged,
+// rather than being sent as null.
+func MinimalDeviceSettingsPolicyPatch(current, desired DeviceSettingsPolicy) UpdateDeviceSettingsPolicyParams {
+	var params UpdateDeviceSettingsPolicyParams
+	switch {
+	case desired.PolicyID != nil:
+		params.PolicyID = StringPtr(*desired.PolicyID)
+	case current.PolicyID != nil:
+		params.PolicyID = StringPtr(*current.PolicyID)
+	}
+
+	currentFields := deviceSettingsPolicyJSONFields(comparableDeviceSettingsPolicy(current))
+	desiredFields := deviceSettingsPolicyJSONFields(comparableDeviceSettingsPolicy(desired))
+
+	v := reflect.ValueOf(&params).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		want, have := desiredFields[name], currentFields[name]
+		if isJSONNull(want) {
+			if !isJSONNull(have) && deviceSettingsPolicyResettableFields[DeviceSettingsPolicyResettableField(name)] {
+				params.Reset = append(params.Reset, DeviceSettingsPolicyResettableField(name))
+			}
+			continue
+		}
+
+		if bytes.Equal(want, have) {
+			continue
+		}
+
+		_ = json.Unmarshal(want, v.Field(i).Addr().Interface())
+	}
+
+	if params.ExcludeOfficeIps == nil {
+		switch {
+		case desired.ExcludeOfficeIps != nil:
+			params.ExcludeOfficeIps = BoolPtr(*desired.ExcludeOfficeIps)
+		case current.ExcludeOfficeIps != nil:
+			params.ExcludeOfficeIps = BoolPtr(*current.ExcludeOfficeIps)
+		}
+	}
+
+	return params
+}
+
+// deviceSettingsPolicyJSONFields returns the JSON encoding of each field of
+// policy, keyed by JSON name.
+func deviceSettingsPolicyJSONFields(policy DeviceSettingsPolicy) map[string]json.RawMessage {
+	b, _ := json.Marshal(policy)
+
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(b, &fields)
+
+	return fields
 }
 
 type ListDeviceSettingsPoliciesResponse struct {
@@ -150,6 +443,22 @@ type GetDeviceSettingsPolicyParams struct {
 	PolicyID *string `json:"-"`
 }
 
+type VerifyDeviceAPIPermissionsParams struct{}
+
+type GetDeviceSettingsPoliciesParams struct {
+	PolicyIDs []string
+	// Concurrency is the maximum number of policies fetched at once.
+	// Defaults to 4.
+	Concurrency int
+}
+
+type ScheduleDeviceSettingsPolicyUpdateParams struct {
+	// At is the time the update should be applied. Times in the past apply
+	// the update immediately.
+	At     time.Time
+	Update UpdateDeviceSettingsPolicyParams
+}
+
 // UpdateDeviceClientCertificates controls the zero trust zone used to provision client certificates.
 //
 // API reference: https://api.cloudflare.com/#device-client-certificates
@@ -166,10 +475,12 @@ func (api *API) UpdateDeviceClientCertificates(ctx context.Context, rc *Resource
 		return result, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return result, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
+	api.logResponseWarnings(http.MethodPatch, uri, result.Response)
+
 	return result, err
 }
 
@@ -190,7 +501,7 @@ func (api *API) GetDeviceClientCertificates(ctx context.Context, rc *ResourceCon
 		return result, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return result, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
@@ -198,7 +509,8 @@ func (api *API) GetDeviceClientCertificates(ctx context.Context, rc *ResourceCon
 }
 
 // CreateDeviceSettingsPolicy creates a settings policy against devices that
-// match the policy.
+// match the policy. ErrMissingDeviceSettingsPolicyMatch is returned when
+// Match is empty, unless AllowEmptyMatch is set.
 //
 // API reference: https://api.cloudflare.com/#devices-create-device-settings-policy
 func (api *API) CreateDeviceSettingsPolicy(ctx context.Context, rc *ResourceContainer, params CreateDeviceSettingsPolicyParams) (DeviceSettingsPolicy, error) {
@@ -206,19 +518,57 @@ func (api *API) CreateDeviceSettingsPolicy(ctx context.Context, rc *ResourceCont
 		return DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
 	}
 
+	if !params.AllowEmptyMatch && (params.Match == nil || strings.TrimSpace(*params.Match) == "") {
+		return DeviceSettingsPolicy{}, ErrMissingDeviceSettingsPolicyMatch
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/policy", rc.Level, rc.Identifier)
 
 	result := DeviceSettingsPolicyResponse{}
-	res, err := api.makeRequestContext(ctx, http.MethodPost, uri, params)
+	res, err := api.makeRequestContextWithHeadersComplete(ctx, http.MethodPost, uri, params, nil)
 	if err != nil {
 		return DeviceSettingsPolicy{}, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
-		return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
+	if len(bytes.TrimSpace(res.Body)) > 0 {
+		if err := api.unmarshalDeviceResponse(res.Body, &result); err != nil {
+			return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
+		}
 	}
 
-	return result.Result, err
+	api.logResponseWarnings(http.MethodPost, uri, result.Response)
+
+	if result.Result.PolicyID != nil && *result.Result.PolicyID != "" {
+		return result.Result, nil
+	}
+
+	// Some responses carry no policy in the body but point to the created
+	// resource in the Location header instead.
+	policyID := deviceSettingsPolicyIDFromLocation(res.Headers.Get("Location"))
+	if policyID == "" {
+		return DeviceSettingsPolicy{}, ErrMissingDeviceSettingsPolicyID
+	}
+
+	return api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: &policyID})
+}
+
+// deviceSettingsPolicyIDFromLocation returns the policy ID at the end of a
+// Location header such as "/accounts/<id>/devices/policy/<policy_id>".
+func deviceSettingsPolicyIDFromLocation(location string) string {
+	if location == "" {
+		return ""
+	}
+
+	if u, err := url.Parse(location); err == nil {
+		location = u.Path
+	}
+
+	segments := strings.Split(strings.TrimRight(location, "/"), "/")
+	if len(segments) < 2 || segments[len(segments)-2] != "policy" {
+		return ""
+	}
+
+	return segments[len(segments)-1]
 }
 
 // UpdateDefaultDeviceSettingsPolicy updates the default settings policy for an account
@@ -236,14 +586,19 @@ func (api *API) UpdateDefaultDeviceSettingsPolicy(ctx context.Context, rc *Resou
 		return DeviceSettingsPolicy{}, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
+	api.logResponseWarnings(http.MethodPatch, uri, result.Response)
+
 	return result.Result, err
 }
 
-// UpdateDeviceSettingsPolicy updates a settings policy
+// UpdateDeviceSettingsPolicy updates a settings policy.
+// ErrMissingDeviceSettingsPolicyMatch is returned when Match is set to an
+// empty expression, unless AllowEmptyMatch is set. A nil Match leaves the
+// expression of the policy unchanged.
 //
 // API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
 func (api *API) UpdateDeviceSettingsPolicy(ctx context.Context, rc *ResourceContainer, params UpdateDeviceSettingsPolicyParams) (DeviceSettingsPolicy, error) {
@@ -251,6 +606,19 @@ func (api *API) UpdateDeviceSettingsPolicy(ctx context.Context, rc *ResourceCont
 		return DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
 	}
 
+	if !params.AllowEmptyMatch && params.Match != nil && strings.TrimSpace(*params.Match) == "" {
+		return DeviceSettingsPolicy{}, ErrMissingDeviceSettingsPolicyMatch
+	}
+
+	var before DeviceSettingsPolicy
+	if params.OnChange != nil {
+		var err error
+		before, err = api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: params.PolicyID})
+		if err != nil {
+			return DeviceSettingsPolicy{}, err
+		}
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/policy/%s", rc.Level, rc.Identifier, *params.PolicyID)
 
 	result := DeviceSettingsPolicyResponse{}
@@ -259,13 +627,234 @@ func (api *API) UpdateDeviceSettingsPolicy(ctx context.Context, rc *ResourceCont
 		return DeviceSettingsPolicy{}, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
+	api.logResponseWarnings(http.MethodPatch, uri, result.Response)
+
+	if params.OnChange != nil {
+		params.OnChange(DiffDeviceSettingsPolicies(before, result.Result))
+	}
+
 	return result.Result, err
 }
 
+// DeviceSettingsPolicyFieldChange is the before and after value of a device
+// settings policy field, as JSON. A field that is unset on one side is null.
+type DeviceSettingsPolicyFieldChange struct {
+	// Field is the JSON name of the field, e.g. "support_url".
+	Field  string
+	Before json.RawMessage
+	After  json.RawMessage
+}
+
+// DiffDeviceSettingsPolicies returns the fields that differ between before
+// and after, ordered by field name. The policies are normalized like
+// DeviceSettingsPolicyEquateEmpty and
+// DeviceSettingsPolicyNormalizeFallbackDomains first, so neither an empty
+// list and an unset one nor fallback domains differing only in their
+// spelling are reported as a change.
+func DiffDeviceSettingsPolicies(before, after DeviceSettingsPolicy) []DeviceSettingsPolicyFieldChange {
+	beforeFields := deviceSettingsPolicyJSONFields(comparableDeviceSettingsPolicy(before))
+	afterFields := deviceSettingsPolicyJSONFields(comparableDeviceSettingsPolicy(after))
+
+	names := make([]string, 0, len(afterFields))
+	for name := range afterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []DeviceSettingsPolicyFieldChange
+	for _, name := range names {
+		was, is := beforeFields[name], afterFields[name]
+		if isJSONNull(was) && isJSONNull(is) || bytes.Equal(was, is) {
+			continue
+		}
+
+		if isJSONNull(was) {
+			was = json.RawMessage("null")
+		}
+		if isJSONNull(is) {
+			is = json.RawMessage("null")
+		}
+		changes = append(changes, DeviceSettingsPolicyFieldChange{Field: name, Before: was, After: is})
+	}
+
+	return changes
+}
+
+type SetRegisterInterfaceIPWithDNSParams struct {
+	// PolicyID is the device settings policy to change. When empty, the
+	// default policy of the account is changed.
+	PolicyID string
+	Enabled  bool
+}
+
+// SetRegisterInterfaceIPWithDNS toggles whether devices register their WARP
+// interface IP address with the local DNS server. Only this field is sent, so
+// the other settings of the policy are left untouched.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) SetRegisterInterfaceIPWithDNS(ctx context.Context, rc *ResourceContainer, params SetRegisterInterfaceIPWithDNSParams) (DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	body := struct {
+		RegisterInterfaceIPWithDNS bool `json:"register_interface_ip_with_dns"`
+	}{params.Enabled}
+
+	return api.patchDeviceSettingsPolicy(ctx, rc, params.PolicyID, body)
+}
+
+// deviceSettingsPolicyCustomOnlyFields are the fields that can't be set on
+// the default policy, which applies to every device not matched by a custom
+// policy and is always evaluated last.
+var deviceSettingsPolicyCustomOnlyFields = map[string]bool{
+	"name":       true,
+	"match":      true,
+	"precedence": true,
+	"enabled":    true,
+}
+
+type SetDeviceSettingsPolicyFieldParams struct {
+	// PolicyIDs are the device settings policies to change. An empty policy
+	// ID, or "default", selects the default policy.
+	PolicyIDs []string
+	// Field is the JSON name of the field, such as "allow_mode_switch".
+	Field string
+	// Value is the value to set. nil resets the fields that can be reset to
+	// their server default.
+	Value interface{}
+	// Concurrency is the maximum number of policies updated at once.
+	// Defaults to 4.
+	Concurrency int
+}
+
+// SetDeviceSettingsPolicyField sets a single field to the same value on each
+// of the listed device settings policies. Like the dedicated setters, only
+// this field is sent, so the other settings of the policies are left
+// untouched.
+//
+// The field must be one of UpdateDeviceSettingsPolicyParams and the value
+// must encode to its type. An error is returned before any request when the
+// field is unknown, the value doesn't fit, or the field, such as "match",
+// can't be set on the default policy and it is listed.
+//
+// The updated policies are returned keyed by policy ID, or "default" for the
+// default policy. Policies that fail to be updated are left out and
+// reported in a *DeviceBatchError with the same keys.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) SetDeviceSettingsPolicyField(ctx context.Context, rc *ResourceContainer, params SetDeviceSettingsPolicyFieldParams) (map[string]DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return map[string]DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	raw, err := deviceSettingsPolicyFieldValue(params.Field, params.Value)
+	if err != nil {
+		return map[string]DeviceSettingsPolicy{}, err
+	}
+
+	keys := make([]string, 0, len(params.PolicyIDs))
+	for _, policyID := range params.PolicyIDs {
+		if policyID == "" || policyID == "default" {
+			if deviceSettingsPolicyCustomOnlyFields[params.Field] {
+				return map[string]DeviceSettingsPolicy{}, fmt.Errorf("device settings policy field %q can't be set on the default policy", params.Field)
+			}
+			policyID = "default"
+		}
+		keys = append(keys, policyID)
+	}
+
+	body := map[string]json.RawMessage{params.Field: raw}
+
+	var mu sync.Mutex
+	results := make(map[string]DeviceSettingsPolicy, len(keys))
+	errs := runDeviceBatch(ctx, uniqueDeviceBatchIDs(keys), params.Concurrency, func(ctx context.Context, key string) error {
+		policyID := key
+		if key == "default" {
+			policyID = ""
+		}
+
+		policy, err := api.patchDeviceSettingsPolicy(ctx, rc, policyID, body)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		results[key] = policy
+		mu.Unlock()
+
+		return nil
+	})
+
+	return results, newDeviceBatchError(errs)
+}
+
+// deviceSettingsPolicyFieldValue checks that value can be sent as field of
+// UpdateDeviceSettingsPolicyParams and returns its JSON encoding.
+func deviceSettingsPolicyFieldValue(field string, value interface{}) (json.RawMessage, error) {
+	t := reflect.TypeOf(UpdateDeviceSettingsPolicyParams{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != field || name == "" || name == "-" {
+			continue
+		}
+
+		if value == nil {
+			if !deviceSettingsPolicyResettableFields[DeviceSettingsPolicyResettableField(field)] {
+				return nil, fmt.Errorf("device settings policy field %q can't be reset", field)
+			}
+			return json.RawMessage("null"), nil
+		}
+
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for device settings policy field %q: %w", field, err)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(reflect.New(t.Field(i).Type).Interface()); err != nil {
+			return nil, fmt.Errorf("invalid value for device settings policy field %q: %w", field, err)
+		}
+
+		if field == "match" && strings.TrimSpace(string(raw)) == `""` {
+			return nil, ErrMissingDeviceSettingsPolicyMatch
+		}
+
+		return raw, nil
+	}
+
+	return nil, fmt.Errorf("unknown device settings policy field %q", field)
+}
+
+// patchDeviceSettingsPolicy sends body as is to a device settings policy, or
+// to the default policy when policyID is empty. The update params always
+// send exclude_office_ips, so single field changes use a dedicated body to
+// leave every other field of the policy untouched.
+func (api *API) patchDeviceSettingsPolicy(ctx context.Context, rc *ResourceContainer, policyID string, body interface{}) (DeviceSettingsPolicy, error) {
+	uri := fmt.Sprintf("/%s/%s/devices/policy", rc.Level, rc.Identifier)
+	if policyID != "" {
+		uri = fmt.Sprintf("%s/%s", uri, policyID)
+	}
+
+	result := DeviceSettingsPolicyResponse{}
+	res, err := api.makeRequestContext(ctx, http.MethodPatch, uri, body)
+	if err != nil {
+		return DeviceSettingsPolicy{}, err
+	}
+
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
+		return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
+	}
+
+	api.logResponseWarnings(http.MethodPatch, uri, result.Response)
+
+	return result.Result, nil
+}
+
 // DeleteDeviceSettingsPolicy deletes a settings policy and returns a list
 // of all of the other policies in the account.
 //
@@ -283,10 +872,12 @@ func (api *API) DeleteDeviceSettingsPolicy(ctx context.Context, rc *ResourceCont
 		return []DeviceSettingsPolicy{}, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return []DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
+	api.logResponseWarnings(http.MethodDelete, uri, result.Response)
+
 	return result.Result, err
 }
 
@@ -306,7 +897,7 @@ func (api *API) GetDefaultDeviceSettingsPolicy(ctx context.Context, rc *Resource
 		return DeviceSettingsPolicy{}, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
@@ -329,15 +920,93 @@ func (api *API) GetDeviceSettingsPolicy(ctx context.Context, rc *ResourceContain
 		return DeviceSettingsPolicy{}, err
 	}
 
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return DeviceSettingsPolicy{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
 	return result.Result, err
 }
 
+type VerifyServiceModeParams struct {
+	// PolicyID is the device settings policy to verify. When empty, the
+	// default policy of the account is verified.
+	PolicyID string
+	// Expected is the service mode the policy should have. The port is only
+	// compared when set.
+	Expected ServiceModeV2
+}
+
+// VerifyServiceMode reads a device settings policy back and reports whether
+// its service mode matches Expected, to catch modes that are unavailable on
+// the account's plan being silently dropped although the update succeeded.
+// The actual service mode is returned alongside. A policy without a mode
+// runs in warp mode.
+//
+// API reference: https://api.cloudflare.com/#devices-get-device-settings-policy-by-id
+func (api *API) VerifyServiceMode(ctx context.Context, rc *ResourceContainer, params VerifyServiceModeParams) (bool, ServiceModeV2, error) {
+	if rc.Level != AccountRouteLevel {
+		return false, ServiceModeV2{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	var policy DeviceSettingsPolicy
+	var err error
+	if params.PolicyID == "" {
+		policy, err = api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+	} else {
+		policy, err = api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: &params.PolicyID})
+	}
+	if err != nil {
+		return false, ServiceModeV2{}, err
+	}
+
+	var actual ServiceModeV2
+	if policy.ServiceModeV2 != nil {
+		actual = *policy.ServiceModeV2
+	}
+
+	expected := params.Expected
+	actualMode, expectedMode := actual.Mode, expected.Mode
+	if actualMode == "" {
+		actualMode = warp
+	}
+	if expectedMode == "" {
+		expectedMode = warp
+	}
+	if actualMode != expectedMode {
+		return false, actual, nil
+	}
+
+	return expected.Port == 0 || actual.Port == expected.Port, actual, nil
+}
+
 type ListDeviceSettingsPoliciesParams struct {
 	ResultInfo
+
+	// ResolveSplitTunnelMode fetches every listed policy whose split tunnel
+	// lists are missing from the list response, so that
+	// DeviceSettingsPolicy.SplitTunnelMode reports its mode.
+	ResolveSplitTunnelMode bool `url:"-"`
+	// Concurrency is the maximum number of policies fetched at once when
+	// ResolveSplitTunnelMode is set. Defaults to 4.
+	Concurrency int `url:"-"`
+}
+
+// listDeviceSettingsPoliciesPage fetches a single page of device settings
+// policies.
+func (api *API) listDeviceSettingsPoliciesPage(ctx context.Context, rc *ResourceContainer, params ListDeviceSettingsPoliciesParams) (ListDeviceSettingsPoliciesResponse, error) {
+	uri := buildURI(fmt.Sprintf("/%s/%s/devices/policies", rc.Level, rc.Identifier), params)
+	res, err := api.makeRequestContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return ListDeviceSettingsPoliciesResponse{}, err
+	}
+
+	var r ListDeviceSettingsPoliciesResponse
+	err = api.unmarshalDeviceResponse(res, &r)
+	if err != nil {
+		return ListDeviceSettingsPoliciesResponse{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
+	}
+
+	return r, nil
 }
 
 // ListDeviceSettingsPolicies returns all device settings policies for an account
@@ -356,16 +1025,10 @@ func (api *API) ListDeviceSettingsPolicies(ctx context.Context, rc *ResourceCont
 	var policies []DeviceSettingsPolicy
 	var lastResultInfo ResultInfo
 	for {
-		uri := buildURI(fmt.Sprintf("/%s/%s/devices/policies", rc.Level, rc.Identifier), params)
-		res, err := api.makeRequestContext(ctx, http.MethodGet, uri, nil)
+		r, err := api.listDeviceSettingsPoliciesPage(ctx, rc, params)
 		if err != nil {
 			return nil, nil, err
 		}
-		var r ListDeviceSettingsPoliciesResponse
-		err = json.Unmarshal(res, &r)
-		if err != nil {
-			return nil, nil, fmt.Errorf("%s: %w", errUnmarshalError, err)
-		}
 		policies = append(policies, r.Result...)
 		lastResultInfo = r.ResultInfo
 		params.ResultInfo = r.ResultInfo.Next()
@@ -373,5 +1036,946 @@ func (api *API) ListDeviceSettingsPolicies(ctx context.Context, rc *ResourceCont
 			break
 		}
 	}
+
+	if params.ResolveSplitTunnelMode {
+		if err := api.resolveSplitTunnelModes(ctx, rc, policies, params.Concurrency); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return policies, &lastResultInfo, nil
 }
+
+// SplitTunnelMode returns "include" when the policy only tunnels the entries
+// of its include list and "exclude" when it tunnels everything but the
+// entries of its exclude list. An empty string is returned when neither list
+// is known, as is often the case for policies returned by
+// ListDeviceSettingsPolicies.
+func (p DeviceSettingsPolicy) SplitTunnelMode() string {
+	if p.Include != nil && len(*p.Include) > 0 {
+		return "include"
+	}
+
+	if p.Exclude != nil {
+		return "exclude"
+	}
+
+	return ""
+}
+
+// DefaultCaptivePortalTimeoutSeconds is the captive portal timeout the API
+// applies to new policies.
+const DefaultCaptivePortalTimeoutSeconds = 180
+
+// CaptivePortalConfig is the captive portal behavior of a device settings
+// policy. When enabled, WARP may be paused for up to TimeoutSeconds so that
+// users can sign in to a captive portal, e.g. on hotel or airport networks.
+//
+// The API stores it in the captive_portal field as a single number of
+// seconds, where 0 disables captive portal detection. NewCaptivePortalConfig
+// and CaptivePortalConfig.Value convert between the two.
+type CaptivePortalConfig struct {
+	Enabled        bool
+	TimeoutSeconds int
+}
+
+// NewCaptivePortalConfig converts the captive_portal field of a policy. A
+// positive value enables captive portal detection with that timeout, while
+// 0, a negative value or nil disable it.
+func NewCaptivePortalConfig(captivePortal *int) CaptivePortalConfig {
+	if captivePortal == nil || *captivePortal <= 0 {
+		return CaptivePortalConfig{}
+	}
+
+	return CaptivePortalConfig{Enabled: true, TimeoutSeconds: *captivePortal}
+}
+
+// Value returns the captive_portal field value for c: 0 when disabled, and
+// otherwise TimeoutSeconds, or DefaultCaptivePortalTimeoutSeconds when no
+// timeout is set.
+func (c CaptivePortalConfig) Value() *int {
+	switch {
+	case !c.Enabled:
+		return IntPtr(0)
+	case c.TimeoutSeconds <= 0:
+		return IntPtr(DefaultCaptivePortalTimeoutSeconds)
+	default:
+		return IntPtr(c.TimeoutSeconds)
+	}
+}
+
+// CaptivePortalConfig returns the captive portal behavior of the policy, see
+// NewCaptivePortalConfig.
+func (p DeviceSettingsPolicy) CaptivePortalConfig() CaptivePortalConfig {
+	return NewCaptivePortalConfig(p.CaptivePortal)
+}
+
+// WARPRequired reports whether users of devices covered by the policy can't
+// stop using WARP. That takes all three of:
+//
+//   - SwitchLocked, so that users can't turn the WARP switch off;
+//   - not AllowedToLeave, so that users can't leave the Zero Trust
+//     organization and use WARP without its policies;
+//   - not AllowModeSwitch, so that users can't switch to a service mode
+//     that doesn't tunnel their traffic.
+//
+// Unset fields are taken at their server default: the switch unlocked,
+// leaving allowed and mode switching disallowed.
+func (p DeviceSettingsPolicy) WARPRequired() bool {
+	switchLocked := p.SwitchLocked != nil && *p.SwitchLocked
+	allowedToLeave := p.AllowedToLeave == nil || *p.AllowedToLeave
+	allowModeSwitch := p.AllowModeSwitch != nil && *p.AllowModeSwitch
+
+	return switchLocked && !allowedToLeave && !allowModeSwitch
+}
+
+// resolveSplitTunnelModes fetches the policies without a split tunnel mode and
+// copies their include and exclude lists in place.
+func (api *API) resolveSplitTunnelModes(ctx context.Context, rc *ResourceContainer, policies []DeviceSettingsPolicy, concurrency int) error {
+	indexes := make(map[string]int)
+	ids := make([]string, 0, len(policies))
+	for i, policy := range policies {
+		if policy.SplitTunnelMode() != "" {
+			continue
+		}
+
+		id := defaultSplitTunnelBatchID
+		if !policy.Default {
+			if policy.PolicyID == nil || *policy.PolicyID == "" {
+				continue
+			}
+			id = *policy.PolicyID
+		}
+		indexes[id] = i
+		ids = append(ids, id)
+	}
+
+	// Every call writes to a different policy, so no locking is needed.
+	errs := runDeviceBatch(ctx, ids, concurrency, func(ctx context.Context, id string) error {
+		var policy DeviceSettingsPolicy
+		var err error
+		if id == defaultSplitTunnelBatchID {
+			policy, err = api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+		} else {
+			policy, err = api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: StringPtr(id)})
+		}
+		if err != nil {
+			return err
+		}
+
+		policies[indexes[id]].Include = policy.Include
+		policies[indexes[id]].Exclude = policy.Exclude
+
+		return nil
+	})
+
+	return newDeviceBatchError(errs)
+}
+
+// VerifyDeviceAPIPermissions performs a minimal read of the default device
+// settings policy to confirm the credentials in use are allowed to manage
+// devices. A 403 response is reported as ErrInsufficientDevicePermissions.
+func (api *API) VerifyDeviceAPIPermissions(ctx context.Context, rc *ResourceContainer, params VerifyDeviceAPIPermissionsParams) error {
+	_, err := api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+	if err != nil {
+		var authErr *AuthenticationError
+		if errors.As(err, &authErr) {
+			return fmt.Errorf("%w: %s", ErrInsufficientDevicePermissions, authErr.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeviceSettingsPolicyEqualOption configures DeviceSettingsPoliciesEqual.
+type DeviceSettingsPolicyEqualOption func(*deviceSettingsPolicyEqualOptions)
+
+type deviceSettingsPolicyEqualOptions struct {
+	equateEmpty              bool
+	normalizeFallbackDomains bool
+}
+
+// DeviceSettingsPolicyEquateEmpty treats unset (nil) and empty lists as equal
+// when comparing policies. This applies to the split tunnel and fallback
+// domain lists as well as the DNS servers of each fallback domain.
+func DeviceSettingsPolicyEquateEmpty() DeviceSettingsPolicyEqualOption {
+	return func(o *deviceSettingsPolicyEqualOptions) {
+		o.equateEmpty = true
+	}
+}
+
+// DeviceSettingsPolicyNormalizeFallbackDomains applies
+// NormalizeFallbackDomains to the fallback domains of both policies before
+// comparing them.
+func DeviceSettingsPolicyNormalizeFallbackDomains() DeviceSettingsPolicyEqualOption {
+	return func(o *deviceSettingsPolicyEqualOptions) {
+		o.normalizeFallbackDomains = true
+	}
+}
+
+// DeviceSettingsPoliciesEqual reports whether two policies have the same
+// values for every field. Pointer fields are compared by the values they
+// point to.
+func DeviceSettingsPoliciesEqual(a, b DeviceSettingsPolicy, opts ...DeviceSettingsPolicyEqualOption) bool {
+	o := deviceSettingsPolicyEqualOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.normalizeFallbackDomains {
+		if a.FallbackDomains != nil {
+			domains := NormalizeFallbackDomains(*a.FallbackDomains)
+			a.FallbackDomains = &domains
+		}
+		if b.FallbackDomains != nil {
+			domains := NormalizeFallbackDomains(*b.FallbackDomains)
+			b.FallbackDomains = &domains
+		}
+	}
+
+	if o.equateEmpty {
+		a = equateEmptyDeviceSettingsPolicy(a)
+		b = equateEmptyDeviceSettingsPolicy(b)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// comparableDeviceSettingsPolicy returns a copy of policy normalized for a
+// field by field comparison: its fallback domains are normalized with
+// NormalizeFallbackDomains and its empty lists are replaced by nil.
+func comparableDeviceSettingsPolicy(policy DeviceSettingsPolicy) DeviceSettingsPolicy {
+	if policy.FallbackDomains != nil {
+		domains := NormalizeFallbackDomains(*policy.FallbackDomains)
+		policy.FallbackDomains = &domains
+	}
+
+	return equateEmptyDeviceSettingsPolicy(policy)
+}
+
+// equateEmptyDeviceSettingsPolicy returns a copy of policy with empty lists
+// replaced by nil.
+func equateEmptyDeviceSettingsPolicy(policy DeviceSettingsPolicy) DeviceSettingsPolicy {
+	if policy.Include != nil && len(*policy.Include) == 0 {
+		policy.Include = nil
+	}
+
+	if policy.Exclude != nil && len(*policy.Exclude) == 0 {
+		policy.Exclude = nil
+	}
+
+	if policy.FallbackDomains != nil {
+		if len(*policy.FallbackDomains) == 0 {
+			policy.FallbackDomains = nil
+		} else {
+			domains := make([]FallbackDomain, len(*policy.FallbackDomains))
+			for i, d := range *policy.FallbackDomains {
+				if len(d.DNSServer) == 0 {
+					d.DNSServer = nil
+				}
+				domains[i] = d
+			}
+			policy.FallbackDomains = &domains
+		}
+	}
+
+	return policy
+}
+
+// DeviceSettingsPolicyFingerprint returns a stable hex encoded SHA-256 hash
+// of the settings of policy, for detecting whether a policy actually changed.
+//
+// The policy ID is left out so that the same settings have the same
+// fingerprint in every account. Before hashing, the policy is normalized:
+//
+//   - unset (nil) and empty lists are treated the same, as with
+//     DeviceSettingsPolicyEquateEmpty;
+//   - fallback domains are normalized with NormalizeFallbackDomains;
+//   - the split tunnel and fallback domain entries are sorted, as their order
+//     has no effect.
+func DeviceSettingsPolicyFingerprint(policy DeviceSettingsPolicy) string {
+	policy.PolicyID = nil
+
+	if policy.FallbackDomains != nil {
+		domains := NormalizeFallbackDomains(*policy.FallbackDomains)
+		sort.SliceStable(domains, func(i, j int) bool {
+			return domains[i].Suffix < domains[j].Suffix
+		})
+		policy.FallbackDomains = &domains
+	}
+
+	for _, tunnels := range []**[]SplitTunnel{&policy.Include, &policy.Exclude} {
+		if *tunnels == nil {
+			continue
+		}
+		sorted := append([]SplitTunnel(nil), **tunnels...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sorted[i].Address != sorted[j].Address {
+				return sorted[i].Address < sorted[j].Address
+			}
+			return sorted[i].Host < sorted[j].Host
+		})
+		*tunnels = &sorted
+	}
+
+	policy = equateEmptyDeviceSettingsPolicy(policy)
+
+	// Marshalling a struct always writes its fields in the same order, so the
+	// output only depends on the values.
+	b, _ := json.Marshal(policy)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ScheduleDeviceSettingsPolicyUpdate blocks until the requested time and then
+// updates the settings policy. If the context is cancelled before then, no
+// update is made and the context error is returned.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) ScheduleDeviceSettingsPolicyUpdate(ctx context.Context, rc *ResourceContainer, params ScheduleDeviceSettingsPolicyUpdateParams) (DeviceSettingsPolicy, error) {
+	timer := time.NewTimer(time.Until(params.At))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return DeviceSettingsPolicy{}, fmt.Errorf("scheduled device settings policy update aborted: %w", ctx.Err())
+	}
+
+	return api.UpdateDeviceSettingsPolicy(ctx, rc, params.Update)
+}
+
+// AnyDeviceSettingsPolicy reports whether any device settings policy in the
+// account satisfies pred, returning the first policy that does. Pages are
+// fetched one at a time and no further pages are requested once a match is
+// found.
+//
+// API reference: https://api.cloudflare.com/#devices-list-device-settings-policies
+func (api *API) AnyDeviceSettingsPolicy(ctx context.Context, rc *ResourceContainer, pred func(DeviceSettingsPolicy) bool) (bool, *DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return false, nil, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	params := ListDeviceSettingsPoliciesParams{
+		ResultInfo: ResultInfo{Page: 1, PerPage: listDeviceSettingsPoliciesDefaultPageSize},
+	}
+
+	for {
+		r, err := api.listDeviceSettingsPoliciesPage(ctx, rc, params)
+		if err != nil {
+			return false, nil, err
+		}
+
+		for i := range r.Result {
+			if pred(r.Result[i]) {
+				return true, &r.Result[i], nil
+			}
+		}
+
+		if !r.ResultInfo.HasMorePages() {
+			return false, nil, nil
+		}
+		params.ResultInfo = r.ResultInfo.Next()
+	}
+}
+
+// FindDeviceSettingsPolicy returns the first device settings policy that
+// satisfies pred, or nil when none do. Like AnyDeviceSettingsPolicy, it stops
+// paginating as soon as a match is found.
+//
+// API reference: https://api.cloudflare.com/#devices-list-device-settings-policies
+func (api *API) FindDeviceSettingsPolicy(ctx context.Context, rc *ResourceContainer, pred func(DeviceSettingsPolicy) bool) (*DeviceSettingsPolicy, error) {
+	_, policy, err := api.AnyDeviceSettingsPolicy(ctx, rc, pred)
+	return policy, err
+}
+
+// GetDeviceSettingsPolicies fetches the device settings policies with the
+// given IDs concurrently. Duplicate IDs are fetched once. The policies that
+// could be fetched are returned keyed by ID alongside a *DeviceBatchError
+// holding the failures, if any.
+//
+// API reference: https://api.cloudflare.com/#devices-get-device-settings-policy-by-id
+func (api *API) GetDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params GetDeviceSettingsPoliciesParams) (map[string]DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return map[string]DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	var mu sync.Mutex
+	policies := make(map[string]DeviceSettingsPolicy)
+
+	errs := runDeviceBatch(ctx, uniqueDeviceBatchIDs(params.PolicyIDs), params.Concurrency, func(ctx context.Context, policyID string) error {
+		policy, err := api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: &policyID})
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		policies[policyID] = policy
+		mu.Unlock()
+
+		return nil
+	})
+
+	return policies, newDeviceBatchError(errs)
+}
+
+// ErrDeviceSettingsPolicyGatewayNotFound is returned when the gateway
+// referenced by a device settings policy doesn't exist.
+var ErrDeviceSettingsPolicyGatewayNotFound = errors.New("device settings policy references a gateway location that does not exist")
+
+type GetDeviceSettingsPolicyGatewayDetailsParams struct {
+	// PolicyID is the device settings policy to inspect. When empty, the
+	// default policy is used.
+	PolicyID string
+}
+
+// DeviceSettingsPolicyGatewayDetails describes the Gateway location a device
+// settings policy sends DNS queries to.
+type DeviceSettingsPolicyGatewayDetails struct {
+	PolicyID        string
+	GatewayUniqueID string
+	// Location is the Gateway location whose DoH subdomain matches
+	// GatewayUniqueID. It is nil when the policy doesn't reference a
+	// location, in which case the account's default location is used.
+	Location *TeamsLocation
+}
+
+// GetDeviceSettingsPolicyGatewayDetails resolves the gateway unique ID of a
+// device settings policy to the Gateway location it belongs to. An error
+// wrapping ErrDeviceSettingsPolicyGatewayNotFound is returned when no
+// location uses the referenced ID.
+//
+// API reference: https://api.cloudflare.com/#teams-locations-list-teams-locations
+func (api *API) GetDeviceSettingsPolicyGatewayDetails(ctx context.Context, rc *ResourceContainer, params GetDeviceSettingsPolicyGatewayDetailsParams) (DeviceSettingsPolicyGatewayDetails, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsPolicyGatewayDetails{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	var policy DeviceSettingsPolicy
+	var err error
+	if params.PolicyID == "" {
+		policy, err = api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+	} else {
+		policy, err = api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: &params.PolicyID})
+	}
+	if err != nil {
+		return DeviceSettingsPolicyGatewayDetails{}, err
+	}
+
+	details := DeviceSettingsPolicyGatewayDetails{PolicyID: params.PolicyID}
+	if policy.PolicyID != nil {
+		details.PolicyID = *policy.PolicyID
+	}
+
+	if policy.GatewayUniqueID == nil || *policy.GatewayUniqueID == "" {
+		return details, nil
+	}
+	details.GatewayUniqueID = *policy.GatewayUniqueID
+
+	locations, _, err := api.TeamsLocations(ctx, rc.Identifier)
+	if err != nil {
+		return DeviceSettingsPolicyGatewayDetails{}, err
+	}
+
+	for i := range locations {
+		if locations[i].Subdomain == details.GatewayUniqueID {
+			details.Location = &locations[i]
+			return details, nil
+		}
+	}
+
+	return DeviceSettingsPolicyGatewayDetails{}, fmt.Errorf("%w: %q", ErrDeviceSettingsPolicyGatewayNotFound, details.GatewayUniqueID)
+}
+
+// DeviceSettingsPolicyPosition is where CreateDeviceSettingsPolicyAtPosition
+// places a new policy in the evaluation order.
+type DeviceSettingsPolicyPosition string
+
+const (
+	// DeviceSettingsPolicyPositionTop evaluates the new policy before all
+	// existing custom policies.
+	DeviceSettingsPolicyPositionTop DeviceSettingsPolicyPosition = "top"
+	// DeviceSettingsPolicyPositionBottom evaluates the new policy after all
+	// existing custom policies.
+	DeviceSettingsPolicyPositionBottom DeviceSettingsPolicyPosition = "bottom"
+
+	// deviceSettingsPolicyPrecedenceStep is the gap left between the last
+	// policy and a policy added at the bottom, and the precedence of the first
+	// policy of an account.
+	deviceSettingsPolicyPrecedenceStep = 10
+)
+
+// ErrNoDeviceSettingsPolicyPrecedenceAvailable is returned when there is no
+// free precedence above the top device settings policy.
+var ErrNoDeviceSettingsPolicyPrecedenceAvailable = errors.New("no precedence available above the top device settings policy")
+
+type CreateDeviceSettingsPolicyAtPositionParams struct {
+	// Policy is the policy to create. Its Precedence is ignored.
+	Policy   CreateDeviceSettingsPolicyParams
+	Position DeviceSettingsPolicyPosition
+}
+
+// CreateDeviceSettingsPolicyAtPosition creates a device settings policy at
+// the top or bottom of the evaluation order, computing its precedence from the
+// existing custom policies.
+//
+// The new policy always gets a precedence strictly lower (top) or higher
+// (bottom) than every existing policy, so it never ties with one. At the
+// bottom it is placed 10 after the highest precedence; at the top it is
+// placed halfway between 0 and the lowest precedence. Existing policies are
+// never renumbered: when the top policy already has precedence 1,
+// ErrNoDeviceSettingsPolicyPrecedenceAvailable is returned. The precedences
+// are read before the policy is created, so concurrent changes may still
+// collide and are reported by the API.
+//
+// API reference: https://api.cloudflare.com/#devices-create-device-settings-policy
+func (api *API) CreateDeviceSettingsPolicyAtPosition(ctx context.Context, rc *ResourceContainer, params CreateDeviceSettingsPolicyAtPositionParams) (DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if params.Position != DeviceSettingsPolicyPositionTop && params.Position != DeviceSettingsPolicyPositionBottom {
+		return DeviceSettingsPolicy{}, fmt.Errorf("invalid device settings policy position: %q", params.Position)
+	}
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return DeviceSettingsPolicy{}, err
+	}
+
+	lowest, highest := 0, 0
+	for _, policy := range policies {
+		if policy.Default || policy.Precedence == nil {
+			continue
+		}
+		if lowest == 0 || *policy.Precedence < lowest {
+			lowest = *policy.Precedence
+		}
+		if *policy.Precedence > highest {
+			highest = *policy.Precedence
+		}
+	}
+
+	precedence := highest + deviceSettingsPolicyPrecedenceStep
+	if params.Position == DeviceSettingsPolicyPositionTop && lowest != 0 {
+		if lowest <= 1 {
+			return DeviceSettingsPolicy{}, ErrNoDeviceSettingsPolicyPrecedenceAvailable
+		}
+		precedence = lowest / 2
+	}
+
+	params.Policy.Precedence = &precedence
+
+	return api.CreateDeviceSettingsPolicy(ctx, rc, params.Policy)
+}
+
+// PolicyPrecedenceIssueType identifies the kind of problem found in the
+// precedences of a set of device settings policies.
+type PolicyPrecedenceIssueType string
+
+const (
+	// PolicyPrecedenceIssueDuplicate reports custom policies sharing a
+	// precedence, whose relative evaluation order is undefined.
+	PolicyPrecedenceIssueDuplicate PolicyPrecedenceIssueType = "duplicate"
+	// PolicyPrecedenceIssueMissing reports a custom policy without a
+	// precedence, which is evaluated after every other custom policy.
+	PolicyPrecedenceIssueMissing PolicyPrecedenceIssueType = "missing"
+	// PolicyPrecedenceIssueInvalid reports a precedence below 1.
+	PolicyPrecedenceIssueInvalid PolicyPrecedenceIssueType = "invalid"
+	// PolicyPrecedenceIssueDefaultPosition reports a default policy that
+	// isn't evaluated last, or more than one default policy.
+	PolicyPrecedenceIssueDefaultPosition PolicyPrecedenceIssueType = "default_position"
+)
+
+// PolicyPrecedenceIssue is a problem reported by
+// ValidateDeviceSettingsPolicyPrecedences.
+type PolicyPrecedenceIssue struct {
+	Type PolicyPrecedenceIssueType
+	// Precedence is the precedence concerned, or 0 for a missing one.
+	Precedence int
+	// Policies are the policies concerned, in the order they were given.
+	Policies []DeviceSettingsPolicy
+	Message  string
+}
+
+// ValidateDeviceSettingsPolicyPrecedences checks the precedences of the
+// policies of an account, as returned by ListDeviceSettingsPolicies, and
+// reports duplicates, missing and invalid precedences, and a default policy
+// that has a precedence at or before a custom policy. Consecutive
+// precedences are valid and aren't reported. The issues are ordered by
+// precedence.
+func ValidateDeviceSettingsPolicyPrecedences(policies []DeviceSettingsPolicy) []PolicyPrecedenceIssue {
+	var issues []PolicyPrecedenceIssue
+	var defaults []DeviceSettingsPolicy
+	byPrecedence := make(map[int][]DeviceSettingsPolicy)
+	highest := 0
+	for _, policy := range policies {
+		if policy.Default {
+			defaults = append(defaults, policy)
+			continue
+		}
+
+		if policy.Precedence == nil {
+			issues = append(issues, PolicyPrecedenceIssue{
+				Type:     PolicyPrecedenceIssueMissing,
+				Policies: []DeviceSettingsPolicy{policy},
+				Message:  fmt.Sprintf("policy %s has no precedence", deviceSettingsPolicyLabel(policy)),
+			})
+			continue
+		}
+
+		byPrecedence[*policy.Precedence] = append(byPrecedence[*policy.Precedence], policy)
+		if *policy.Precedence > highest {
+			highest = *policy.Precedence
+		}
+	}
+
+	precedences := make([]int, 0, len(byPrecedence))
+	for precedence := range byPrecedence {
+		precedences = append(precedences, precedence)
+	}
+	sort.Ints(precedences)
+
+	for _, precedence := range precedences {
+		group := byPrecedence[precedence]
+		labels := make([]string, 0, len(group))
+		for _, policy := range group {
+			labels = append(labels, deviceSettingsPolicyLabel(policy))
+		}
+
+		if precedence < 1 {
+			issues = append(issues, PolicyPrecedenceIssue{
+				Type:       PolicyPrecedenceIssueInvalid,
+				Precedence: precedence,
+				Policies:   group,
+				Message:    fmt.Sprintf("policy %s has invalid precedence %d", strings.Join(labels, ", "), precedence),
+			})
+		}
+
+		if len(group) > 1 {
+			issues = append(issues, PolicyPrecedenceIssue{
+				Type:       PolicyPrecedenceIssueDuplicate,
+				Precedence: precedence,
+				Policies:   group,
+				Message:    fmt.Sprintf("policies %s share precedence %d", strings.Join(labels, ", "), precedence),
+			})
+		}
+	}
+
+	if len(defaults) > 1 {
+		issues = append(issues, PolicyPrecedenceIssue{
+			Type:     PolicyPrecedenceIssueDefaultPosition,
+			Policies: defaults,
+			Message:  fmt.Sprintf("found %d default policies", len(defaults)),
+		})
+	}
+	for _, policy := range defaults {
+		if policy.Precedence != nil && *policy.Precedence != 0 && *policy.Precedence <= highest {
+			issues = append(issues, PolicyPrecedenceIssue{
+				Type:       PolicyPrecedenceIssueDefaultPosition,
+				Precedence: *policy.Precedence,
+				Policies:   []DeviceSettingsPolicy{policy},
+				Message:    fmt.Sprintf("default policy has precedence %d, at or before custom policies up to precedence %d", *policy.Precedence, highest),
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Precedence < issues[j].Precedence
+	})
+
+	return issues
+}
+
+// deviceSettingsPolicyIdentityFields are the fields describing a policy itself
+// rather than device settings.
+var deviceSettingsPolicyIdentityFields = map[string]bool{
+	"policy_id":   true,
+	"name":        true,
+	"match":       true,
+	"precedence":  true,
+	"enabled":     true,
+	"default":     true,
+	"description": true,
+}
+
+type GetDeviceSettingsEffectiveParams struct {
+	// PolicyID is the custom policy to inspect. When empty, the default
+	// policy is used.
+	PolicyID string
+}
+
+// DeviceSettingsEffective is a device settings policy along with the account
+// level device settings that override some of its fields.
+type DeviceSettingsEffective struct {
+	Policy DeviceSettingsPolicy
+	// Account holds the account level device settings.
+	Account TeamsDeviceSettings
+	// Overrides maps the JSON name of policy fields that have no effect
+	// because of an account level setting to the reason why.
+	Overrides map[string]string
+}
+
+// GetDeviceSettingsEffective returns a device settings policy along with the
+// policy fields that have no effect because of account level device
+// settings.
+//
+// The only documented account level override covered is the proxy service
+// mode, which has no effect unless the Gateway proxy is enabled in the
+// account device settings. Fields a policy leaves unset are reported as
+// unset: custom policies don't inherit them from the default policy, the
+// client applies its own defaults.
+//
+// API reference: https://api.cloudflare.com/#devices-get-device-settings-policy-by-id
+func (api *API) GetDeviceSettingsEffective(ctx context.Context, rc *ResourceContainer, params GetDeviceSettingsEffectiveParams) (DeviceSettingsEffective, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsEffective{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	var policy DeviceSettingsPolicy
+	var err error
+	if params.PolicyID != "" {
+		policy, err = api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: &params.PolicyID})
+	} else {
+		policy, err = api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+	}
+	if err != nil {
+		return DeviceSettingsEffective{}, err
+	}
+
+	account, err := api.TeamsAccountDeviceConfiguration(ctx, rc.Identifier)
+	if err != nil {
+		return DeviceSettingsEffective{}, err
+	}
+
+	effective := DeviceSettingsEffective{
+		Policy:    policy,
+		Account:   account,
+		Overrides: make(map[string]string),
+	}
+
+	if policy.ServiceModeV2 != nil && policy.ServiceModeV2.Mode == proxy && !account.GatewayProxyEnabled {
+		effective.Overrides["service_mode_v2"] = "proxy mode requires the Gateway proxy to be enabled in the account device settings"
+	}
+
+	return effective, nil
+}
+
+type DeviceSettingsPoliciesUsingPostureRuleParams struct {
+	// RuleID is the device posture rule to look for.
+	RuleID string
+}
+
+// DeviceSettingsPoliciesUsingPostureRule returns the custom device settings
+// policies whose match expression references a device posture rule through
+// the `device_posture.checks.passed` selector. Expressions that can't be
+// parsed are searched for the rule ID as plain text.
+//
+// API reference: https://api.cloudflare.com/#devices-list-device-settings-policies
+func (api *API) DeviceSettingsPoliciesUsingPostureRule(ctx context.Context, rc *ResourceContainer, params DeviceSettingsPoliciesUsingPostureRuleParams) ([]DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return []DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	ruleID := params.RuleID
+	if ruleID == "" {
+		return []DeviceSettingsPolicy{}, ErrMissingResourceIdentifier
+	}
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return []DeviceSettingsPolicy{}, err
+	}
+
+	var using []DeviceSettingsPolicy
+	for _, policy := range policies {
+		if policy.Match == nil || *policy.Match == "" {
+			continue
+		}
+
+		node, err := parseDeviceMatch(*policy.Match)
+		if err != nil {
+			if strings.Contains(*policy.Match, ruleID) {
+				using = append(using, policy)
+			}
+			continue
+		}
+
+		for _, id := range deviceMatchFieldValues(node, "device_posture.checks.passed") {
+			if id == ruleID {
+				using = append(using, policy)
+				break
+			}
+		}
+	}
+
+	return using, nil
+}
+
+type CreateDeviceSettingsPoliciesParams struct {
+	Policies []CreateDeviceSettingsPolicyParams
+	// Defaults holds the values of the fields left unset (nil) in a policy
+	// of Policies. A field set in the policy always wins over its default,
+	// and a field unset in both is left unset. Name, Match and Precedence
+	// identify each policy and are never taken from Defaults.
+	// AllowEmptyMatch applies to every policy when set in Defaults.
+	Defaults CreateDeviceSettingsPolicyParams
+	// Concurrency is the maximum number of policies created at once.
+	// Defaults to 4.
+	Concurrency int
+}
+
+// CreateDeviceSettingsPolicies creates several device settings policies,
+// filling in their unset fields from Defaults, and returns them in the order
+// of Policies. Policies that fail to be created are left as zero values in
+// the result and reported in a *DeviceBatchError keyed by their index in
+// Policies.
+//
+// API reference: https://api.cloudflare.com/#devices-create-device-settings-policy
+func (api *API) CreateDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params CreateDeviceSettingsPoliciesParams) ([]DeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return []DeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	keys := make([]string, len(params.Policies))
+	for i := range params.Policies {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	created := make([]DeviceSettingsPolicy, len(params.Policies))
+	errs := runDeviceBatch(ctx, keys, params.Concurrency, func(ctx context.Context, key string) error {
+		i, _ := strconv.Atoi(key)
+
+		policy, err := api.CreateDeviceSettingsPolicy(ctx, rc, mergeDeviceSettingsPolicyDefaults(params.Policies[i], params.Defaults))
+		if err != nil {
+			return err
+		}
+
+		// Each index is written by a single call.
+		created[i] = policy
+		return nil
+	})
+
+	return created, newDeviceBatchError(errs)
+}
+
+// deviceSettingsPolicyDefaultsIgnoredFields are the fields identifying a
+// custom policy, which CreateDeviceSettingsPolicies never takes from
+// Defaults: policies sharing them would collide or shadow each other.
+var deviceSettingsPolicyDefaultsIgnoredFields = map[string]bool{
+	"name":       true,
+	"match":      true,
+	"precedence": true,
+}
+
+// mergeDeviceSettingsPolicyDefaults returns policy with its nil fields set to
+// a copy of those of defaults, except for the identity fields.
+func mergeDeviceSettingsPolicyDefaults(policy, defaults CreateDeviceSettingsPolicyParams) CreateDeviceSettingsPolicyParams {
+	v := reflect.ValueOf(&policy).Elem()
+	d := reflect.ValueOf(defaults)
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if deviceSettingsPolicyDefaultsIgnoredFields[name] {
+			continue
+		}
+
+		field, value := v.Field(i), d.Field(i)
+		if field.Kind() != reflect.Ptr || !field.IsNil() || value.IsNil() {
+			continue
+		}
+
+		// Every policy gets its own copy, so that changing one of the
+		// created policies never changes the others or Defaults.
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(value.Elem())
+		field.Set(copied)
+	}
+
+	policy.AllowEmptyMatch = policy.AllowEmptyMatch || defaults.AllowEmptyMatch
+
+	return policy
+}
+
+// UpsertDeviceSettingsPolicyByName makes sure a custom device settings policy
+// named like params exists with the settings of params. When no policy has
+// that name, one is created. Otherwise the policy is updated with
+// MinimalDeviceSettingsPolicyPatch, so only the fields that differ are sent
+// and the fields unset in params that can be reset are reset to their server
+// default; no request is sent when nothing differs. The resulting policy is
+// returned along with whether it was created.
+//
+// Names are compared exactly. An error wrapping
+// ErrAmbiguousDeviceSettingsPolicyName is returned when several policies
+// have the name, and ErrMissingDeviceSettingsPolicyName when params has
+// none. The policies are listed before being changed, so a policy created
+// concurrently with the same name is not detected.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) UpsertDeviceSettingsPolicyByName(ctx context.Context, rc *ResourceContainer, params CreateDeviceSettingsPolicyParams) (DeviceSettingsPolicy, bool, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsPolicy{}, false, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if params.Name == nil || *params.Name == "" {
+		return DeviceSettingsPolicy{}, false, ErrMissingDeviceSettingsPolicyName
+	}
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return DeviceSettingsPolicy{}, false, err
+	}
+
+	var matches []DeviceSettingsPolicy
+	for _, policy := range policies {
+		if !policy.Default && policy.Name != nil && *policy.Name == *params.Name {
+			matches = append(matches, policy)
+		}
+	}
+
+	if len(matches) > 1 {
+		return DeviceSettingsPolicy{}, false, fmt.Errorf("%w: %d policies are named %q", ErrAmbiguousDeviceSettingsPolicyName, len(matches), *params.Name)
+	}
+
+	if len(matches) == 0 {
+		policy, err := api.CreateDeviceSettingsPolicy(ctx, rc, params)
+		if err != nil {
+			return DeviceSettingsPolicy{}, false, err
+		}
+		return policy, true, nil
+	}
+
+	current := matches[0]
+	var desired DeviceSettingsPolicy
+	b, _ := json.Marshal(params)
+	_ = json.Unmarshal(b, &desired)
+
+	patch := MinimalDeviceSettingsPolicyPatch(current, desired)
+	if isNoopDeviceSettingsPolicyPatch(current, patch) {
+		return current, false, nil
+	}
+	patch.AllowEmptyMatch = params.AllowEmptyMatch
+
+	policy, err := api.UpdateDeviceSettingsPolicy(ctx, rc, patch)
+	if err != nil {
+		return DeviceSettingsPolicy{}, false, err
+	}
+
+	return policy, false, nil
+}
+
+// isNoopDeviceSettingsPolicyPatch reports whether patch, as returned by
+// MinimalDeviceSettingsPolicyPatch, leaves current unchanged. ExcludeOfficeIps
+// is always set by the patch, so it only counts when it differs.
+func isNoopDeviceSettingsPolicyPatch(current DeviceSettingsPolicy, patch UpdateDeviceSettingsPolicyParams) bool {
+	if patch.ExcludeOfficeIps != nil && (current.ExcludeOfficeIps == nil || *current.ExcludeOfficeIps != *patch.ExcludeOfficeIps) {
+		return false
+	}
+
+	patch.PolicyID = nil
+	patch.ExcludeOfficeIps = nil
+
+	return reflect.DeepEqual(patch, UpdateDeviceSettingsPolicyParams{})
+}
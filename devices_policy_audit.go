@@ -0,0 +1,331 @@
+package cloudflare
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// PolicyAuditSeverity is the severity of a PolicyAuditFinding.
+type PolicyAuditSeverity string
+
+const (
+	PolicyAuditSeverityInfo    PolicyAuditSeverity = "info"
+	PolicyAuditSeverityWarning PolicyAuditSeverity = "warning"
+	PolicyAuditSeverityError   PolicyAuditSeverity = "error"
+)
+
+// PolicyAuditFinding is a problem reported by AuditDeviceSettingsPolicy.
+type PolicyAuditFinding struct {
+	// Check is the name of the check that reported the finding.
+	Check    string
+	Severity PolicyAuditSeverity
+	// Field is the JSON name of the policy field the finding is about.
+	Field       string
+	Message     string
+	Remediation string
+}
+
+// DefaultProxyReservedPorts are the local ports the proxy service mode should
+// not listen on, because WARP or the services it relies on use them:
+//
+//   - 53 and 853 for DNS and DNS over TLS;
+//   - 80 and 443 for captive portal detection and HTTPS connections;
+//   - 500, 1701, 2408 and 4500 for the WireGuard tunnel.
+var DefaultProxyReservedPorts = []int{53, 80, 443, 500, 853, 1701, 2408, 4500}
+
+// PolicyAuditOption configures AuditDeviceSettingsPolicy.
+type PolicyAuditOption func(*policyAuditOptions)
+
+type policyAuditOptions struct {
+	reservedProxyPorts []int
+	strict             bool
+}
+
+// PolicyAuditReservedProxyPorts replaces DefaultProxyReservedPorts as the set
+// of ports reported when used as the proxy port.
+func PolicyAuditReservedProxyPorts(ports ...int) PolicyAuditOption {
+	return func(o *policyAuditOptions) {
+		o.reservedProxyPorts = ports
+	}
+}
+
+// PolicyAuditStrict reports findings that are warnings by default, such as a
+// reserved proxy port, as errors.
+func PolicyAuditStrict() PolicyAuditOption {
+	return func(o *policyAuditOptions) {
+		o.strict = true
+	}
+}
+
+// deviceSettingsPolicyAuditCheck inspects a single aspect of a policy and
+// returns a finding, or nil when the policy passes.
+type deviceSettingsPolicyAuditCheck func(policy DeviceSettingsPolicy, o policyAuditOptions) *PolicyAuditFinding
+
+// deviceSettingsPolicyAuditChecks are the checks run by
+// AuditDeviceSettingsPolicy, in order.
+var deviceSettingsPolicyAuditChecks = []deviceSettingsPolicyAuditCheck{
+	auditDeviceSettingsPolicyMatch,
+	auditDeviceSettingsPolicyProxyPort,
+	auditDeviceSettingsPolicyProxyReservedPort,
+	auditDeviceSettingsPolicySupportURL,
+	auditDeviceSettingsPolicyCaptivePortal,
+	auditDeviceSettingsPolicyAutoConnect,
+}
+
+// AuditDeviceSettingsPolicy runs a set of best practice checks against a
+// device settings policy and returns the findings. No requests are made. An
+// empty result means no problems were found.
+func AuditDeviceSettingsPolicy(policy DeviceSettingsPolicy, opts ...PolicyAuditOption) []PolicyAuditFinding {
+	o := policyAuditOptions{reservedProxyPorts: DefaultProxyReservedPorts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var findings []PolicyAuditFinding
+	for _, check := range deviceSettingsPolicyAuditChecks {
+		if finding := check(policy, o); finding != nil {
+			if o.strict && finding.Severity == PolicyAuditSeverityWarning {
+				finding.Severity = PolicyAuditSeverityError
+			}
+			findings = append(findings, *finding)
+		}
+	}
+
+	return findings
+}
+
+// auditDeviceSettingsPolicyMatch reports custom policies without a match
+// expression, which never apply to any device.
+func auditDeviceSettingsPolicyMatch(policy DeviceSettingsPolicy, _ policyAuditOptions) *PolicyAuditFinding {
+	if policy.Default || (policy.Match != nil && *policy.Match != "") {
+		return nil
+	}
+
+	return &PolicyAuditFinding{
+		Check:       "empty_match",
+		Severity:    PolicyAuditSeverityError,
+		Field:       "match",
+		Message:     "custom policy has no match expression and applies to no device",
+		Remediation: "set a match expression selecting the devices the policy is for",
+	}
+}
+
+// auditDeviceSettingsPolicyProxyPort reports proxy mode without a port.
+func auditDeviceSettingsPolicyProxyPort(policy DeviceSettingsPolicy, _ policyAuditOptions) *PolicyAuditFinding {
+	if policy.ServiceModeV2 == nil || policy.ServiceModeV2.Mode != proxy || policy.ServiceModeV2.Port != 0 {
+		return nil
+	}
+
+	return &PolicyAuditFinding{
+		Check:       "proxy_without_port",
+		Severity:    PolicyAuditSeverityError,
+		Field:       "service_mode_v2",
+		Message:     "proxy service mode is used without a proxy port",
+		Remediation: "set service_mode_v2.port to the local port the proxy listens on",
+	}
+}
+
+// auditDeviceSettingsPolicyProxyReservedPort reports a proxy port that
+// collides with a port WARP uses itself.
+func auditDeviceSettingsPolicyProxyReservedPort(policy DeviceSettingsPolicy, o policyAuditOptions) *PolicyAuditFinding {
+	if policy.ServiceModeV2 == nil || policy.ServiceModeV2.Mode != proxy || policy.ServiceModeV2.Port == 0 {
+		return nil
+	}
+
+	for _, port := range o.reservedProxyPorts {
+		if policy.ServiceModeV2.Port != port {
+			continue
+		}
+
+		return &PolicyAuditFinding{
+			Check:       "proxy_reserved_port",
+			Severity:    PolicyAuditSeverityWarning,
+			Field:       "service_mode_v2",
+			Message:     fmt.Sprintf("proxy port %d is reserved and may break connectivity", port),
+			Remediation: "set service_mode_v2.port to an unused port above 1024, e.g. 40000",
+		}
+	}
+
+	return nil
+}
+
+// auditDeviceSettingsPolicySupportURL reports policies without a support URL
+// shown to users in the WARP client.
+func auditDeviceSettingsPolicySupportURL(policy DeviceSettingsPolicy, _ policyAuditOptions) *PolicyAuditFinding {
+	if policy.SupportURL != nil && *policy.SupportURL != "" {
+		return nil
+	}
+
+	return &PolicyAuditFinding{
+		Check:       "missing_support_url",
+		Severity:    PolicyAuditSeverityWarning,
+		Field:       "support_url",
+		Message:     "no support URL is configured for users reporting WARP issues",
+		Remediation: "set support_url to a mailto: or https: link of your help desk",
+	}
+}
+
+// auditDeviceSettingsPolicyCaptivePortal reports locked switches without a
+// captive portal timeout, leaving users unable to sign in to captive portals.
+func auditDeviceSettingsPolicyCaptivePortal(policy DeviceSettingsPolicy, _ policyAuditOptions) *PolicyAuditFinding {
+	if policy.SwitchLocked == nil || !*policy.SwitchLocked {
+		return nil
+	}
+
+	if policy.CaptivePortal != nil && *policy.CaptivePortal > 0 {
+		return nil
+	}
+
+	return &PolicyAuditFinding{
+		Check:       "switch_locked_without_captive_portal",
+		Severity:    PolicyAuditSeverityWarning,
+		Field:       "captive_portal",
+		Message:     "users can't turn WARP off and captive portal detection is disabled, so they can't sign in to captive portals",
+		Remediation: "set captive_portal to the number of seconds WARP may be paused for captive portal sign in",
+	}
+}
+
+// auditDeviceSettingsPolicyAutoConnect reports an auto connect timeout on a
+// locked switch, where users can't disconnect in the first place.
+func auditDeviceSettingsPolicyAutoConnect(policy DeviceSettingsPolicy, _ policyAuditOptions) *PolicyAuditFinding {
+	if policy.SwitchLocked == nil || !*policy.SwitchLocked || policy.AutoConnect == nil || *policy.AutoConnect == 0 {
+		return nil
+	}
+
+	return &PolicyAuditFinding{
+		Check:       "auto_connect_with_switch_locked",
+		Severity:    PolicyAuditSeverityInfo,
+		Field:       "auto_connect",
+		Message:     "auto connect has no effect because users can't turn WARP off",
+		Remediation: "set auto_connect to 0 or unlock the switch",
+	}
+}
+
+// ValidateFallbackVsSplitTunnel cross-checks the fallback domains of policy
+// against its split tunnel entries and reports suffixes whose names are
+// resolved by the fallback DNS servers although their traffic is tunneled,
+// which makes resolution depend on which DNS server answers first:
+//
+//   - in include mode, include hosts at or under a fallback suffix, and
+//     wildcard include hosts covering a suffix;
+//   - reverse DNS suffixes (in-addr.arpa and ip6.arpa) covering tunneled
+//     addresses: in include mode those overlapping an include CIDR, and in
+//     exclude mode those not entirely covered by an exclude CIDR.
+//
+// In exclude mode, hosts under a fallback suffix are tunneled by design and
+// are not reported. The findings are warnings, or errors with
+// PolicyAuditStrict. Nothing is reported when the policy lacks its fallback
+// domains or split tunnel lists.
+func ValidateFallbackVsSplitTunnel(policy DeviceSettingsPolicy, opts ...PolicyAuditOption) []PolicyAuditFinding {
+	o := policyAuditOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mode := policy.SplitTunnelMode()
+	if policy.FallbackDomains == nil || mode == "" {
+		return nil
+	}
+
+	var entries []SplitTunnel
+	if mode == "include" {
+		entries = *policy.Include
+	} else {
+		entries = *policy.Exclude
+	}
+
+	var findings []PolicyAuditFinding
+	report := func(domain FallbackDomain, message string) {
+		finding := PolicyAuditFinding{
+			Check:       "fallback_domain_tunneled",
+			Severity:    PolicyAuditSeverityWarning,
+			Field:       "fallback_domains",
+			Message:     message,
+			Remediation: fmt.Sprintf("remove %q from the fallback domains, or stop tunneling the names and addresses it covers", domain.Suffix),
+		}
+		if o.strict {
+			finding.Severity = PolicyAuditSeverityError
+		}
+		findings = append(findings, finding)
+	}
+
+	for _, domain := range *policy.FallbackDomains {
+		suffix := normalizeSplitTunnelHost(domain.Suffix)
+		if suffix == "" {
+			continue
+		}
+		reverse, isReverse := reverseDNSZonePrefix(suffix)
+
+		covered := false
+		for _, entry := range entries {
+			if host := normalizeSplitTunnelHost(entry.Host); host != "" && mode == "include" {
+				if host == suffix || strings.HasSuffix(host, "."+suffix) || hostContains(host, suffix) {
+					report(domain, fmt.Sprintf("fallback domain %q is resolved locally but include host %q is tunneled", domain.Suffix, entry.Host))
+				}
+			}
+
+			if entry.Address == "" || !isReverse {
+				continue
+			}
+			prefix, err := parseSplitTunnelAddress(entry.Address)
+			if err != nil {
+				continue
+			}
+			if mode == "include" && reverse.Overlaps(prefix) {
+				report(domain, fmt.Sprintf("reverse DNS fallback domain %q is resolved locally but include address %q is tunneled", domain.Suffix, entry.Address))
+			}
+			if mode == "exclude" && prefixContains(prefix, reverse) {
+				covered = true
+			}
+		}
+
+		if mode == "exclude" && isReverse && !covered {
+			report(domain, fmt.Sprintf("reverse DNS fallback domain %q is resolved locally but addresses in %s are tunneled", domain.Suffix, reverse))
+		}
+	}
+
+	return findings
+}
+
+// reverseDNSZonePrefix returns the addresses covered by a reverse DNS zone
+// such as "10.in-addr.arpa" or "8.b.d.0.1.0.0.2.ip6.arpa".
+func reverseDNSZonePrefix(zone string) (netip.Prefix, bool) {
+	ipv6 := strings.HasSuffix(zone, ".ip6.arpa")
+	if !ipv6 && !strings.HasSuffix(zone, ".in-addr.arpa") {
+		return netip.Prefix{}, false
+	}
+
+	labels := strings.Split(zone[:strings.LastIndex(zone, ".")], ".")
+	labels = labels[:len(labels)-1]
+
+	// IPv4 zones have one decimal label per byte, IPv6 zones one hexadecimal
+	// label per nibble, least significant first.
+	base, bits, max := 10, 8, 4
+	if ipv6 {
+		base, bits, max = 16, 4, 32
+	}
+	if len(labels) > max {
+		return netip.Prefix{}, false
+	}
+
+	var addr [16]byte
+	for i := range labels {
+		v, err := strconv.ParseUint(labels[len(labels)-1-i], base, bits)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		if ipv6 {
+			addr[i/2] |= byte(v) << (4 * (1 - i%2))
+		} else {
+			addr[i] = byte(v)
+		}
+	}
+
+	if ipv6 {
+		return netip.PrefixFrom(netip.AddrFrom16(addr), 4*len(labels)), true
+	}
+
+	return netip.PrefixFrom(netip.AddrFrom4([4]byte{addr[0], addr[1], addr[2], addr[3]}), 8*len(labels)), true
+}
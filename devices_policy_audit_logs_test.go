@@ -0,0 +1,67 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListDevicePolicyAuditLogs(t *testing.T) {
+	setup()
+	defer teardown()
+
+	since := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/audit_logs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		assert.Equal(t, "2023-06-01T00:00:00Z", r.URL.Query().Get("since"))
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "log-1",
+					"action": {"result": true, "type": "update"},
+					"actor": {"email": "admin@example.com", "id": "actor-1", "ip": "198.51.100.1", "type": "user"},
+					"resource": {"id": "policy-1", "type": "device_settings_policy"},
+					"oldValueJson": {"captive_portal": 180},
+					"newValueJson": {"captive_portal": 60},
+					"when": "2023-06-02T10:00:00Z"
+				},
+				{
+					"id": "log-2",
+					"action": {"result": true, "type": "update"},
+					"actor": {"email": "admin@example.com", "id": "actor-1", "ip": "198.51.100.1", "type": "user"},
+					"resource": {"id": "zone-1", "type": "zone"},
+					"when": "2023-06-02T11:00:00Z"
+				}
+			],
+			"result_info": {"page": 1, "per_page": 100, "count": 2}
+		}`)
+	})
+
+	actual, err := client.ListDevicePolicyAuditLogs(context.Background(), AccountIdentifier(testAccountID), ListDevicePolicyAuditLogsParams{Since: since})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []DevicePolicyAuditLog{{
+			ID:           "log-1",
+			When:         time.Date(2023, 6, 2, 10, 0, 0, 0, time.UTC),
+			ActorID:      "actor-1",
+			ActorEmail:   "admin@example.com",
+			ActorIP:      "198.51.100.1",
+			ActorType:    "user",
+			Action:       "update",
+			Succeeded:    true,
+			PolicyID:     "policy-1",
+			ResourceType: "device_settings_policy",
+			Before:       map[string]interface{}{"captive_portal": float64(180)},
+			After:        map[string]interface{}{"captive_portal": float64(60)},
+		}}, actual)
+	}
+}
@@ -0,0 +1,156 @@
+package cloudflare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditDeviceSettingsPolicy(t *testing.T) {
+	policy := DeviceSettingsPolicy{
+		Name:          StringPtr("locked"),
+		ServiceModeV2: &ServiceModeV2{Mode: proxy},
+		SwitchLocked:  BoolPtr(true),
+		AutoConnect:   IntPtr(300),
+	}
+
+	var checks []string
+	for _, finding := range AuditDeviceSettingsPolicy(policy) {
+		checks = append(checks, finding.Check)
+	}
+
+	assert.Equal(t, []string{
+		"empty_match",
+		"proxy_without_port",
+		"missing_support_url",
+		"switch_locked_without_captive_portal",
+		"auto_connect_with_switch_locked",
+	}, checks)
+
+	assert.Empty(t, AuditDeviceSettingsPolicy(DeviceSettingsPolicy{
+		Default:       true,
+		ServiceModeV2: &ServiceModeV2{Mode: proxy, Port: 3000},
+		SupportURL:    StringPtr("https://support.example.com"),
+		SwitchLocked:  BoolPtr(true),
+		CaptivePortal: IntPtr(180),
+		AutoConnect:   IntPtr(0),
+	}))
+}
+
+func TestAuditDeviceSettingsPolicyChecks(t *testing.T) {
+	testCases := map[string]struct {
+		check  deviceSettingsPolicyAuditCheck
+		fails  DeviceSettingsPolicy
+		passes DeviceSettingsPolicy
+	}{
+		"match": {
+			check:  auditDeviceSettingsPolicyMatch,
+			fails:  DeviceSettingsPolicy{Match: StringPtr("")},
+			passes: DeviceSettingsPolicy{Match: StringPtr(`os.name == "mac"`)},
+		},
+		"proxy port": {
+			check:  auditDeviceSettingsPolicyProxyPort,
+			fails:  DeviceSettingsPolicy{ServiceModeV2: &ServiceModeV2{Mode: proxy}},
+			passes: DeviceSettingsPolicy{ServiceModeV2: &ServiceModeV2{Mode: warp}},
+		},
+		"proxy reserved port": {
+			check:  auditDeviceSettingsPolicyProxyReservedPort,
+			fails:  DeviceSettingsPolicy{ServiceModeV2: &ServiceModeV2{Mode: proxy, Port: 443}},
+			passes: DeviceSettingsPolicy{ServiceModeV2: &ServiceModeV2{Mode: proxy, Port: 40000}},
+		},
+		"support url": {
+			check:  auditDeviceSettingsPolicySupportURL,
+			fails:  DeviceSettingsPolicy{SupportURL: StringPtr("")},
+			passes: DeviceSettingsPolicy{SupportURL: StringPtr("mailto:it@example.com")},
+		},
+		"captive portal": {
+			check:  auditDeviceSettingsPolicyCaptivePortal,
+			fails:  DeviceSettingsPolicy{SwitchLocked: BoolPtr(true), CaptivePortal: IntPtr(0)},
+			passes: DeviceSettingsPolicy{SwitchLocked: BoolPtr(false), CaptivePortal: IntPtr(0)},
+		},
+		"auto connect": {
+			check:  auditDeviceSettingsPolicyAutoConnect,
+			fails:  DeviceSettingsPolicy{SwitchLocked: BoolPtr(true), AutoConnect: IntPtr(60)},
+			passes: DeviceSettingsPolicy{SwitchLocked: BoolPtr(false), AutoConnect: IntPtr(60)},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			o := policyAuditOptions{reservedProxyPorts: DefaultProxyReservedPorts}
+			if finding := tc.check(tc.fails, o); assert.NotNil(t, finding) {
+				assert.NotEmpty(t, finding.Remediation)
+			}
+			assert.Nil(t, tc.check(tc.passes, o))
+		})
+	}
+}
+
+func TestAuditDeviceSettingsPolicyReservedProxyPorts(t *testing.T) {
+	policy := DeviceSettingsPolicy{
+		Match:         StringPtr(`os.name == "mac"`),
+		SupportURL:    StringPtr("mailto:it@example.com"),
+		ServiceModeV2: &ServiceModeV2{Mode: proxy, Port: 8080},
+	}
+
+	assert.Empty(t, AuditDeviceSettingsPolicy(policy))
+
+	findings := AuditDeviceSettingsPolicy(policy, PolicyAuditReservedProxyPorts(8080))
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, "proxy_reserved_port", findings[0].Check)
+		assert.Equal(t, PolicyAuditSeverityWarning, findings[0].Severity)
+	}
+
+	findings = AuditDeviceSettingsPolicy(policy, PolicyAuditReservedProxyPorts(8080), PolicyAuditStrict())
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, PolicyAuditSeverityError, findings[0].Severity)
+	}
+}
+
+func TestValidateFallbackVsSplitTunnel(t *testing.T) {
+	include := DeviceSettingsPolicy{
+		Include: &[]SplitTunnel{{Host: "app.corp.example.com"}, {Host: "*.example.net"}, {Address: "10.1.0.0/16"}, {Host: "other.example.org"}},
+		FallbackDomains: &[]FallbackDomain{
+			{Suffix: "corp.example.com"},
+			{Suffix: "intranet.example.net."},
+			{Suffix: "10.in-addr.arpa"},
+			{Suffix: "168.192.in-addr.arpa"},
+			{Suffix: "example.org"},
+		},
+	}
+
+	findings := ValidateFallbackVsSplitTunnel(include)
+	messages := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		assert.Equal(t, "fallback_domain_tunneled", finding.Check)
+		assert.Equal(t, PolicyAuditSeverityWarning, finding.Severity)
+		messages = append(messages, finding.Message)
+	}
+	assert.Equal(t, []string{
+		`fallback domain "corp.example.com" is resolved locally but include host "app.corp.example.com" is tunneled`,
+		`fallback domain "intranet.example.net." is resolved locally but include host "*.example.net" is tunneled`,
+		`reverse DNS fallback domain "10.in-addr.arpa" is resolved locally but include address "10.1.0.0/16" is tunneled`,
+		`fallback domain "example.org" is resolved locally but include host "other.example.org" is tunneled`,
+	}, messages)
+
+	findings = ValidateFallbackVsSplitTunnel(include, PolicyAuditStrict())
+	assert.Equal(t, PolicyAuditSeverityError, findings[0].Severity)
+
+	exclude := DeviceSettingsPolicy{
+		Exclude: &[]SplitTunnel{{Address: "10.0.0.0/8"}, {Host: "app.corp.example.com"}},
+		FallbackDomains: &[]FallbackDomain{
+			{Suffix: "corp.example.com"},
+			{Suffix: "2.10.in-addr.arpa"},
+			{Suffix: "16.172.in-addr.arpa"},
+			{Suffix: "d.f.ip6.arpa"},
+		},
+	}
+
+	findings = ValidateFallbackVsSplitTunnel(exclude)
+	if assert.Len(t, findings, 2) {
+		assert.Equal(t, `reverse DNS fallback domain "16.172.in-addr.arpa" is resolved locally but addresses in 172.16.0.0/16 are tunneled`, findings[0].Message)
+		assert.Equal(t, `reverse DNS fallback domain "d.f.ip6.arpa" is resolved locally but addresses in fd00::/8 are tunneled`, findings[1].Message)
+	}
+
+	assert.Empty(t, ValidateFallbackVsSplitTunnel(DeviceSettingsPolicy{FallbackDomains: include.FallbackDomains}))
+}
@@ -0,0 +1,115 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+)
+
+// DNSFallbackRoute is a domain suffix resolved outside of Gateway.
+type DNSFallbackRoute struct {
+	Suffix      string
+	Description string
+	// Resolvers are the DNS servers queried for the suffix. When empty, the
+	// resolver configured on the device's network is used.
+	Resolvers []string
+}
+
+// EffectiveDNSConfig is a consolidated view of how devices assigned to a
+// device settings policy resolve DNS.
+type EffectiveDNSConfig struct {
+	// PolicyID is the policy described, empty for the default policy.
+	PolicyID string
+	// FallbackRoutes are the suffixes resolved by local DNS servers instead
+	// of Gateway, in the order of the fallback domain list.
+	FallbackRoutes []DNSFallbackRoute
+	// SplitTunnelMode is "include" when only the entries of SplitTunnels go
+	// through WARP, and "exclude" when everything but them does.
+	SplitTunnelMode string
+	SplitTunnels    []SplitTunnel
+	// GatewayUniqueID is the DoH subdomain of the Gateway location queries
+	// are sent to. When empty, the default location of the account is used.
+	GatewayUniqueID string
+	// DoHEndpoint is the DNS over HTTPS URL of GatewayUniqueID.
+	DoHEndpoint string
+	// Location is the Gateway location of GatewayUniqueID, or nil when the
+	// policy doesn't reference one or the location doesn't exist.
+	Location *TeamsLocation
+}
+
+type GetEffectiveDNSConfigParams struct {
+	// PolicyID is the device settings policy to inspect. When empty, the
+	// default policy is used.
+	PolicyID string
+}
+
+// GetEffectiveDNSConfig combines the fallback domains, split tunnel entries
+// and Gateway location of a device settings policy into a single view of how
+// its devices resolve DNS, to help debugging split DNS issues. It makes no
+// changes.
+//
+// API reference: https://api.cloudflare.com/#devices-get-device-settings-policy-by-id
+func (api *API) GetEffectiveDNSConfig(ctx context.Context, rc *ResourceContainer, params GetEffectiveDNSConfigParams) (EffectiveDNSConfig, error) {
+	if rc.Level != AccountRouteLevel {
+		return EffectiveDNSConfig{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	var policy DeviceSettingsPolicy
+	var err error
+	if params.PolicyID == "" {
+		policy, err = api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+	} else {
+		policy, err = api.GetDeviceSettingsPolicy(ctx, rc, GetDeviceSettingsPolicyParams{PolicyID: &params.PolicyID})
+	}
+	if err != nil {
+		return EffectiveDNSConfig{}, err
+	}
+
+	config := EffectiveDNSConfig{PolicyID: params.PolicyID}
+
+	domains, err := api.listFallbackDomains(ctx, rc.Identifier, params.PolicyID)
+	if err != nil {
+		return EffectiveDNSConfig{}, err
+	}
+	for _, domain := range domains {
+		config.FallbackRoutes = append(config.FallbackRoutes, DNSFallbackRoute{
+			Suffix:      domain.Suffix,
+			Description: domain.Description,
+			Resolvers:   domain.DNSServer,
+		})
+	}
+
+	include, err := api.listSplitTunnels(ctx, rc.Identifier, params.PolicyID, "include")
+	if err != nil {
+		return EffectiveDNSConfig{}, err
+	}
+	if len(include) > 0 {
+		config.SplitTunnelMode = "include"
+		config.SplitTunnels = include
+	} else {
+		config.SplitTunnelMode = "exclude"
+		config.SplitTunnels, err = api.listSplitTunnels(ctx, rc.Identifier, params.PolicyID, "exclude")
+		if err != nil {
+			return EffectiveDNSConfig{}, err
+		}
+	}
+
+	if policy.GatewayUniqueID == nil || *policy.GatewayUniqueID == "" {
+		return config, nil
+	}
+	config.GatewayUniqueID = *policy.GatewayUniqueID
+	config.DoHEndpoint = fmt.Sprintf("https://%s.cloudflare-gateway.com/dns-query", config.GatewayUniqueID)
+
+	locations, _, err := api.TeamsLocations(ctx, rc.Identifier)
+	if err != nil {
+		return EffectiveDNSConfig{}, err
+	}
+
+	for i := range locations {
+		if locations[i].Subdomain == config.GatewayUniqueID {
+			config.Location = &locations[i]
+			break
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,53 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEffectiveDNSConfig(t *testing.T) {
+	setup()
+	defer teardown()
+
+	base := "/accounts/" + testAccountID + "/devices/policy/" + deviceSettingsPolicyID
+	respond := func(result string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s}`, result)
+		}
+	}
+
+	mux.HandleFunc(base, respond(nonDefaultDeviceSettingsPolicyJson))
+	mux.HandleFunc(base+"/fallback_domains", respond(`[
+		{"suffix": "corp.example.com", "description": "intranet", "dns_server": ["10.0.0.53"]},
+		{"suffix": "local"}
+	]`))
+	mux.HandleFunc(base+"/include", respond(`[]`))
+	mux.HandleFunc(base+"/exclude", respond(`[{"address": "10.0.0.0/8"}]`))
+	mux.HandleFunc("/accounts/"+testAccountID+"/gateway/locations", respond(`[
+		{"id": "location", "name": "office", "doh_subdomain": "t1235"}
+	]`))
+
+	config, err := client.GetEffectiveDNSConfig(context.Background(), AccountIdentifier(testAccountID), GetEffectiveDNSConfigParams{
+		PolicyID: deviceSettingsPolicyID,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []DNSFallbackRoute{
+		{Suffix: "corp.example.com", Description: "intranet", Resolvers: []string{"10.0.0.53"}},
+		{Suffix: "local"},
+	}, config.FallbackRoutes)
+	assert.Equal(t, "exclude", config.SplitTunnelMode)
+	assert.Equal(t, []SplitTunnel{{Address: "10.0.0.0/8"}}, config.SplitTunnels)
+	assert.Equal(t, "t1235", config.GatewayUniqueID)
+	assert.Equal(t, "https://t1235.cloudflare-gateway.com/dns-query", config.DoHEndpoint)
+	if assert.NotNil(t, config.Location) {
+		assert.Equal(t, "office", config.Location.Name)
+	}
+}
@@ -0,0 +1,120 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hclResourceNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// DeviceSettingsPolicyToHCL renders a device settings policy as a Terraform
+// `cloudflare_device_settings_policy` resource block. This is a best-effort
+// helper for migrating policies created elsewhere into Terraform.
+//
+// The policy fields map onto the resource arguments of the same name, with
+// ServiceModeV2 flattened into `service_mode_v2_mode` and
+// `service_mode_v2_port`. Unset fields are omitted. The following are not
+// rendered and need to be added by hand:
+//
+//   - `account_id`, which is not part of the policy.
+//   - Include, Exclude and FallbackDomains, which are managed with the
+//     separate `cloudflare_split_tunnel` and `cloudflare_fallback_domain`
+//     resources.
+//   - GatewayUniqueID, LANAllowMinutes and LANAllowSubnetSize, which have no
+//     equivalent argument.
+//
+// The resource is labelled after the policy name, or "default" for the
+// default policy.
+func DeviceSettingsPolicyToHCL(policy DeviceSettingsPolicy) (string, error) {
+	label := "default"
+	if !policy.Default {
+		if policy.Name == nil || *policy.Name == "" {
+			return "", errors.New("device settings policy name is required for non-default policies")
+		}
+		label = hclResourceName(*policy.Name)
+	}
+
+	var attrs [][2]string
+	addString := func(key string, v *string) {
+		if v != nil {
+			attrs = append(attrs, [2]string{key, hclString(*v)})
+		}
+	}
+	addBool := func(key string, v *bool) {
+		if v != nil {
+			attrs = append(attrs, [2]string{key, strconv.FormatBool(*v)})
+		}
+	}
+	addInt := func(key string, v *int) {
+		if v != nil {
+			attrs = append(attrs, [2]string{key, strconv.Itoa(*v)})
+		}
+	}
+
+	name := policy.Name
+	if policy.Default && name == nil {
+		name = StringPtr("Default")
+	}
+	addString("name", name)
+	addString("description", policy.Description)
+	if policy.Default {
+		attrs = append(attrs, [2]string{"default", "true"})
+	}
+	addBool("enabled", policy.Enabled)
+	if !policy.Default {
+		addString("match", policy.Match)
+		addInt("precedence", policy.Precedence)
+	}
+	addBool("allow_mode_switch", policy.AllowModeSwitch)
+	addBool("allow_updates", policy.AllowUpdates)
+	addBool("allowed_to_leave", policy.AllowedToLeave)
+	addInt("auto_connect", policy.AutoConnect)
+	addInt("captive_portal", policy.CaptivePortal)
+	addBool("disable_auto_fallback", policy.DisableAutoFallback)
+	addBool("exclude_office_ips", policy.ExcludeOfficeIps)
+	if policy.ServiceModeV2 != nil {
+		if policy.ServiceModeV2.Mode != "" {
+			attrs = append(attrs, [2]string{"service_mode_v2_mode", hclString(string(policy.ServiceModeV2.Mode))})
+		}
+		if policy.ServiceModeV2.Port != 0 {
+			attrs = append(attrs, [2]string{"service_mode_v2_port", strconv.Itoa(policy.ServiceModeV2.Port)})
+		}
+	}
+	addString("support_url", policy.SupportURL)
+	addBool("switch_locked", policy.SwitchLocked)
+
+	width := 0
+	for _, attr := range attrs {
+		if len(attr[0]) > width {
+			width = len(attr[0])
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "resource \"cloudflare_device_settings_policy\" %s {\n", hclString(label))
+	for _, attr := range attrs {
+		fmt.Fprintf(&b, "  %-*s = %s\n", width, attr[0], attr[1])
+	}
+	b.WriteString("}\n")
+
+	return b.String(), nil
+}
+
+// hclResourceName converts name into a valid Terraform resource name.
+func hclResourceName(name string) string {
+	label := strings.Trim(hclResourceNameInvalidChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if label == "" || (label[0] >= '0' && label[0] <= '9') || label[0] == '-' {
+		label = "policy_" + label
+	}
+	return label
+}
+
+// hclString quotes s as an HCL string literal, escaping template sequences.
+func hclString(s string) string {
+	s = strings.ReplaceAll(s, "${", "$${")
+	s = strings.ReplaceAll(s, "%{", "%%{")
+	return strconv.Quote(s)
+}
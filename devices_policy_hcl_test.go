@@ -0,0 +1,66 @@
+package cloudflare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceSettingsPolicyToHCL(t *testing.T) {
+	actual, err := DeviceSettingsPolicyToHCL(nonDefaultDeviceSettingsPolicy)
+
+	want := `resource "cloudflare_device_settings_policy" "test" {
+  name                  = "test"
+  description           = "Test Description"
+  enabled               = true
+  match                 = "identity.email == \"test@example.com\""
+  precedence            = 10
+  allow_mode_switch     = false
+  allow_updates         = false
+  allowed_to_leave      = true
+  auto_connect          = 0
+  captive_portal        = 180
+  disable_auto_fallback = false
+  exclude_office_ips    = true
+  service_mode_v2_mode  = "warp"
+  support_url           = ""
+  switch_locked         = false
+}
+`
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, actual)
+	}
+}
+
+func TestDeviceSettingsPolicyToHCLDefault(t *testing.T) {
+	actual, err := DeviceSettingsPolicyToHCL(DeviceSettingsPolicy{
+		Default:       true,
+		ServiceModeV2: &ServiceModeV2{Mode: "proxy", Port: 3128},
+		SupportURL:    StringPtr("https://example.com/${path}"),
+	})
+
+	want := `resource "cloudflare_device_settings_policy" "default" {
+  name                 = "Default"
+  default              = true
+  service_mode_v2_mode = "proxy"
+  service_mode_v2_port = 3128
+  support_url          = "https://example.com/$${path}"
+}
+`
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, actual)
+	}
+}
+
+func TestDeviceSettingsPolicyToHCLMissingName(t *testing.T) {
+	_, err := DeviceSettingsPolicyToHCL(DeviceSettingsPolicy{})
+	assert.Error(t, err)
+}
+
+func TestHCLResourceName(t *testing.T) {
+	assert.Equal(t, "engineering_laptops", hclResourceName("Engineering Laptops"))
+	assert.Equal(t, "policy_1st", hclResourceName("1st"))
+	assert.Equal(t, "policy_", hclResourceName("!!!"))
+}
@@ -0,0 +1,110 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDeviceSettingsPolicyChangeNotConfirmed is returned by account wide
+// device settings policy changes called without Confirm.
+var ErrDeviceSettingsPolicyChangeNotConfirmed = errors.New("account wide device settings policy change requires confirmation")
+
+// DeviceSettingsPolicyStates records whether each custom device settings
+// policy was enabled, keyed by policy ID.
+type DeviceSettingsPolicyStates map[string]bool
+
+type DisableAllCustomDeviceSettingsPoliciesParams struct {
+	// Confirm must be true for any policy to be disabled.
+	Confirm bool
+	// Concurrency is the maximum number of policies updated at once.
+	// Defaults to 4.
+	Concurrency int
+}
+
+// DisableAllCustomDeviceSettingsPolicies disables every custom device settings
+// policy, so that the default policy applies to all devices, and returns the
+// state the policies were in before. Pass the states to
+// RestoreDeviceSettingsPolicyStates to undo the change.
+//
+// ErrDeviceSettingsPolicyChangeNotConfirmed is returned unless Confirm is set.
+// Policies that fail to be disabled are reported in a *DeviceBatchError; the
+// returned states are complete even then, so the policies that were disabled
+// can still be restored.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) DisableAllCustomDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params DisableAllCustomDeviceSettingsPoliciesParams) (DeviceSettingsPolicyStates, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsPolicyStates{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if !params.Confirm {
+		return DeviceSettingsPolicyStates{}, ErrDeviceSettingsPolicyChangeNotConfirmed
+	}
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return DeviceSettingsPolicyStates{}, err
+	}
+
+	states := make(DeviceSettingsPolicyStates)
+	var enabled []string
+	for _, policy := range policies {
+		if policy.Default || policy.PolicyID == nil || *policy.PolicyID == "" {
+			continue
+		}
+
+		// Policies are enabled unless stated otherwise.
+		isEnabled := policy.Enabled == nil || *policy.Enabled
+		states[*policy.PolicyID] = isEnabled
+		if isEnabled {
+			enabled = append(enabled, *policy.PolicyID)
+		}
+	}
+
+	errs := api.setDeviceSettingsPoliciesEnabled(ctx, rc, enabled, false, params.Concurrency)
+
+	return states, newDeviceBatchError(errs)
+}
+
+type RestoreDeviceSettingsPolicyStatesParams struct {
+	// States are the states returned by
+	// DisableAllCustomDeviceSettingsPolicies.
+	States DeviceSettingsPolicyStates
+	// Concurrency is the maximum number of policies updated at once.
+	// Defaults to 4.
+	Concurrency int
+}
+
+// RestoreDeviceSettingsPolicyStates enables the policies recorded as enabled
+// in States again. Policies that were already disabled are left untouched.
+// Failures are reported in a *DeviceBatchError.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) RestoreDeviceSettingsPolicyStates(ctx context.Context, rc *ResourceContainer, params RestoreDeviceSettingsPolicyStatesParams) error {
+	if rc.Level != AccountRouteLevel {
+		return fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	var enabled []string
+	for policyID, isEnabled := range params.States {
+		if isEnabled {
+			enabled = append(enabled, policyID)
+		}
+	}
+
+	return newDeviceBatchError(api.setDeviceSettingsPoliciesEnabled(ctx, rc, enabled, true, params.Concurrency))
+}
+
+// setDeviceSettingsPoliciesEnabled sets Enabled on the given policies and
+// returns the errors keyed by policy ID.
+func (api *API) setDeviceSettingsPoliciesEnabled(ctx context.Context, rc *ResourceContainer, policyIDs []string, enabled bool, concurrency int) map[string]error {
+	return runDeviceBatch(ctx, uniqueDeviceBatchIDs(policyIDs), concurrency, func(ctx context.Context, policyID string) error {
+		body := struct {
+			Enabled bool `json:"enabled"`
+		}{enabled}
+
+		_, err := api.patchDeviceSettingsPolicy(ctx, rc, policyID, body)
+		return err
+	})
+}
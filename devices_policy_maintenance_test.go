@@ -0,0 +1,67 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableAllCustomDeviceSettingsPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	bodies := make(map[string]string)
+	patch := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = string(body)
+		mu.Unlock()
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": null, "messages": null, "result": {}}`)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"policy_id": "enabled-policy", "default": false, "enabled": true},
+				{"policy_id": "disabled-policy", "default": false, "enabled": false},
+				{"default": true, "enabled": true}
+			],
+			"result_info": {"count": 3, "page": 1, "per_page": 20, "total_count": 3}
+		}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/enabled-policy", patch)
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/disabled-policy", patch)
+
+	_, err := client.DisableAllCustomDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), DisableAllCustomDeviceSettingsPoliciesParams{})
+	assert.ErrorIs(t, err, ErrDeviceSettingsPolicyChangeNotConfirmed)
+	assert.Empty(t, bodies)
+
+	states, err := client.DisableAllCustomDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), DisableAllCustomDeviceSettingsPoliciesParams{Confirm: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DeviceSettingsPolicyStates{"enabled-policy": true, "disabled-policy": false}, states)
+		assert.Equal(t, map[string]string{
+			"/accounts/" + testAccountID + "/devices/policy/enabled-policy": `{"enabled":false}`,
+		}, bodies)
+	}
+
+	bodies = make(map[string]string)
+	err = client.RestoreDeviceSettingsPolicyStates(context.Background(), AccountIdentifier(testAccountID), RestoreDeviceSettingsPolicyStatesParams{States: states})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			"/accounts/" + testAccountID + "/devices/policy/enabled-policy": `{"enabled":true}`,
+		}, bodies)
+	}
+}
@@ -0,0 +1,1059 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Device settings policies select devices with a match expression written in
+// the Wirefilter syntax, e.g.
+//
+//	identity.email == "alice@example.com" and os.name == "mac"
+//	any(identity.groups.name[*] in {"engineering" "it"})
+//
+// This file contains a client side parser and evaluator for the subset of the
+// syntax used by device settings policies. It supports the following
+// selectors:
+//
+//	identity.email                  string
+//	identity.groups.id              array of strings
+//	identity.groups.name            array of strings
+//	identity.groups.email           array of strings
+//	os.name                         string
+//	os.version                      string
+//	network                         string
+//	device_posture.checks.passed    array of strings (posture rule IDs)
+//
+// The comparison operators `==` (`eq`), `!=` (`ne`), `in`, `matches` (`~`)
+// and `contains`, the logical operators `and` (`&&`), `or` (`||`), `xor`
+// (`^^`) and `not` (`!`), parentheses, and the `any()` and `all()` functions
+// over array selectors (`field[*]`) are supported.
+//
+// Evaluation is a best-effort simulation and is not the engine Cloudflare uses
+// to assign policies. In particular, lists (`$name`), numeric comparisons,
+// SAML attributes and any selector not listed above are rejected, and regular
+// expressions are evaluated with Go's RE2 engine.
+
+// deviceMatchFieldKind is the type of a match expression selector.
+type deviceMatchFieldKind int
+
+const (
+	deviceMatchString deviceMatchFieldKind = iota
+	deviceMatchArray
+)
+
+var deviceMatchFields = map[string]deviceMatchFieldKind{
+	"identity.email":               deviceMatchString,
+	"identity.groups.id":           deviceMatchArray,
+	"identity.groups.name":         deviceMatchArray,
+	"identity.groups.email":        deviceMatchArray,
+	"os.name":                      deviceMatchString,
+	"os.version":                   deviceMatchString,
+	"network":                      deviceMatchString,
+	"device_posture.checks.passed": deviceMatchArray,
+}
+
+// DeviceMatchGroup is an identity provider group a user belongs to.
+type DeviceMatchGroup struct {
+	ID    string
+	Name  string
+	Email string
+}
+
+// DeviceMatchAttributes describes a device and its user for evaluating
+// device settings policy match expressions.
+type DeviceMatchAttributes struct {
+	Email  string
+	Groups []DeviceMatchGroup
+	// OSName is the platform as used by the `os.name` selector, e.g.
+	// "windows" or "mac".
+	OSName    string
+	OSVersion string
+	// Network is the name of the managed network the device is connected
+	// to, if any.
+	Network string
+	// PostureResults holds the outcome of each device posture rule keyed by
+	// rule ID. Rules missing from the map are treated as not passed.
+	PostureResults map[string]bool
+}
+
+// values returns the values of a selector for the attributes.
+func (a DeviceMatchAttributes) values(field string) []string {
+	switch field {
+	case "identity.email":
+		return []string{a.Email}
+	case "identity.groups.id", "identity.groups.name", "identity.groups.email":
+		values := make([]string, 0, len(a.Groups))
+		for _, g := range a.Groups {
+			switch field {
+			case "identity.groups.id":
+				values = append(values, g.ID)
+			case "identity.groups.name":
+				values = append(values, g.Name)
+			default:
+				values = append(values, g.Email)
+			}
+		}
+		return values
+	case "os.name":
+		return []string{a.OSName}
+	case "os.version":
+		return []string{a.OSVersion}
+	case "network":
+		return []string{a.Network}
+	case "device_posture.checks.passed":
+		passed := make([]string, 0, len(a.PostureResults))
+		for id, ok := range a.PostureResults {
+			if ok {
+				passed = append(passed, id)
+			}
+		}
+		sort.Strings(passed)
+		return passed
+	}
+
+	return nil
+}
+
+// DeviceMatchSyntaxError is returned for match expressions that cannot be
+// parsed.
+type DeviceMatchSyntaxError struct {
+	Expression string
+	Offset     int
+	Message    string
+}
+
+func (e *DeviceMatchSyntaxError) Error() string {
+	return fmt.Sprintf("invalid device match expression at offset %d: %s", e.Offset, e.Message)
+}
+
+// ValidateDeviceMatch checks that a match expression is syntactically valid
+// and only uses supported selectors. It makes no requests.
+func ValidateDeviceMatch(expression string) error {
+	_, err := parseDeviceMatch(expression)
+	return err
+}
+
+// ErrDeviceMatchDanglingReference is matched by errors reporting match
+// expressions that reference resources which don't exist.
+var ErrDeviceMatchDanglingReference = errors.New("device match expression references a resource that does not exist")
+
+// DeviceMatchReferenceError lists the device posture rules referenced by a
+// match expression that don't exist in the account. It matches
+// ErrDeviceMatchDanglingReference with errors.Is.
+type DeviceMatchReferenceError struct {
+	PostureRuleIDs []string
+}
+
+func (e *DeviceMatchReferenceError) Error() string {
+	return fmt.Sprintf("%s: device posture rule(s) %s", ErrDeviceMatchDanglingReference, strings.Join(e.PostureRuleIDs, ", "))
+}
+
+func (e *DeviceMatchReferenceError) Is(target error) bool {
+	return target == ErrDeviceMatchDanglingReference
+}
+
+type ValidateDeviceMatchReferencesParams struct {
+	Expression string
+}
+
+// ValidateDeviceMatchReferences validates a match expression with
+// ValidateDeviceMatch, then checks that the device posture rules it
+// references through `device_posture.checks.passed` exist. Missing rules are
+// reported as a *DeviceMatchReferenceError. Use ValidateDeviceMatch to only
+// check the syntax, without making requests.
+//
+// Group selectors refer to identity provider groups, which can't be looked
+// up through the API and are therefore not checked.
+//
+// API reference: https://api.cloudflare.com/#device-posture-rules-list-device-posture-rules
+func (api *API) ValidateDeviceMatchReferences(ctx context.Context, rc *ResourceContainer, params ValidateDeviceMatchReferencesParams) error {
+	if rc.Level != AccountRouteLevel {
+		return fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if err := ValidateDeviceMatch(params.Expression); err != nil {
+		return err
+	}
+
+	// A valid expression always parses.
+	node, _ := parseDeviceMatch(params.Expression)
+	referenced := deviceMatchFieldValues(node, "device_posture.checks.passed")
+	if len(referenced) == 0 {
+		return nil
+	}
+
+	rules, _, err := api.DevicePostureRules(ctx, rc.Identifier)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		existing[rule.ID] = true
+	}
+
+	var missing []string
+	for _, id := range uniqueDeviceBatchIDs(referenced) {
+		if !existing[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &DeviceMatchReferenceError{PostureRuleIDs: missing}
+	}
+
+	return nil
+}
+
+// ErrMissingDeviceMatchGroupID is returned when setting a group match
+// without a group ID.
+var ErrMissingDeviceMatchGroupID = errors.New("device settings policy match requires a group ID")
+
+type SetDeviceSettingsPolicyMatchGroupParams struct {
+	PolicyID string
+	// GroupID is the identity provider group the policy applies to, as it
+	// appears in identity.groups.id.
+	GroupID string
+}
+
+// SetDeviceSettingsPolicyMatchGroup replaces the match expression of a device
+// settings policy with one selecting the members of a single identity
+// provider group:
+//
+//	any(identity.groups.id[*] in {"<group_id>"})
+//
+// The default policy has no match expression and can't be changed this way.
+// Identity provider groups can't be looked up through the API, so the group
+// is not checked for existence; a typo results in a policy that matches no
+// one.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) SetDeviceSettingsPolicyMatchGroup(ctx context.Context, rc *ResourceContainer, params SetDeviceSettingsPolicyMatchGroupParams) (DeviceSettingsPolicy, error) {
+	if params.PolicyID == "" {
+		return DeviceSettingsPolicy{}, errors.New("device settings policy ID cannot be empty")
+	}
+
+	if strings.TrimSpace(params.GroupID) == "" {
+		return DeviceSettingsPolicy{}, ErrMissingDeviceMatchGroupID
+	}
+
+	match := fmt.Sprintf("any(identity.groups.id[*] in {%s})", quoteDeviceMatchString(params.GroupID))
+	if err := ValidateDeviceMatch(match); err != nil {
+		return DeviceSettingsPolicy{}, err
+	}
+
+	return api.UpdateDeviceSettingsPolicy(ctx, rc, UpdateDeviceSettingsPolicyParams{
+		PolicyID: StringPtr(params.PolicyID),
+		Match:    StringPtr(match),
+	})
+}
+
+// quoteDeviceMatchString returns s as a string literal of a match
+// expression.
+func quoteDeviceMatchString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ErrEmptyDeviceMatchValues is returned when a match expression is built
+// from an empty list of values.
+var ErrEmptyDeviceMatchValues = errors.New("device match expression requires at least one value")
+
+// DeviceMatchBuilder builds device settings policy match expressions with
+// correctly escaped values, e.g.
+//
+//	match, err := DeviceMatch().
+//		GroupIDIn([]string{"engineering"}).
+//		EmailNotIn([]string{"ceo@example.com"}).
+//		Build()
+//
+// Conditions added to a builder are combined with `and`. Use Or and Not to
+// combine builders; each operand is wrapped in parentheses so that the
+// expression reads as it was built regardless of operator precedence. The
+// first error encountered is reported by Build.
+type DeviceMatchBuilder struct {
+	terms []string
+	err   error
+}
+
+// DeviceMatch returns an empty match expression builder.
+func DeviceMatch() *DeviceMatchBuilder {
+	return &DeviceMatchBuilder{}
+}
+
+// EmailIn matches users whose email is one of emails.
+func (b *DeviceMatchBuilder) EmailIn(emails []string) *DeviceMatchBuilder {
+	return b.addSet("identity.email in %s", emails)
+}
+
+// EmailNotIn matches users whose email is none of emails, e.g. to exclude
+// a few users from a policy.
+func (b *DeviceMatchBuilder) EmailNotIn(emails []string) *DeviceMatchBuilder {
+	return b.addSet("not(identity.email in %s)", emails)
+}
+
+// GroupIDIn matches users belonging to any of the identity provider groups.
+func (b *DeviceMatchBuilder) GroupIDIn(groupIDs []string) *DeviceMatchBuilder {
+	return b.addSet("any(identity.groups.id[*] in %s)", groupIDs)
+}
+
+// GroupIDNotIn matches users belonging to none of the identity provider
+// groups.
+func (b *DeviceMatchBuilder) GroupIDNotIn(groupIDs []string) *DeviceMatchBuilder {
+	return b.addSet("not(any(identity.groups.id[*] in %s))", groupIDs)
+}
+
+// Or matches when any of the alternatives does.
+func (b *DeviceMatchBuilder) Or(alternatives ...*DeviceMatchBuilder) *DeviceMatchBuilder {
+	terms := make([]string, 0, len(alternatives))
+	for _, alternative := range alternatives {
+		term, err := alternative.expression()
+		if err != nil {
+			return b.fail(err)
+		}
+		terms = append(terms, "("+term+")")
+	}
+
+	if len(terms) == 0 {
+		return b.fail(ErrEmptyDeviceMatchValues)
+	}
+
+	return b.add("(" + strings.Join(terms, " or ") + ")")
+}
+
+// Not matches when other doesn't.
+func (b *DeviceMatchBuilder) Not(other *DeviceMatchBuilder) *DeviceMatchBuilder {
+	term, err := other.expression()
+	if err != nil {
+		return b.fail(err)
+	}
+
+	return b.add("not(" + term + ")")
+}
+
+// Build returns the match expression. The expression is checked with
+// ValidateDeviceMatch before being returned.
+func (b *DeviceMatchBuilder) Build() (string, error) {
+	match, err := b.expression()
+	if err != nil {
+		return "", err
+	}
+
+	if err := ValidateDeviceMatch(match); err != nil {
+		return "", err
+	}
+
+	return match, nil
+}
+
+func (b *DeviceMatchBuilder) expression() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+
+	if len(b.terms) == 0 {
+		return "", errors.New("device match expression has no conditions")
+	}
+
+	return strings.Join(b.terms, " and "), nil
+}
+
+func (b *DeviceMatchBuilder) addSet(format string, values []string) *DeviceMatchBuilder {
+	if len(values) == 0 {
+		return b.fail(ErrEmptyDeviceMatchValues)
+	}
+
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, quoteDeviceMatchString(v))
+	}
+
+	return b.add(fmt.Sprintf(format, "{"+strings.Join(quoted, " ")+"}"))
+}
+
+func (b *DeviceMatchBuilder) add(term string) *DeviceMatchBuilder {
+	b.terms = append(b.terms, term)
+	return b
+}
+
+func (b *DeviceMatchBuilder) fail(err error) *DeviceMatchBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// EvaluateDeviceSettingsPolicyMatch returns the policy that would apply to a
+// device with the given attributes. Enabled, non-default policies are
+// considered in ascending precedence order and the first whose match
+// expression evaluates to true wins. If none match, the default policy is
+// returned, or nil when policies doesn't include it.
+func EvaluateDeviceSettingsPolicyMatch(policies []DeviceSettingsPolicy, attrs DeviceMatchAttributes) (*DeviceSettingsPolicy, error) {
+	candidates := make([]int, 0, len(policies))
+	defaultIndex := -1
+	for i, policy := range policies {
+		if policy.Default {
+			defaultIndex = i
+			continue
+		}
+		if policy.Enabled != nil && !*policy.Enabled {
+			continue
+		}
+		if policy.Match == nil || *policy.Match == "" {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return deviceSettingsPolicyRank(policies[candidates[i]]) < deviceSettingsPolicyRank(policies[candidates[j]])
+	})
+
+	for _, i := range candidates {
+		node, err := parseDeviceMatch(*policies[i].Match)
+		if err != nil {
+			return nil, fmt.Errorf("device settings policy %s: %w", deviceSettingsPolicyLabel(policies[i]), err)
+		}
+
+		matched, err := node.eval(attrs)
+		if err != nil {
+			return nil, fmt.Errorf("device settings policy %s: %w", deviceSettingsPolicyLabel(policies[i]), err)
+		}
+
+		if matched {
+			return &policies[i], nil
+		}
+	}
+
+	if defaultIndex >= 0 {
+		return &policies[defaultIndex], nil
+	}
+
+	return nil, nil
+}
+
+// ShadowingDeviceSettingsPolicy is a custom device settings policy whose
+// match expression selects every device, so that the default policy, and
+// the policies evaluated after it, never apply.
+type ShadowingDeviceSettingsPolicy struct {
+	Policy DeviceSettingsPolicy
+	// Reason explains why the match expression selects every device.
+	Reason string
+	// Shadowed are the enabled custom policies evaluated after Policy,
+	// which can no longer match any device.
+	Shadowed []DeviceSettingsPolicy
+}
+
+type FindShadowingDeviceSettingsPoliciesParams struct{}
+
+// FindShadowingDeviceSettingsPolicies reports the enabled custom device
+// settings policies of an account whose match expression is so broad that it
+// selects every device, such as `identity.email matches ".*"`, in the order
+// they are evaluated. Such a policy acts as the catch-all instead of the
+// default policy, usually by mistake.
+//
+// The analysis is static and conservative: an expression is only reported
+// when one of its comparisons is true for any non-empty value, such as a
+// regular expression matching any string, `contains ""` or `!= ""`, and the
+// expression holds whenever that comparison does. Expressions that cannot
+// be parsed are skipped; use ValidateDeviceMatch to find those.
+//
+// API reference: https://api.cloudflare.com/#devices-list-device-settings-policies
+func (api *API) FindShadowingDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params FindShadowingDeviceSettingsPoliciesParams) ([]ShadowingDeviceSettingsPolicy, error) {
+	if rc.Level != AccountRouteLevel {
+		return []ShadowingDeviceSettingsPolicy{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return []ShadowingDeviceSettingsPolicy{}, err
+	}
+
+	var candidates []DeviceSettingsPolicy
+	for _, policy := range policies {
+		if (policy.Enabled != nil && !*policy.Enabled) || policy.Match == nil || *policy.Match == "" {
+			continue
+		}
+		candidates = append(candidates, policy)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return deviceSettingsPolicyRank(candidates[i]) < deviceSettingsPolicyRank(candidates[j])
+	})
+
+	var shadowing []ShadowingDeviceSettingsPolicy
+	for i, policy := range candidates {
+		node, err := parseDeviceMatch(*policy.Match)
+		if err != nil {
+			continue
+		}
+
+		reason := deviceMatchCatchAll(node)
+		if reason == "" {
+			continue
+		}
+
+		shadowing = append(shadowing, ShadowingDeviceSettingsPolicy{
+			Policy:   policy,
+			Reason:   reason,
+			Shadowed: candidates[i+1:],
+		})
+	}
+
+	return shadowing, nil
+}
+
+// deviceMatchCatchAllProbes are the values a regular expression is tried on
+// to decide whether it matches any string.
+var deviceMatchCatchAllProbes = []string{"a", "alice@example.com", "Engineering IT", "10.0.0.1", "\n"}
+
+// deviceMatchCatchAll returns why node matches every device that has a value
+// for the selectors it uses, or an empty string when it might not.
+func deviceMatchCatchAll(node deviceMatchNode) string {
+	switch n := node.(type) {
+	case deviceMatchLogical:
+		left, right := deviceMatchCatchAll(n.left), deviceMatchCatchAll(n.right)
+		switch {
+		case n.op == "or" && left != "":
+			return left
+		case n.op == "or" && right != "":
+			return right
+		case n.op == "and" && left != "" && right != "":
+			return left + ", and " + right
+		}
+	case deviceMatchComparison:
+		selector := n.field
+		if n.reduce != "" {
+			selector = fmt.Sprintf("%s(%s[*] ...)", n.reduce, n.field)
+		}
+
+		switch n.op {
+		case "matches":
+			for _, probe := range deviceMatchCatchAllProbes {
+				if !n.re.MatchString(probe) {
+					return ""
+				}
+			}
+			return fmt.Sprintf("%s matches %s, which matches any value", selector, quoteDeviceMatchString(n.values[0]))
+		case "contains":
+			if n.values[0] == "" {
+				return fmt.Sprintf("%s contains \"\", which matches any value", selector)
+			}
+		case "!=":
+			if n.values[0] == "" {
+				return fmt.Sprintf("%s != \"\", which matches any value", selector)
+			}
+		}
+	}
+
+	return ""
+}
+
+// deviceSettingsPolicyRank returns the precedence of a policy, ordering
+// policies without one last.
+func deviceSettingsPolicyRank(policy DeviceSettingsPolicy) int {
+	if policy.Precedence == nil {
+		return int(^uint(0) >> 1)
+	}
+	return *policy.Precedence
+}
+
+// deviceSettingsPolicyLabel returns a human readable identifier of a policy
+// for error messages.
+func deviceSettingsPolicyLabel(policy DeviceSettingsPolicy) string {
+	switch {
+	case policy.Default:
+		return "default"
+	case policy.Name != nil && policy.PolicyID != nil:
+		return fmt.Sprintf("%q (%s)", *policy.Name, *policy.PolicyID)
+	case policy.PolicyID != nil:
+		return *policy.PolicyID
+	case policy.Name != nil:
+		return fmt.Sprintf("%q", *policy.Name)
+	default:
+		return "<unnamed>"
+	}
+}
+
+// deviceMatchNode is a node of a parsed match expression.
+type deviceMatchNode interface {
+	eval(attrs DeviceMatchAttributes) (bool, error)
+}
+
+type deviceMatchLogical struct {
+	op          string // "and", "or" or "xor"
+	left, right deviceMatchNode
+}
+
+func (n deviceMatchLogical) eval(attrs DeviceMatchAttributes) (bool, error) {
+	left, err := n.left.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case n.op == "and" && !left:
+		return false, nil
+	case n.op == "or" && left:
+		return true, nil
+	}
+
+	right, err := n.right.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+
+	if n.op == "xor" {
+		return left != right, nil
+	}
+
+	return right, nil
+}
+
+type deviceMatchNot struct {
+	node deviceMatchNode
+}
+
+func (n deviceMatchNot) eval(attrs DeviceMatchAttributes) (bool, error) {
+	v, err := n.node.eval(attrs)
+	return !v, err
+}
+
+// deviceMatchComparison compares a selector against a value. For array
+// selectors used with `[*]`, reduce is "any" or "all".
+type deviceMatchComparison struct {
+	field  string
+	op     string // "==", "!=", "in", "matches" or "contains"
+	values []string
+	re     *regexp.Regexp
+	reduce string
+}
+
+func (n deviceMatchComparison) eval(attrs DeviceMatchAttributes) (bool, error) {
+	values := attrs.values(n.field)
+
+	if n.reduce == "" {
+		if len(values) == 0 {
+			return false, nil
+		}
+		return n.compare(values[0]), nil
+	}
+
+	for _, v := range values {
+		ok := n.compare(v)
+		if n.reduce == "any" && ok {
+			return true, nil
+		}
+		if n.reduce == "all" && !ok {
+			return false, nil
+		}
+	}
+
+	return n.reduce == "all" && len(values) > 0, nil
+}
+
+func (n deviceMatchComparison) compare(v string) bool {
+	switch n.op {
+	case "==":
+		return v == n.values[0]
+	case "!=":
+		return v != n.values[0]
+	case "contains":
+		return strings.Contains(v, n.values[0])
+	case "matches":
+		return n.re.MatchString(v)
+	case "in":
+		for _, candidate := range n.values {
+			if v == candidate {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// deviceMatchToken is a lexical token of a match expression.
+type deviceMatchToken struct {
+	kind   string // "ident", "string", "op", "eof"
+	value  string
+	offset int
+}
+
+func lexDeviceMatch(expression string) ([]deviceMatchToken, error) {
+	var tokens []deviceMatchToken
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			start := i
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, &DeviceMatchSyntaxError{Expression: expression, Offset: start, Message: "unterminated string"}
+			}
+			tokens = append(tokens, deviceMatchToken{kind: "string", value: b.String(), offset: start})
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.' || runes[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, deviceMatchToken{kind: "ident", value: string(runes[start:i]), offset: start})
+		default:
+			start := i
+			matched := ""
+			for _, op := range []string{"[*]", "==", "!=", "&&", "||", "^^", "(", ")", "{", "}", "~", "!", ","} {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					matched = op
+					break
+				}
+			}
+			if matched == "" {
+				return nil, &DeviceMatchSyntaxError{Expression: expression, Offset: start, Message: fmt.Sprintf("unexpected character %q", r)}
+			}
+			i += len([]rune(matched))
+			tokens = append(tokens, deviceMatchToken{kind: "op", value: matched, offset: start})
+		}
+	}
+
+	tokens = append(tokens, deviceMatchToken{kind: "eof", offset: len(runes)})
+
+	return tokens, nil
+}
+
+// deviceMatchParser is a recursive descent parser for match expressions.
+type deviceMatchParser struct {
+	expression string
+	tokens     []deviceMatchToken
+	pos        int
+}
+
+func parseDeviceMatch(expression string) (deviceMatchNode, error) {
+	node, err := parseDeviceMatchTokens(expression)
+	if err != nil {
+		if lintErr := lintUnquotedDeviceMatchStrings(expression); lintErr != nil {
+			return nil, lintErr
+		}
+		return nil, err
+	}
+
+	return node, nil
+}
+
+func parseDeviceMatchTokens(expression string) (deviceMatchNode, error) {
+	tokens, err := lexDeviceMatch(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &deviceMatchParser{expression: expression, tokens: tokens}
+	if p.peek().kind == "eof" {
+		return nil, p.errorf("empty expression")
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != "eof" {
+		return nil, p.errorf("unexpected %q", p.peek().value)
+	}
+
+	return node, nil
+}
+
+func (p *deviceMatchParser) peek() deviceMatchToken {
+	return p.tokens[p.pos]
+}
+
+func (p *deviceMatchParser) next() deviceMatchToken {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *deviceMatchParser) errorf(format string, args ...interface{}) error {
+	return &DeviceMatchSyntaxError{Expression: p.expression, Offset: p.peek().offset, Message: fmt.Sprintf(format, args...)}
+}
+
+// accept consumes the next token if it is one of values.
+func (p *deviceMatchParser) accept(values ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "ident" && t.kind != "op" {
+		return "", false
+	}
+	for _, v := range values {
+		if t.value == v {
+			p.next()
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (p *deviceMatchParser) expect(value string) error {
+	if _, ok := p.accept(value); !ok {
+		return p.errorf("expected %q", value)
+	}
+	return nil
+}
+
+func (p *deviceMatchParser) parseOr() (deviceMatchNode, error) {
+	return p.parseBinary("or", []string{"or", "||"}, p.parseXor)
+}
+
+func (p *deviceMatchParser) parseXor() (deviceMatchNode, error) {
+	return p.parseBinary("xor", []string{"xor", "^^"}, p.parseAnd)
+}
+
+func (p *deviceMatchParser) parseAnd() (deviceMatchNode, error) {
+	return p.parseBinary("and", []string{"and", "&&"}, p.parseUnary)
+}
+
+func (p *deviceMatchParser) parseBinary(op string, tokens []string, operand func() (deviceMatchNode, error)) (deviceMatchNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept(tokens...); !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = deviceMatchLogical{op: op, left: left, right: right}
+	}
+}
+
+func (p *deviceMatchParser) parseUnary() (deviceMatchNode, error) {
+	if _, ok := p.accept("not", "!"); ok {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return deviceMatchNot{node: node}, nil
+	}
+
+	if _, ok := p.accept("("); ok {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	if reduce, ok := p.accept("any", "all"); ok {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		node, err := p.parseComparison(reduce)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	return p.parseComparison("")
+}
+
+func (p *deviceMatchParser) parseComparison(reduce string) (deviceMatchNode, error) {
+	t := p.peek()
+	if t.kind != "ident" {
+		return nil, p.errorf("expected a field, found %q", t.value)
+	}
+
+	if strings.HasPrefix(t.value, "$") {
+		return nil, p.errorf("lists are not supported")
+	}
+
+	kind, ok := deviceMatchFields[t.value]
+	if !ok {
+		return nil, p.errorf("unknown field %q", t.value)
+	}
+	p.next()
+
+	_, wildcard := p.accept("[*]")
+	switch {
+	case kind == deviceMatchArray && !wildcard:
+		return nil, p.errorf("array field %q must be used as %s[*]", t.value, t.value)
+	case kind == deviceMatchString && wildcard:
+		return nil, p.errorf("field %q is not an array", t.value)
+	case wildcard && reduce == "":
+		return nil, p.errorf("array field %q must be wrapped in any() or all()", t.value)
+	case !wildcard && reduce != "":
+		return nil, p.errorf("%s() requires an array field", reduce)
+	}
+
+	node := deviceMatchComparison{field: t.value, reduce: reduce}
+
+	op, ok := p.accept("==", "eq", "!=", "ne", "in", "matches", "~", "contains")
+	if !ok {
+		return nil, p.errorf("expected a comparison operator after %q", t.value)
+	}
+
+	switch op {
+	case "eq":
+		op = "=="
+	case "ne":
+		op = "!="
+	case "~":
+		op = "matches"
+	}
+	node.op = op
+
+	if op == "in" {
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		for {
+			if _, ok := p.accept("}"); ok {
+				break
+			}
+			p.accept(",")
+			v := p.next()
+			if v.kind != "string" {
+				return nil, p.errorf("expected a string in set")
+			}
+			node.values = append(node.values, v.value)
+		}
+		if len(node.values) == 0 {
+			return nil, p.errorf("empty set")
+		}
+		return node, nil
+	}
+
+	v := p.peek()
+	if v.kind != "string" {
+		return nil, p.errorf("expected a quoted string after %q", op)
+	}
+	p.next()
+	node.values = []string{v.value}
+
+	if op == "matches" {
+		re, err := regexp.Compile(v.value)
+		if err != nil {
+			return nil, &DeviceMatchSyntaxError{Expression: p.expression, Offset: v.offset, Message: fmt.Sprintf("invalid regular expression: %s", err)}
+		}
+		node.re = re
+	}
+
+	return node, nil
+}
+
+var (
+	// deviceMatchComparedValuePattern matches the value compared to a field.
+	deviceMatchComparedValuePattern = regexp.MustCompile(`(?:==|!=|\beq\b|\bne\b|\bcontains\b)\s*([^\s"(){},!&|^]+)`)
+	// deviceMatchSetPattern matches the values of an `in` set.
+	deviceMatchSetPattern = regexp.MustCompile(`\bin\s*\{([^}]*)\}`)
+	// deviceMatchSetValuePattern matches a single unquoted value of a set.
+	deviceMatchSetValuePattern = regexp.MustCompile(`[^\s",]+`)
+)
+
+// lintUnquotedDeviceMatchStrings looks for values that were most likely meant
+// as string literals but are missing their quotes, as in
+// `identity.email == alice@example.com`, a frequent mistake that otherwise
+// results in a confusing syntax error. It is a heuristic, only applied to
+// expressions that fail to parse: any bare word compared to a field, or
+// listed in an `in` set, that isn't a known selector is reported, with the
+// quoted value as a suggestion.
+func lintUnquotedDeviceMatchStrings(expression string) error {
+	// Blank out string literals so that their content isn't mistaken for
+	// operators or values.
+	masked := []rune(expression)
+	for i := 0; i < len(masked); i++ {
+		if masked[i] != '"' {
+			continue
+		}
+		for i++; i < len(masked) && masked[i] != '"'; i++ {
+			if masked[i] == '\\' && i+1 < len(masked) {
+				masked[i] = '_'
+				i++
+			}
+			masked[i] = '_'
+		}
+	}
+	text := string(masked)
+
+	type candidate struct {
+		offset int
+		value  string
+	}
+	var candidates []candidate
+	for _, m := range deviceMatchComparedValuePattern.FindAllStringSubmatchIndex(text, -1) {
+		candidates = append(candidates, candidate{offset: m[2], value: text[m[2]:m[3]]})
+	}
+	for _, m := range deviceMatchSetPattern.FindAllStringSubmatchIndex(text, -1) {
+		for _, v := range deviceMatchSetValuePattern.FindAllStringIndex(text[m[2]:m[3]], -1) {
+			candidates = append(candidates, candidate{offset: m[2] + v[0], value: text[m[2]+v[0] : m[2]+v[1]]})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].offset < candidates[j].offset })
+
+	for _, c := range candidates {
+		if _, ok := deviceMatchFields[c.value]; ok || strings.HasPrefix(c.value, "$") || strings.HasPrefix(c.value, "_") {
+			continue
+		}
+
+		return &DeviceMatchSyntaxError{
+			Expression: expression,
+			Offset:     utf8.RuneCountInString(text[:c.offset]),
+			Message:    fmt.Sprintf("%s looks like a string that is missing its quotes, use %s instead", c.value, quoteDeviceMatchString(c.value)),
+		}
+	}
+
+	return nil
+}
+
+// deviceMatchFieldValues returns the values node compares field against.
+func deviceMatchFieldValues(node deviceMatchNode, field string) []string {
+	switch n := node.(type) {
+	case deviceMatchLogical:
+		return append(deviceMatchFieldValues(n.left, field), deviceMatchFieldValues(n.right, field)...)
+	case deviceMatchNot:
+		return deviceMatchFieldValues(n.node, field)
+	case deviceMatchComparison:
+		if n.field == field {
+			return n.values
+		}
+	}
+
+	return nil
+}
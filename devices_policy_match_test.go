@@ -0,0 +1,310 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDeviceMatch(t *testing.T) {
+	valid := []string{
+		`identity.email == "alice@example.com"`,
+		`identity.email eq "alice@example.com" and os.name == "mac"`,
+		`any(identity.groups.name[*] in {"engineering" "it"})`,
+		`any(identity.groups.name[*] in {"engineering", "it"})`,
+		`not (os.name == "windows" || network == "office")`,
+		`identity.email matches ".*@example\\.com$"`,
+		`all(device_posture.checks.passed[*] != "abc")`,
+	}
+	for _, expression := range valid {
+		assert.NoError(t, ValidateDeviceMatch(expression), expression)
+	}
+
+	invalid := []string{
+		``,
+		`identity.email == `,
+		`identity.email == "unterminated`,
+		`identity.unknown == "x"`,
+		`identity.groups.name == "x"`,
+		`identity.groups.name[*] == "x"`,
+		`any(identity.email in {"x"})`,
+		`identity.email in {}`,
+		`identity.email in $list`,
+		`identity.email matches "("`,
+		`(os.name == "mac"`,
+		`os.name == "mac" os.name == "linux"`,
+	}
+	for _, expression := range invalid {
+		err := ValidateDeviceMatch(expression)
+		var syntaxErr *DeviceMatchSyntaxError
+		assert.True(t, errors.As(err, &syntaxErr), expression)
+	}
+}
+
+func TestValidateDeviceMatchUnquotedStrings(t *testing.T) {
+	testCases := map[string]struct {
+		expression string
+		offset     int
+		suggestion string
+	}{
+		"email":         {`identity.email == alice@example.com`, 18, `"alice@example.com"`},
+		"domain":        {`os.name == "mac" and network != corp.example.com`, 32, `"corp.example.com"`},
+		"set":           {`any(identity.groups.name[*] in {"it" engineering})`, 37, `"engineering"`},
+		"word operator": {`identity.email eq bob@example.com`, 18, `"bob@example.com"`},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateDeviceMatch(tc.expression)
+
+			var syntaxErr *DeviceMatchSyntaxError
+			require.True(t, errors.As(err, &syntaxErr))
+			assert.Equal(t, tc.offset, syntaxErr.Offset)
+			assert.Contains(t, syntaxErr.Message, tc.suggestion)
+		})
+	}
+
+	// Quoted values are left alone, even when they look like operators.
+	err := ValidateDeviceMatch(`identity.email == "a == b" or`)
+	var syntaxErr *DeviceMatchSyntaxError
+	require.True(t, errors.As(err, &syntaxErr))
+	assert.NotContains(t, syntaxErr.Message, "quotes")
+}
+
+func TestEvaluateDeviceSettingsPolicyMatch(t *testing.T) {
+	policy := func(id string, precedence int, match string) DeviceSettingsPolicy {
+		return DeviceSettingsPolicy{
+			PolicyID:   StringPtr(id),
+			Name:       StringPtr(id),
+			Precedence: IntPtr(precedence),
+			Match:      StringPtr(match),
+			Enabled:    BoolPtr(true),
+		}
+	}
+
+	disabled := policy("disabled", 1, `identity.email == "alice@example.com"`)
+	disabled.Enabled = BoolPtr(false)
+
+	policies := []DeviceSettingsPolicy{
+		{Default: true, PolicyID: StringPtr("default")},
+		policy("compliant-engineers", 30, `any(identity.groups.name[*] in {"engineering"}) and any(device_posture.checks.passed[*] == "disk-encryption")`),
+		policy("engineers", 40, `any(identity.groups.name[*] in {"engineering"})`),
+		policy("alice-mac", 20, `identity.email == "alice@example.com" and os.name == "mac"`),
+		disabled,
+	}
+
+	testCases := map[string]struct {
+		attrs    DeviceMatchAttributes
+		expected string
+	}{
+		"email and os": {
+			attrs:    DeviceMatchAttributes{Email: "alice@example.com", OSName: "mac"},
+			expected: "alice-mac",
+		},
+		"posture check passed": {
+			attrs: DeviceMatchAttributes{
+				Email:          "bob@example.com",
+				Groups:         []DeviceMatchGroup{{ID: "1", Name: "engineering"}},
+				PostureResults: map[string]bool{"disk-encryption": true},
+			},
+			expected: "compliant-engineers",
+		},
+		"posture check failed": {
+			attrs: DeviceMatchAttributes{
+				Email:          "bob@example.com",
+				Groups:         []DeviceMatchGroup{{ID: "1", Name: "engineering"}},
+				PostureResults: map[string]bool{"disk-encryption": false},
+			},
+			expected: "engineers",
+		},
+		"no match falls back to default": {
+			attrs:    DeviceMatchAttributes{Email: "alice@example.com", OSName: "windows"},
+			expected: "default",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := EvaluateDeviceSettingsPolicyMatch(policies, tc.attrs)
+			require.NoError(t, err)
+			require.NotNil(t, actual)
+			assert.Equal(t, tc.expected, *actual.PolicyID)
+		})
+	}
+}
+
+func TestEvaluateDeviceSettingsPolicyMatchInvalidExpression(t *testing.T) {
+	policies := []DeviceSettingsPolicy{{
+		PolicyID: StringPtr("broken"),
+		Match:    StringPtr(`identity.saml_attributes == "x"`),
+	}}
+
+	_, err := EvaluateDeviceSettingsPolicyMatch(policies, DeviceMatchAttributes{})
+	assert.ErrorContains(t, err, "broken")
+
+	actual, err := EvaluateDeviceSettingsPolicyMatch(nil, DeviceMatchAttributes{})
+	assert.NoError(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestFindShadowingDeviceSettingsPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"policy_id": "engineering", "precedence": 10, "match": "any(identity.groups.name[*] in {\"engineering\"})"},
+				{"policy_id": "test", "precedence": 20, "match": "identity.email matches \".*\" or os.name == \"mac\""},
+				{"policy_id": "disabled", "precedence": 5, "enabled": false, "match": "identity.email contains \"\""},
+				{"policy_id": "anchored", "precedence": 30, "match": "identity.email matches \"^.*@example\\\\.com$\""},
+				{"policy_id": "mac", "precedence": 40, "match": "os.name == \"mac\" and identity.email != \"\""}
+			],
+			"result_info": {"page": 1, "per_page": 20, "count": 5, "total_count": 5}
+		}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the default policy should not be fetched")
+	})
+
+	_, err := client.FindShadowingDeviceSettingsPolicies(context.Background(), ZoneIdentifier(testZoneID), FindShadowingDeviceSettingsPoliciesParams{})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+
+	actual, err := client.FindShadowingDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), FindShadowingDeviceSettingsPoliciesParams{})
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, "test", *actual[0].Policy.PolicyID)
+	assert.Equal(t, `identity.email matches ".*", which matches any value`, actual[0].Reason)
+	require.Len(t, actual[0].Shadowed, 2)
+	assert.Equal(t, "anchored", *actual[0].Shadowed[0].PolicyID)
+	assert.Equal(t, "mac", *actual[0].Shadowed[1].PolicyID)
+}
+
+func TestValidateDeviceMatchReferences(t *testing.T) {
+	setup()
+	defer teardown()
+
+	requests := 0
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		requests++
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [{"id": "disk-encryption", "type": "disk_encryption", "name": "Disk encryption"}]
+		}`)
+	})
+
+	expression := `any(device_posture.checks.passed[*] in {"disk-encryption" "deleted-rule"})`
+
+	assert.NoError(t, ValidateDeviceMatch(expression))
+	assert.Equal(t, 0, requests, "syntax validation makes no requests")
+
+	err := client.ValidateDeviceMatchReferences(context.Background(), AccountIdentifier(testAccountID), ValidateDeviceMatchReferencesParams{Expression: expression})
+	assert.ErrorIs(t, err, ErrDeviceMatchDanglingReference)
+	var refErr *DeviceMatchReferenceError
+	if assert.ErrorAs(t, err, &refErr) {
+		assert.Equal(t, []string{"deleted-rule"}, refErr.PostureRuleIDs)
+	}
+
+	err = client.ValidateDeviceMatchReferences(context.Background(), AccountIdentifier(testAccountID), ValidateDeviceMatchReferencesParams{
+		Expression: `any(device_posture.checks.passed[*] == "disk-encryption")`,
+	})
+	assert.NoError(t, err)
+
+	requests = 0
+	err = client.ValidateDeviceMatchReferences(context.Background(), AccountIdentifier(testAccountID), ValidateDeviceMatchReferencesParams{Expression: `os.name ==`})
+	var syntaxErr *DeviceMatchSyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 0, requests, "invalid expressions are rejected before any request")
+
+	err = client.ValidateDeviceMatchReferences(context.Background(), ZoneIdentifier(testZoneID), ValidateDeviceMatchReferencesParams{Expression: expression})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+}
+
+func TestSetDeviceSettingsPolicyMatchGroup(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, `any(identity.groups.id[*] in {"eng \"core\""})`, body["match"])
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	})
+
+	_, err := client.SetDeviceSettingsPolicyMatchGroup(context.Background(), AccountIdentifier(testAccountID), SetDeviceSettingsPolicyMatchGroupParams{
+		PolicyID: deviceSettingsPolicyID,
+		GroupID:  `eng "core"`,
+	})
+	assert.NoError(t, err)
+
+	match, err := parseDeviceMatch(`any(identity.groups.id[*] in {"eng \"core\""})`)
+	require.NoError(t, err)
+	ok, err := match.eval(DeviceMatchAttributes{Groups: []DeviceMatchGroup{{ID: `eng "core"`}}})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = client.SetDeviceSettingsPolicyMatchGroup(context.Background(), AccountIdentifier(testAccountID), SetDeviceSettingsPolicyMatchGroupParams{
+		PolicyID: deviceSettingsPolicyID,
+	})
+	assert.ErrorIs(t, err, ErrMissingDeviceMatchGroupID)
+}
+
+func TestDeviceMatchBuilder(t *testing.T) {
+	match, err := DeviceMatch().
+		GroupIDIn([]string{"engineering"}).
+		EmailNotIn([]string{"ceo@example.com", `o"brien@example.com`}).
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, `any(identity.groups.id[*] in {"engineering"}) and not(identity.email in {"ceo@example.com" "o\"brien@example.com"})`, match)
+
+	node, err := parseDeviceMatch(match)
+	require.NoError(t, err)
+	for email, expected := range map[string]bool{
+		"dev@example.com":     true,
+		"ceo@example.com":     false,
+		`o"brien@example.com`: false,
+		"intern@example.com":  true,
+	} {
+		matched, err := node.eval(DeviceMatchAttributes{Email: email, Groups: []DeviceMatchGroup{{ID: "engineering"}}})
+		require.NoError(t, err)
+		assert.Equal(t, expected, matched, email)
+	}
+
+	match, err = DeviceMatch().
+		Or(DeviceMatch().EmailIn([]string{"a@example.com"}), DeviceMatch().GroupIDIn([]string{"it"})).
+		Not(DeviceMatch().GroupIDIn([]string{"contractors"})).
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, `((identity.email in {"a@example.com"}) or (any(identity.groups.id[*] in {"it"}))) and not(any(identity.groups.id[*] in {"contractors"}))`, match)
+
+	_, err = DeviceMatch().EmailNotIn(nil).Build()
+	assert.ErrorIs(t, err, ErrEmptyDeviceMatchValues)
+
+	_, err = DeviceMatch().Not(DeviceMatch().EmailIn([]string{})).Build()
+	assert.ErrorIs(t, err, ErrEmptyDeviceMatchValues)
+
+	_, err = DeviceMatch().Build()
+	assert.Error(t, err)
+}
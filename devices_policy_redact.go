@@ -0,0 +1,119 @@
+package cloudflare
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+
+	"github.com/goccy/go-json"
+)
+
+// deviceSettingsPolicyRedactedValue replaces the value of redacted fields.
+const deviceSettingsPolicyRedactedValue = "REDACTED"
+
+// DeviceSettingsPolicyRedactOption configures the fields masked by
+// DeviceSettingsPolicy.RedactedJSON.
+type DeviceSettingsPolicyRedactOption func(*deviceSettingsPolicyRedactOptions)
+
+type deviceSettingsPolicyRedactOptions struct {
+	fields map[string]bool
+}
+
+// defaultDeviceSettingsPolicyRedactedFields are the JSON fields masked when
+// no DeviceSettingsPolicyRedactFields option is given.
+var defaultDeviceSettingsPolicyRedactedFields = []string{"support_url", "gateway_unique_id"}
+
+// DeviceSettingsPolicyRedactFields replaces the default set of redacted
+// fields with fields, given by their JSON names (e.g. "policy_id"). Calling
+// it without arguments disables redaction.
+func DeviceSettingsPolicyRedactFields(fields ...string) DeviceSettingsPolicyRedactOption {
+	return func(o *deviceSettingsPolicyRedactOptions) {
+		o.fields = make(map[string]bool, len(fields))
+		for _, field := range fields {
+			o.fields[field] = true
+		}
+	}
+}
+
+// DeviceSettingsPolicyRedactAdditionalFields masks fields, given by their
+// JSON names, on top of the current set of redacted fields.
+func DeviceSettingsPolicyRedactAdditionalFields(fields ...string) DeviceSettingsPolicyRedactOption {
+	return func(o *deviceSettingsPolicyRedactOptions) {
+		for _, field := range fields {
+			o.fields[field] = true
+		}
+	}
+}
+
+// RedactedJSON marshals the policy to JSON with sensitive fields masked, so
+// that it can be shared, e.g. in a support ticket, without leaking internal
+// identifiers.
+//
+// By default the gateway unique ID is replaced by "REDACTED" and only the
+// query parameter values of the support URL are, keeping the rest of the URL
+// readable. Any other redacted field is replaced by "REDACTED" as a whole.
+// Unset (null) fields are left as is. An error is returned when an option
+// names a field that a device settings policy doesn't have.
+func (p DeviceSettingsPolicy) RedactedJSON(opts ...DeviceSettingsPolicyRedactOption) ([]byte, error) {
+	o := deviceSettingsPolicyRedactOptions{fields: make(map[string]bool)}
+	for _, field := range defaultDeviceSettingsPolicyRedactedFields {
+		o.fields[field] = true
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	for field := range o.fields {
+		value, ok := fields[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown device settings policy field %q", field)
+		}
+		if value == nil {
+			continue
+		}
+
+		if field == "support_url" {
+			if s, ok := value.(string); ok {
+				fields[field] = redactURLQuery(s)
+				continue
+			}
+		}
+
+		fields[field] = deviceSettingsPolicyRedactedValue
+	}
+
+	return json.Marshal(fields)
+}
+
+// redactURLQuery masks the query parameter values of rawURL. A value that
+// isn't a valid URL is masked completely.
+func redactURLQuery(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return deviceSettingsPolicyRedactedValue
+	}
+
+	if u.RawQuery == "" {
+		return rawURL
+	}
+
+	query := u.Query()
+	for key := range query {
+		query[key] = []string{deviceSettingsPolicyRedactedValue}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,51 @@
+package cloudflare
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceSettingsPolicy_RedactedJSON(t *testing.T) {
+	policy := DeviceSettingsPolicy{
+		PolicyID:        StringPtr("a842fa8a-a583-482e-9cd9-eb43362949fd"),
+		Name:            StringPtr("engineering"),
+		GatewayUniqueID: StringPtr("t1235"),
+		SupportURL:      StringPtr("https://support.example.com/ticket?team=internal-it&token=secret"),
+		Precedence:      IntPtr(10),
+	}
+
+	decode := func(b []byte) map[string]interface{} {
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal(b, &fields))
+		return fields
+	}
+
+	out, err := policy.RedactedJSON()
+	if assert.NoError(t, err) {
+		fields := decode(out)
+		assert.Equal(t, "REDACTED", fields["gateway_unique_id"])
+		assert.Equal(t, "https://support.example.com/ticket?team=REDACTED&token=REDACTED", fields["support_url"])
+		assert.Equal(t, "a842fa8a-a583-482e-9cd9-eb43362949fd", fields["policy_id"])
+		assert.Equal(t, float64(10), fields["precedence"])
+		assert.Nil(t, fields["match"])
+	}
+
+	out, err = policy.RedactedJSON(DeviceSettingsPolicyRedactFields("policy_id"))
+	if assert.NoError(t, err) {
+		fields := decode(out)
+		assert.Equal(t, "REDACTED", fields["policy_id"])
+		assert.Equal(t, "t1235", fields["gateway_unique_id"])
+	}
+
+	out, err = policy.RedactedJSON(DeviceSettingsPolicyRedactAdditionalFields("name"))
+	if assert.NoError(t, err) {
+		fields := decode(out)
+		assert.Equal(t, "REDACTED", fields["name"])
+		assert.Equal(t, "REDACTED", fields["gateway_unique_id"])
+	}
+
+	_, err = policy.RedactedJSON(DeviceSettingsPolicyRedactFields("secret"))
+	assert.Error(t, err)
+}
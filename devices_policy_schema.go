@@ -0,0 +1,192 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// DeviceSettingsFieldType is the JSON type of a device settings field.
+type DeviceSettingsFieldType string
+
+const (
+	DeviceSettingsFieldTypeBoolean DeviceSettingsFieldType = "boolean"
+	DeviceSettingsFieldTypeInteger DeviceSettingsFieldType = "integer"
+	DeviceSettingsFieldTypeNumber  DeviceSettingsFieldType = "number"
+	DeviceSettingsFieldTypeString  DeviceSettingsFieldType = "string"
+	DeviceSettingsFieldTypeArray   DeviceSettingsFieldType = "array"
+	DeviceSettingsFieldTypeObject  DeviceSettingsFieldType = "object"
+	DeviceSettingsFieldTypeUnknown DeviceSettingsFieldType = "unknown"
+)
+
+// DeviceSettingsField describes a single device setting.
+type DeviceSettingsField struct {
+	// Name is the JSON name of the field, e.g. "captive_portal".
+	Name string
+	Type DeviceSettingsFieldType
+	// Default is the value of the field in the default device settings
+	// policy of the account, or nil when it is unset there. Fields modelled
+	// by DeviceSettingsPolicy hold a value of the Go type of the field, such
+	// as bool or int; others hold the decoded JSON value.
+	Default interface{}
+	// Supported reports whether DeviceSettingsPolicy models the field. Fields
+	// returned by the API that this library doesn't know yet are included
+	// with Supported set to false.
+	Supported bool
+}
+
+// DeviceSettingsSchema lists the device settings that can be set by device
+// settings policies.
+type DeviceSettingsSchema struct {
+	Fields []DeviceSettingsField
+}
+
+// Field returns the field with the given JSON name.
+func (s DeviceSettingsSchema) Field(name string) (DeviceSettingsField, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+
+	return DeviceSettingsField{}, false
+}
+
+type GetDeviceSettingsSchemaParams struct{}
+
+// GetDeviceSettingsSchema describes the device settings, with their types and
+// the values the default policy of the account gives them.
+//
+// The API doesn't publish a schema, so the fields are those of
+// DeviceSettingsPolicy, followed by any field of the default policy response
+// that DeviceSettingsPolicy doesn't model yet, in alphabetical order. The
+// fields identifying a policy, such as its name, match or precedence, are not
+// settings and are left out.
+//
+// API reference: https://api.cloudflare.com/#devices-get-default-device-settings-policy
+func (api *API) GetDeviceSettingsSchema(ctx context.Context, rc *ResourceContainer, params GetDeviceSettingsSchemaParams) (DeviceSettingsSchema, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsSchema{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	uri := fmt.Sprintf("/%s/%s/devices/policy", rc.Level, rc.Identifier)
+	res, err := api.makeRequestContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return DeviceSettingsSchema{}, err
+	}
+
+	var response struct {
+		Result map[string]json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(res, &response); err != nil {
+		return DeviceSettingsSchema{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
+	}
+
+	schema := DeviceSettingsSchema{}
+	known := make(map[string]bool)
+
+	t := reflect.TypeOf(DeviceSettingsPolicy{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		known[name] = true
+		if name == "" || name == "-" || deviceSettingsPolicyIdentityFields[name] {
+			continue
+		}
+
+		fieldType := t.Field(i).Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		field := DeviceSettingsField{
+			Name:      name,
+			Type:      deviceSettingsFieldTypeOf(fieldType),
+			Supported: true,
+		}
+
+		if raw, ok := response.Result[name]; ok && !isJSONNull(raw) {
+			value := reflect.New(fieldType)
+			if err := json.Unmarshal(raw, value.Interface()); err != nil {
+				return DeviceSettingsSchema{}, fmt.Errorf("%s: %s: %w", errUnmarshalError, name, err)
+			}
+			field.Default = value.Elem().Interface()
+		}
+
+		schema.Fields = append(schema.Fields, field)
+	}
+
+	var unknown []string
+	for name := range response.Result {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, name := range unknown {
+		field := DeviceSettingsField{Name: name, Type: DeviceSettingsFieldTypeUnknown}
+
+		if raw := response.Result[name]; !isJSONNull(raw) {
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			if err := decoder.Decode(&field.Default); err != nil {
+				return DeviceSettingsSchema{}, fmt.Errorf("%s: %s: %w", errUnmarshalError, name, err)
+			}
+			field.Type = deviceSettingsFieldTypeOfValue(field.Default)
+		}
+
+		schema.Fields = append(schema.Fields, field)
+	}
+
+	return schema, nil
+}
+
+func deviceSettingsFieldTypeOf(t reflect.Type) DeviceSettingsFieldType {
+	switch t.Kind() {
+	case reflect.Bool:
+		return DeviceSettingsFieldTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return DeviceSettingsFieldTypeInteger
+	case reflect.Float32, reflect.Float64:
+		return DeviceSettingsFieldTypeNumber
+	case reflect.String:
+		return DeviceSettingsFieldTypeString
+	case reflect.Slice, reflect.Array:
+		return DeviceSettingsFieldTypeArray
+	case reflect.Struct, reflect.Map:
+		return DeviceSettingsFieldTypeObject
+	}
+
+	return DeviceSettingsFieldTypeUnknown
+}
+
+func deviceSettingsFieldTypeOfValue(v interface{}) DeviceSettingsFieldType {
+	switch v := v.(type) {
+	case bool:
+		return DeviceSettingsFieldTypeBoolean
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return DeviceSettingsFieldTypeInteger
+		}
+		return DeviceSettingsFieldTypeNumber
+	case string:
+		return DeviceSettingsFieldTypeString
+	case []interface{}:
+		return DeviceSettingsFieldTypeArray
+	case map[string]interface{}:
+		return DeviceSettingsFieldTypeObject
+	}
+
+	return DeviceSettingsFieldTypeUnknown
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null"
+}
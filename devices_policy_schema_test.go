@@ -0,0 +1,71 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDeviceSettingsSchema(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {
+				"default": true,
+				"enabled": true,
+				"captive_portal": 180,
+				"allow_mode_switch": false,
+				"support_url": null,
+				"service_mode_v2": {"mode": "warp"},
+				"tunnel_protocol": "wireguard",
+				"auto_connect_grace_minutes": 15
+			}
+		}`)
+	})
+
+	schema, err := client.GetDeviceSettingsSchema(context.Background(), AccountIdentifier(testAccountID), GetDeviceSettingsSchemaParams{})
+	require.NoError(t, err)
+
+	_, ok := schema.Field("precedence")
+	assert.False(t, ok)
+
+	field, ok := schema.Field("captive_portal")
+	if assert.True(t, ok) {
+		assert.Equal(t, DeviceSettingsField{Name: "captive_portal", Type: DeviceSettingsFieldTypeInteger, Default: 180, Supported: true}, field)
+	}
+
+	field, ok = schema.Field("allow_mode_switch")
+	if assert.True(t, ok) {
+		assert.Equal(t, false, field.Default)
+		assert.Equal(t, DeviceSettingsFieldTypeBoolean, field.Type)
+	}
+
+	field, ok = schema.Field("support_url")
+	if assert.True(t, ok) {
+		assert.Nil(t, field.Default)
+		assert.Equal(t, DeviceSettingsFieldTypeString, field.Type)
+	}
+
+	field, ok = schema.Field("service_mode_v2")
+	if assert.True(t, ok) {
+		assert.Equal(t, ServiceModeV2{Mode: warp}, field.Default)
+		assert.Equal(t, DeviceSettingsFieldTypeObject, field.Type)
+	}
+
+	n := len(schema.Fields)
+	require.GreaterOrEqual(t, n, 2)
+	assert.Equal(t, DeviceSettingsField{Name: "auto_connect_grace_minutes", Type: DeviceSettingsFieldTypeInteger, Default: json.Number("15")}, schema.Fields[n-2])
+	assert.Equal(t, DeviceSettingsField{Name: "tunnel_protocol", Type: DeviceSettingsFieldTypeString, Default: "wireguard"}, schema.Fields[n-1])
+}
@@ -0,0 +1,547 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// DeviceSettingsSnapshotPolicy is a device settings policy along with the
+// lists managed through their own endpoints.
+type DeviceSettingsSnapshotPolicy struct {
+	Policy          DeviceSettingsPolicy `json:"policy"`
+	Include         []SplitTunnel        `json:"include"`
+	Exclude         []SplitTunnel        `json:"exclude"`
+	FallbackDomains []FallbackDomain     `json:"fallback_domains"`
+}
+
+// DeviceSettingsSnapshot is the device configuration of an account, as
+// returned by SnapshotDeviceSettingsPolicies. It is meant to be stored, e.g.
+// as JSON, and restored with RestoreDeviceSettingsPolicies.
+type DeviceSettingsSnapshot struct {
+	AccountID string    `json:"account_id"`
+	TakenAt   time.Time `json:"taken_at"`
+	// Default is the default policy of the account.
+	Default DeviceSettingsSnapshotPolicy `json:"default"`
+	// Policies are the custom policies, in ascending order of precedence.
+	Policies []DeviceSettingsSnapshotPolicy `json:"policies"`
+}
+
+type SnapshotDeviceSettingsPoliciesParams struct {
+	// Concurrency is the maximum number of policies whose lists are fetched
+	// at once. Defaults to 4.
+	Concurrency int
+}
+
+// SnapshotDeviceSettingsPolicies returns the device settings policies of an
+// account, the default policy included, with their split tunnel include and
+// exclude lists and their fallback domains.
+//
+// When the lists of some policies can't be fetched, the snapshot holds the
+// other policies and a *DeviceBatchError keyed by policy ID, or "default" for
+// the default policy, is returned along with it. Such a snapshot is
+// incomplete and must not be restored with pruning.
+//
+// API reference: https://api.cloudflare.com/#devices-list-device-settings-policies
+func (api *API) SnapshotDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params SnapshotDeviceSettingsPoliciesParams) (DeviceSettingsSnapshot, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsSnapshot{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	accountID := rc.Identifier
+	policies, err := api.deviceSettingsPoliciesWithDefault(ctx, accountID)
+	if err != nil {
+		return DeviceSettingsSnapshot{}, err
+	}
+
+	byKey := make(map[string]DeviceSettingsPolicy, len(policies))
+	keys := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		key := devicePolicyCountKey(policy)
+		byKey[key] = policy
+		keys = append(keys, key)
+	}
+
+	var mu sync.Mutex
+	entries := make(map[string]DeviceSettingsSnapshotPolicy, len(policies))
+	errs := runDeviceBatch(ctx, uniqueDeviceBatchIDs(keys), params.Concurrency, func(ctx context.Context, key string) error {
+		policy := byKey[key]
+		policyID := ""
+		if !policy.Default {
+			policyID = key
+		}
+
+		entry := DeviceSettingsSnapshotPolicy{Policy: policy}
+		var err error
+		if entry.Include, err = api.listSplitTunnels(ctx, accountID, policyID, "include"); err != nil {
+			return err
+		}
+		if entry.Exclude, err = api.listSplitTunnels(ctx, accountID, policyID, "exclude"); err != nil {
+			return err
+		}
+		if entry.FallbackDomains, err = api.listFallbackDomains(ctx, accountID, policyID); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		entries[key] = entry
+		mu.Unlock()
+		return nil
+	})
+
+	snapshot := DeviceSettingsSnapshot{AccountID: accountID, TakenAt: time.Now().UTC()}
+	for _, key := range uniqueDeviceBatchIDs(keys) {
+		entry, ok := entries[key]
+		if !ok {
+			continue
+		}
+		if entry.Policy.Default {
+			snapshot.Default = entry
+			continue
+		}
+		snapshot.Policies = append(snapshot.Policies, entry)
+	}
+
+	sort.SliceStable(snapshot.Policies, func(i, j int) bool {
+		return deviceSettingsPolicyRank(snapshot.Policies[i].Policy) < deviceSettingsPolicyRank(snapshot.Policies[j].Policy)
+	})
+
+	return snapshot, newDeviceBatchError(errs)
+}
+
+// DeviceSettingsRestoreAction is what RestoreDeviceSettingsPolicies does, or
+// with DryRun would do, to a policy.
+type DeviceSettingsRestoreAction string
+
+const (
+	DeviceSettingsRestoreCreate    DeviceSettingsRestoreAction = "create"
+	DeviceSettingsRestoreUpdate    DeviceSettingsRestoreAction = "update"
+	DeviceSettingsRestoreDelete    DeviceSettingsRestoreAction = "delete"
+	DeviceSettingsRestoreUnchanged DeviceSettingsRestoreAction = "unchanged"
+)
+
+// DeviceSettingsRestoreChange describes the restore of a single policy.
+type DeviceSettingsRestoreChange struct {
+	Action DeviceSettingsRestoreAction
+	// SnapshotPolicyID is the ID of the policy in the snapshot, or "default"
+	// for the default policy. It is empty for deleted policies.
+	SnapshotPolicyID string
+	// PolicyID is the ID of the policy in the account. It is empty for the
+	// default policy and for policies created in a dry run.
+	PolicyID string
+	Name     string
+	// Fields are the JSON names of the settings changed.
+	Fields []string
+	// Lists are the lists replaced, among "include", "exclude" and
+	// "fallback_domains".
+	Lists []string
+	// Err is the error the restore of the policy failed with.
+	Err error
+}
+
+// DeviceSettingsRestoreReport is the outcome of
+// RestoreDeviceSettingsPolicies.
+type DeviceSettingsRestoreReport struct {
+	DryRun  bool
+	Changes []DeviceSettingsRestoreChange
+	// PolicyIDs maps the ID of each custom policy of the snapshot to the ID
+	// of the policy restoring it, which differs when the policy had to be
+	// created again.
+	PolicyIDs map[string]string
+}
+
+type RestoreDeviceSettingsPoliciesParams struct {
+	// Snapshot is the snapshot to restore, as taken by
+	// SnapshotDeviceSettingsPolicies.
+	Snapshot DeviceSettingsSnapshot
+	// Prune deletes the custom policies of the account that aren't part of
+	// the snapshot. The default policy is never deleted.
+	Prune bool
+	// DryRun only reports the changes that would be made.
+	DryRun bool
+}
+
+// RestoreDeviceSettingsPolicies changes the device settings policies of an
+// account to match a snapshot, as taken by SnapshotDeviceSettingsPolicies.
+//
+// Snapshot policies are matched to the policies of the account by ID, then
+// by name, so that a snapshot can be restored after its policies were
+// deleted or into another account. Matched policies are updated with the
+// settings that differ, the others are created, and their split tunnel and
+// fallback domain lists are replaced when they differ. The precedences of the
+// snapshot are kept where they are free; otherwise the next free value is
+// used, keeping the order of the snapshot. Matched policies holding the
+// precedence of another restored policy are first moved to an unused
+// precedence, so that precedences can be swapped. The default policy is
+// updated like the others but never created or deleted.
+//
+// Policies are restored one at a time: pruned policies first, then the
+// default policy, then the custom policies in order of precedence. A policy
+// that fails to be restored doesn't stop the others; its error is set on its
+// change and reported in a *DeviceBatchError keyed by snapshot policy ID, or
+// by policy ID for pruned policies.
+//
+// API reference: https://api.cloudflare.com/#devices-update-device-settings-policy
+func (api *API) RestoreDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params RestoreDeviceSettingsPoliciesParams) (DeviceSettingsRestoreReport, error) {
+	if rc.Level != AccountRouteLevel {
+		return DeviceSettingsRestoreReport{}, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	snapshot := params.Snapshot
+
+	policies, err := api.deviceSettingsPoliciesWithDefault(ctx, rc.Identifier)
+	if err != nil {
+		return DeviceSettingsRestoreReport{}, err
+	}
+
+	var currentDefault DeviceSettingsPolicy
+	var custom []DeviceSettingsPolicy
+	for _, policy := range policies {
+		if policy.Default {
+			currentDefault = policy
+			continue
+		}
+		custom = append(custom, policy)
+	}
+
+	matches := matchDeviceSettingsSnapshotPolicies(snapshot.Policies, custom)
+
+	report := DeviceSettingsRestoreReport{DryRun: params.DryRun, PolicyIDs: make(map[string]string)}
+	errs := make(map[string]error)
+	record := func(key string, change DeviceSettingsRestoreChange) {
+		if change.Err != nil {
+			errs[key] = change.Err
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	matched := make(map[string]bool, len(matches))
+	for _, policy := range matches {
+		if policy != nil {
+			matched[*policy.PolicyID] = true
+		}
+	}
+
+	taken := make(map[int]bool)
+	for _, policy := range custom {
+		if matched[*policy.PolicyID] {
+			continue
+		}
+
+		if !params.Prune {
+			if policy.Precedence != nil {
+				taken[*policy.Precedence] = true
+			}
+			continue
+		}
+
+		change := DeviceSettingsRestoreChange{Action: DeviceSettingsRestoreDelete, PolicyID: *policy.PolicyID}
+		if policy.Name != nil {
+			change.Name = *policy.Name
+		}
+		if !params.DryRun {
+			_, change.Err = api.DeleteDeviceSettingsPolicy(ctx, rc, *policy.PolicyID)
+		}
+		record(*policy.PolicyID, change)
+	}
+
+	// A snapshot that failed to capture the default policy leaves it as is.
+	if snapshot.Default.Policy.Default {
+		defaultChange := api.restoreDeviceSettingsSnapshotPolicy(ctx, rc, snapshot.Default, &currentDefault, params.DryRun)
+		defaultChange.SnapshotPolicyID = "default"
+		record(defaultChange.SnapshotPolicyID, defaultChange)
+	}
+
+	targets := make([]int, len(snapshot.Policies))
+	targeted := make(map[int]bool, len(snapshot.Policies))
+	last := 0
+	for i, entry := range snapshot.Policies {
+		precedence := deviceSettingsPolicyRank(entry.Policy)
+		if entry.Policy.Precedence == nil || precedence <= last || taken[precedence] {
+			precedence = last + 1
+			for taken[precedence] {
+				precedence++
+			}
+		}
+		last = precedence
+		targets[i] = precedence
+		targeted[precedence] = true
+	}
+
+	// Policies holding a precedence another policy is restored to are moved
+	// out of the way first, so that e.g. two policies can swap precedences.
+	moveErrs := make(map[int]error)
+	if !params.DryRun {
+		free := last
+		for _, policy := range custom {
+			if policy.Precedence != nil && *policy.Precedence > free {
+				free = *policy.Precedence
+			}
+		}
+
+		for i, current := range matches {
+			if current == nil || current.Precedence == nil || *current.Precedence == targets[i] || !targeted[*current.Precedence] {
+				continue
+			}
+
+			free++
+			body := struct {
+				Precedence int `json:"precedence"`
+			}{free}
+			if _, err := api.patchDeviceSettingsPolicy(ctx, rc, *current.PolicyID, body); err != nil {
+				moveErrs[i] = err
+				continue
+			}
+			current.Precedence = IntPtr(free)
+		}
+	}
+
+	for i, entry := range snapshot.Policies {
+		entry.Policy.Precedence = IntPtr(targets[i])
+
+		snapshotID := ""
+		if entry.Policy.PolicyID != nil {
+			snapshotID = *entry.Policy.PolicyID
+		}
+
+		var change DeviceSettingsRestoreChange
+		if err, ok := moveErrs[i]; ok {
+			change = DeviceSettingsRestoreChange{Action: DeviceSettingsRestoreUpdate, PolicyID: *matches[i].PolicyID, Fields: []string{"precedence"}, Err: err}
+			if entry.Policy.Name != nil {
+				change.Name = *entry.Policy.Name
+			}
+		} else {
+			change = api.restoreDeviceSettingsSnapshotPolicy(ctx, rc, entry, matches[i], params.DryRun)
+		}
+		change.SnapshotPolicyID = snapshotID
+		if change.PolicyID != "" && snapshotID != "" {
+			report.PolicyIDs[snapshotID] = change.PolicyID
+		}
+		record(snapshotID, change)
+	}
+
+	return report, newDeviceBatchError(errs)
+}
+
+// matchDeviceSettingsSnapshotPolicies returns the current policy matching
+// each snapshot policy, by ID and then by unique name, or nil.
+func matchDeviceSettingsSnapshotPolicies(entries []DeviceSettingsSnapshotPolicy, current []DeviceSettingsPolicy) []*DeviceSettingsPolicy {
+	byID := make(map[string]*DeviceSettingsPolicy, len(current))
+	byName := make(map[string][]*DeviceSettingsPolicy)
+	for i := range current {
+		policy := &current[i]
+		if policy.PolicyID == nil {
+			continue
+		}
+		byID[*policy.PolicyID] = policy
+		if policy.Name != nil {
+			byName[*policy.Name] = append(byName[*policy.Name], policy)
+		}
+	}
+
+	matches := make([]*DeviceSettingsPolicy, len(entries))
+	used := make(map[*DeviceSettingsPolicy]bool)
+	for i, entry := range entries {
+		if entry.Policy.PolicyID == nil {
+			continue
+		}
+		if policy, ok := byID[*entry.Policy.PolicyID]; ok {
+			matches[i] = policy
+			used[policy] = true
+		}
+	}
+
+	for i, entry := range entries {
+		if matches[i] != nil || entry.Policy.Name == nil {
+			continue
+		}
+		if candidates := byName[*entry.Policy.Name]; len(candidates) == 1 && !used[candidates[0]] {
+			matches[i] = candidates[0]
+			used[candidates[0]] = true
+		}
+	}
+
+	return matches
+}
+
+// deviceSettingsRestoreIgnoredFields are the fields that aren't compared
+// when restoring a policy, as they are set by the server or restored through
+// their own endpoints.
+var deviceSettingsRestoreIgnoredFields = map[string]bool{
+	"policy_id":         true,
+	"default":           true,
+	"gateway_unique_id": true,
+	"include":           true,
+	"exclude":           true,
+	"fallback_domains":  true,
+}
+
+// restoreDeviceSettingsSnapshotPolicy creates entry, or updates current to
+// match it, along with its lists.
+func (api *API) restoreDeviceSettingsSnapshotPolicy(ctx context.Context, rc *ResourceContainer, entry DeviceSettingsSnapshotPolicy, current *DeviceSettingsPolicy, dryRun bool) DeviceSettingsRestoreChange {
+	desired := entry.Policy
+	isDefault := current != nil && current.Default
+
+	change := DeviceSettingsRestoreChange{Action: DeviceSettingsRestoreUnchanged}
+	if desired.Name != nil {
+		change.Name = *desired.Name
+	}
+
+	policyID := ""
+	if current == nil {
+		change.Action = DeviceSettingsRestoreCreate
+		if !dryRun {
+			params := desired.ToCreateParams()
+			params.AllowEmptyMatch = true
+			created, err := api.CreateDeviceSettingsPolicy(ctx, rc, params)
+			if err != nil {
+				change.Err = err
+				return change
+			}
+			policyID = *created.PolicyID
+		}
+	} else {
+		if !isDefault {
+			policyID = *current.PolicyID
+		}
+
+		for _, field := range DiffDeviceSettingsPolicies(*current, desired) {
+			if deviceSettingsRestoreIgnoredFields[field.Field] || isDefault && deviceSettingsPolicyIdentityFields[field.Field] {
+				continue
+			}
+			// Unset fields can only be restored by resetting them.
+			if isJSONNull(field.After) && !deviceSettingsPolicyResettableFields[DeviceSettingsPolicyResettableField(field.Field)] {
+				continue
+			}
+			change.Fields = append(change.Fields, field.Field)
+		}
+
+		if len(change.Fields) > 0 {
+			change.Action = DeviceSettingsRestoreUpdate
+			if !dryRun {
+				change.Err = api.updateDeviceSettingsSnapshotPolicy(ctx, rc, *current, desired)
+				if change.Err != nil {
+					return change
+				}
+			}
+		}
+	}
+	change.PolicyID = policyID
+
+	lists := []struct {
+		name    string
+		desired int
+		equal   func() (bool, error)
+		replace func() error
+	}{
+		{
+			name:    "include",
+			desired: len(entry.Include),
+			equal: func() (bool, error) {
+				have, err := api.listSplitTunnels(ctx, rc.Identifier, policyID, "include")
+				return len(have) == 0 && len(entry.Include) == 0 || reflect.DeepEqual(have, entry.Include), err
+			},
+			replace: func() error {
+				_, err := api.updateSplitTunnels(ctx, rc.Identifier, policyID, "include", entry.Include)
+				return err
+			},
+		},
+		{
+			name:    "exclude",
+			desired: len(entry.Exclude),
+			equal: func() (bool, error) {
+				have, err := api.listSplitTunnels(ctx, rc.Identifier, policyID, "exclude")
+				return len(have) == 0 && len(entry.Exclude) == 0 || reflect.DeepEqual(have, entry.Exclude), err
+			},
+			replace: func() error {
+				_, err := api.updateSplitTunnels(ctx, rc.Identifier, policyID, "exclude", entry.Exclude)
+				return err
+			},
+		},
+		{
+			name:    "fallback_domains",
+			desired: len(entry.FallbackDomains),
+			equal: func() (bool, error) {
+				have, err := api.listFallbackDomains(ctx, rc.Identifier, policyID)
+				return len(have) == 0 && len(entry.FallbackDomains) == 0 || reflect.DeepEqual(NormalizeFallbackDomains(have), NormalizeFallbackDomains(entry.FallbackDomains)), err
+			},
+			replace: func() error {
+				_, err := api.ReplaceFallbackDomains(ctx, rc.Identifier, policyID, entry.FallbackDomains)
+				return err
+			},
+		},
+	}
+
+	for _, list := range lists {
+		// A policy created in a dry run has no lists to compare yet.
+		if current == nil && dryRun {
+			if list.desired > 0 {
+				change.Lists = append(change.Lists, list.name)
+			}
+			continue
+		}
+
+		equal, err := list.equal()
+		if err != nil {
+			change.Err = err
+			return change
+		}
+		if equal {
+			continue
+		}
+
+		change.Lists = append(change.Lists, list.name)
+		if change.Action == DeviceSettingsRestoreUnchanged {
+			change.Action = DeviceSettingsRestoreUpdate
+		}
+		if !dryRun {
+			if err := list.replace(); err != nil {
+				change.Err = err
+				return change
+			}
+		}
+	}
+
+	return change
+}
+
+// updateDeviceSettingsSnapshotPolicy updates current with the settings of
+// desired that differ.
+func (api *API) updateDeviceSettingsSnapshotPolicy(ctx context.Context, rc *ResourceContainer, current, desired DeviceSettingsPolicy) error {
+	patch := MinimalDeviceSettingsPolicyPatch(current, desired)
+	if !current.Default {
+		// The policy may have been matched by name with another ID.
+		patch.PolicyID = StringPtr(*current.PolicyID)
+		patch.AllowEmptyMatch = true
+		_, err := api.UpdateDeviceSettingsPolicy(ctx, rc, patch)
+		return err
+	}
+
+	// The default policy has no identity of its own.
+	patch.Name, patch.Match, patch.Precedence, patch.Enabled, patch.Description = nil, nil, nil, nil, nil
+	var reset []DeviceSettingsPolicyResettableField
+	for _, field := range patch.Reset {
+		if !deviceSettingsPolicyIdentityFields[string(field)] {
+			reset = append(reset, field)
+		}
+	}
+	patch.Reset = nil
+
+	var params UpdateDefaultDeviceSettingsPolicyParams
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &params); err != nil {
+		return err
+	}
+	params.Reset = reset
+
+	_, err = api.UpdateDefaultDeviceSettingsPolicy(ctx, rc, params)
+	return err
+}
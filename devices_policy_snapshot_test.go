@@ -0,0 +1,216 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDeviceSettingsPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	respond := func(result string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"success": true, "errors": null, "messages": null, "result": %s}`, result)
+		}
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", respond(`[
+		{"policy_id": "second", "name": "Second", "precedence": 20},
+		{"policy_id": "first", "name": "First", "precedence": 10},
+		{"policy_id": "broken", "name": "Broken", "precedence": 30}
+	]`))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", respond(`{"default": true, "name": "Default"}`))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/include", respond(`[]`))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/exclude", respond(`[{"address": "10.0.0.0/8"}]`))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/fallback_domains", respond(`[{"suffix": "internal"}]`))
+	for _, id := range []string{"first", "second"} {
+		mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+id+"/include", respond(`[{"host": "`+id+`.example.com"}]`))
+		mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+id+"/exclude", respond(`[]`))
+		mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+id+"/fallback_domains", respond(`[]`))
+	}
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/broken/include", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := client.SnapshotDeviceSettingsPolicies(context.Background(), ZoneIdentifier(testZoneID), SnapshotDeviceSettingsPoliciesParams{})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+
+	snapshot, err := client.SnapshotDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), SnapshotDeviceSettingsPoliciesParams{Concurrency: 2})
+
+	var batchErr *DeviceBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Contains(t, batchErr.Errors, "broken")
+		assert.Len(t, batchErr.Errors, 1)
+	}
+
+	assert.Equal(t, testAccountID, snapshot.AccountID)
+	assert.Equal(t, "Default", *snapshot.Default.Policy.Name)
+	assert.Equal(t, []SplitTunnel{{Address: "10.0.0.0/8"}}, snapshot.Default.Exclude)
+	assert.Equal(t, []FallbackDomain{{Suffix: "internal"}}, snapshot.Default.FallbackDomains)
+	if assert.Len(t, snapshot.Policies, 2) {
+		assert.Equal(t, "first", *snapshot.Policies[0].Policy.PolicyID)
+		assert.Equal(t, []SplitTunnel{{Host: "first.example.com"}}, snapshot.Policies[0].Include)
+		assert.Equal(t, "second", *snapshot.Policies[1].Policy.PolicyID)
+	}
+
+	b, err := json.Marshal(snapshot)
+	if assert.NoError(t, err) {
+		var decoded DeviceSettingsSnapshot
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, snapshot.Policies, decoded.Policies)
+	}
+}
+
+func TestRestoreDeviceSettingsPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	var writes []string
+	respond := func(w http.ResponseWriter, r *http.Request, result string) {
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			writes = append(writes, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/accounts/"+testAccountID)+" "+string(body))
+			mu.Unlock()
+		}
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{"success": true, "errors": null, "messages": null, "result": %s}`, result)
+	}
+	handle := func(path, result string) {
+		mux.HandleFunc("/accounts/"+testAccountID+path, func(w http.ResponseWriter, r *http.Request) {
+			respond(w, r, result)
+		})
+	}
+
+	handle("/devices/policies", `[
+		{"policy_id": "keep", "name": "Keep", "match": "identity.email == \"a@example.com\"", "precedence": 10, "support_url": "https://a.example.com"},
+		{"policy_id": "stale", "name": "Stale", "match": "identity.email == \"b@example.com\"", "precedence": 20}
+	]`)
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			respond(w, r, `{"policy_id": "new-id", "name": "New"}`)
+			return
+		}
+		respond(w, r, `{"default": true, "captive_portal": 180}`)
+	})
+	for _, prefix := range []string{"/devices/policy", "/devices/policy/keep", "/devices/policy/new-id"} {
+		handle(prefix+"/include", `[]`)
+		handle(prefix+"/exclude", `[]`)
+		handle(prefix+"/fallback_domains", `[]`)
+	}
+	handle("/devices/policy/keep", `{"policy_id": "keep"}`)
+	handle("/devices/policy/stale", `[]`)
+
+	snapshot := DeviceSettingsSnapshot{
+		Default: DeviceSettingsSnapshotPolicy{
+			Policy: DeviceSettingsPolicy{Default: true, CaptivePortal: IntPtr(300)},
+		},
+		Policies: []DeviceSettingsSnapshotPolicy{
+			{
+				Policy: DeviceSettingsPolicy{
+					PolicyID:   StringPtr("old-id"),
+					Name:       StringPtr("Keep"),
+					Match:      StringPtr(`identity.email == "a@example.com"`),
+					Precedence: IntPtr(10),
+					SupportURL: StringPtr("https://b.example.com"),
+				},
+			},
+			{
+				Policy: DeviceSettingsPolicy{
+					PolicyID:   StringPtr("gone"),
+					Name:       StringPtr("New"),
+					Match:      StringPtr(`identity.email == "c@example.com"`),
+					Precedence: IntPtr(20),
+				},
+				Exclude: []SplitTunnel{{Address: "10.0.0.0/8"}},
+			},
+		},
+	}
+
+	_, err := client.RestoreDeviceSettingsPolicies(context.Background(), ZoneIdentifier(testZoneID), RestoreDeviceSettingsPoliciesParams{Snapshot: snapshot})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+
+	report, err := client.RestoreDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), RestoreDeviceSettingsPoliciesParams{Snapshot: snapshot, Prune: true, DryRun: true})
+	if assert.NoError(t, err) {
+		assert.Empty(t, writes)
+		assert.Equal(t, []DeviceSettingsRestoreChange{
+			{Action: DeviceSettingsRestoreDelete, PolicyID: "stale", Name: "Stale"},
+			{Action: DeviceSettingsRestoreUpdate, SnapshotPolicyID: "default", Fields: []string{"captive_portal"}},
+			{Action: DeviceSettingsRestoreUpdate, SnapshotPolicyID: "old-id", PolicyID: "keep", Name: "Keep", Fields: []string{"support_url"}},
+			{Action: DeviceSettingsRestoreCreate, SnapshotPolicyID: "gone", Name: "New", Lists: []string{"exclude"}},
+		}, report.Changes)
+		assert.Equal(t, map[string]string{"old-id": "keep"}, report.PolicyIDs)
+	}
+
+	report, err = client.RestoreDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), RestoreDeviceSettingsPoliciesParams{Snapshot: snapshot, Prune: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"old-id": "keep", "gone": "new-id"}, report.PolicyIDs)
+		assert.Equal(t, []string{
+			"DELETE /devices/policy/stale ",
+			`PATCH /devices/policy {"captive_portal":300,"exclude_office_ips":null}`,
+			`PATCH /devices/policy/keep {"support_url":"https://b.example.com","exclude_office_ips":null}`,
+			`POST /devices/policy {"precedence":20,"name":"New","match":"identity.email == \"c@example.com\"","exclude_office_ips":null}`,
+			`PUT /devices/policy/new-id/exclude [{"address":"10.0.0.0/8"}]`,
+		}, writes)
+	}
+}
+
+func TestRestoreDeviceSettingsPoliciesSwapsPrecedences(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var writes []string
+	handle := func(path, result string) {
+		mux.HandleFunc("/accounts/"+testAccountID+path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				body, _ := io.ReadAll(r.Body)
+				writes = append(writes, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/accounts/"+testAccountID)+" "+string(body))
+			}
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"success": true, "errors": null, "messages": null, "result": %s}`, result)
+		})
+	}
+
+	handle("/devices/policies", `[
+		{"policy_id": "a", "name": "A", "match": "identity.email == \"a@example.com\"", "precedence": 10},
+		{"policy_id": "b", "name": "B", "match": "identity.email == \"b@example.com\"", "precedence": 20}
+	]`)
+	handle("/devices/policy", `{"default": true}`)
+	for _, id := range []string{"a", "b"} {
+		handle("/devices/policy/"+id, fmt.Sprintf(`{"policy_id": %q}`, id))
+		handle("/devices/policy/"+id+"/include", `[]`)
+		handle("/devices/policy/"+id+"/exclude", `[]`)
+		handle("/devices/policy/"+id+"/fallback_domains", `[{"suffix": "corp.example.com"}]`)
+	}
+
+	// Fallback domains differing only in their spelling are left as is.
+	fallback := []FallbackDomain{{Suffix: "Corp.Example.com."}}
+	snapshot := DeviceSettingsSnapshot{
+		Policies: []DeviceSettingsSnapshotPolicy{
+			{Policy: DeviceSettingsPolicy{PolicyID: StringPtr("b"), Name: StringPtr("B"), Match: StringPtr(`identity.email == "b@example.com"`), Precedence: IntPtr(10)}, FallbackDomains: fallback},
+			{Policy: DeviceSettingsPolicy{PolicyID: StringPtr("a"), Name: StringPtr("A"), Match: StringPtr(`identity.email == "a@example.com"`), Precedence: IntPtr(20)}, FallbackDomains: fallback},
+		},
+	}
+
+	_, err := client.RestoreDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), RestoreDeviceSettingsPoliciesParams{Snapshot: snapshot})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			`PATCH /devices/policy/b {"precedence":21}`,
+			`PATCH /devices/policy/a {"precedence":22}`,
+			`PATCH /devices/policy/b {"precedence":10,"exclude_office_ips":null}`,
+			`PATCH /devices/policy/a {"precedence":20,"exclude_office_ips":null}`,
+		}, writes)
+	}
+}
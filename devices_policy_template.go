@@ -0,0 +1,60 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrUnresolvedDeviceSettingsPolicyTemplateVariable is returned by
+// RenderDeviceSettingsPolicyTemplate when a placeholder has no value.
+var ErrUnresolvedDeviceSettingsPolicyTemplateVariable = errors.New("unresolved device settings policy template variable")
+
+var deviceSettingsPolicyTemplateVariable = regexp.MustCompile(`\$\{([^{}]*)\}`)
+
+// RenderDeviceSettingsPolicyTemplate returns a copy of template with the
+// `${name}` placeholders in its Name, Match, Description and SupportURL
+// replaced by the value of name in vars, e.g. to create one policy per team
+// from a single template. Values are substituted as is: a value used inside
+// a quoted string of Match must not contain quotes or backslashes, or it
+// has to be escaped beforehand.
+//
+// An error wrapping ErrUnresolvedDeviceSettingsPolicyTemplateVariable and
+// naming every missing variable is returned when vars lacks a value for a
+// placeholder.
+func RenderDeviceSettingsPolicyTemplate(template CreateDeviceSettingsPolicyParams, vars map[string]string) (CreateDeviceSettingsPolicyParams, error) {
+	rendered := template
+	unresolved := map[string]bool{}
+
+	for _, field := range []**string{&rendered.Name, &rendered.Match, &rendered.Description, &rendered.SupportURL} {
+		if *field == nil {
+			continue
+		}
+
+		value := deviceSettingsPolicyTemplateVariable.ReplaceAllStringFunc(**field, func(placeholder string) string {
+			name := placeholder[2 : len(placeholder)-1]
+			value, ok := vars[name]
+			if !ok {
+				unresolved[name] = true
+				return placeholder
+			}
+			return value
+		})
+		// Copy the value so that rendering never modifies template.
+		*field = &value
+	}
+
+	if len(unresolved) > 0 {
+		names := make([]string, 0, len(unresolved))
+		for name := range unresolved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return CreateDeviceSettingsPolicyParams{}, fmt.Errorf("%w: %s", ErrUnresolvedDeviceSettingsPolicyTemplateVariable, strings.Join(names, ", "))
+	}
+
+	return rendered, nil
+}
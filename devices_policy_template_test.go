@@ -0,0 +1,35 @@
+package cloudflare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDeviceSettingsPolicyTemplate(t *testing.T) {
+	template := CreateDeviceSettingsPolicyParams{
+		Name:          StringPtr("${team} laptops"),
+		Match:         StringPtr(`any(identity.groups.name[*] in {"${team}"})`),
+		Description:   StringPtr("Managed policy for ${team}, owned by ${owner}"),
+		SupportURL:    StringPtr("https://support.example.com"),
+		Precedence:    IntPtr(10),
+		CaptivePortal: IntPtr(180),
+	}
+
+	rendered, err := RenderDeviceSettingsPolicyTemplate(template, map[string]string{"team": "engineering", "owner": "it"})
+	require.NoError(t, err)
+	assert.Equal(t, "engineering laptops", *rendered.Name)
+	assert.Equal(t, `any(identity.groups.name[*] in {"engineering"})`, *rendered.Match)
+	assert.Equal(t, "Managed policy for engineering, owned by it", *rendered.Description)
+	assert.Equal(t, "https://support.example.com", *rendered.SupportURL)
+	assert.Equal(t, 10, *rendered.Precedence)
+	assert.Equal(t, 180, *rendered.CaptivePortal)
+
+	// The template is left untouched.
+	assert.Equal(t, "${team} laptops", *template.Name)
+
+	_, err = RenderDeviceSettingsPolicyTemplate(template, map[string]string{})
+	assert.ErrorIs(t, err, ErrUnresolvedDeviceSettingsPolicyTemplateVariable)
+	assert.EqualError(t, err, "unresolved device settings policy template variable: owner, team")
+}
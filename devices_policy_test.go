@@ -1,11 +1,18 @@
 package cloudflare
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -253,6 +260,97 @@ func TestCreateDeviceSettingsPolicy(t *testing.T) {
 	}
 }
 
+func TestCreateDeviceSettingsPolicyEmptyBody(t *testing.T) {
+	setup()
+	defer teardown()
+
+	location := "/accounts/" + testAccountID + "/devices/policy/" + deviceSettingsPolicyID
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		if location != "" {
+			w.Header().Set("Location", location)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	})
+
+	actual, err := client.CreateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{
+		Name:  StringPtr("test"),
+		Match: StringPtr(deviceSettingsPolicyMatch),
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, nonDefaultDeviceSettingsPolicy, actual)
+	}
+
+	location = ""
+	_, err = client.CreateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{
+		Name:  StringPtr("test"),
+		Match: StringPtr(deviceSettingsPolicyMatch),
+	})
+	assert.ErrorIs(t, err, ErrMissingDeviceSettingsPolicyID)
+}
+
+func TestDeviceSettingsPolicyRequiresMatch(t *testing.T) {
+	setup()
+	defer teardown()
+
+	requests := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	}
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", handler)
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, handler)
+
+	_, err := client.CreateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{
+		Name: StringPtr("test"),
+	})
+	assert.ErrorIs(t, err, ErrMissingDeviceSettingsPolicyMatch)
+
+	_, err = client.UpdateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceSettingsPolicyParams{
+		PolicyID: &deviceSettingsPolicyID,
+		Match:    StringPtr(" "),
+	})
+	assert.ErrorIs(t, err, ErrMissingDeviceSettingsPolicyMatch)
+	assert.Equal(t, 0, requests)
+
+	_, err = client.CreateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{
+		Name:            StringPtr("placeholder"),
+		AllowEmptyMatch: true,
+	})
+	assert.NoError(t, err)
+
+	_, err = client.UpdateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceSettingsPolicyParams{
+		PolicyID: &deviceSettingsPolicyID,
+		Name:     StringPtr("renamed"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestDeviceSettingsPolicyIDFromLocation(t *testing.T) {
+	assert.Equal(t, "abc", deviceSettingsPolicyIDFromLocation("https://api.cloudflare.com/client/v4/accounts/1/devices/policy/abc"))
+	assert.Equal(t, "abc", deviceSettingsPolicyIDFromLocation("/accounts/1/devices/policy/abc/"))
+	assert.Equal(t, "", deviceSettingsPolicyIDFromLocation("/accounts/1/devices/policy"))
+	assert.Equal(t, "", deviceSettingsPolicyIDFromLocation(""))
+}
+
 func TestUpdateDefaultDeviceSettingsPolicy(t *testing.T) {
 	setup()
 	defer teardown()
@@ -306,6 +404,139 @@ func TestUpdateDeviceSettingsPolicy(t *testing.T) {
 	}
 }
 
+func TestUpdateDeviceSettingsPolicy_LogsWarnings(t *testing.T) {
+	var logged bytes.Buffer
+	setup(UsingLogger(log.New(&logged, "", 0)))
+	defer teardown()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": [{"code": 10000, "message": "field gateway_unique_id is deprecated"}],
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, handler)
+
+	_, err := client.UpdateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceSettingsPolicyParams{
+		PolicyID: &deviceSettingsPolicyID,
+	})
+
+	if assert.NoError(t, err) {
+		assert.Contains(t, logged.String(), "field gateway_unique_id is deprecated")
+	}
+}
+
+func TestUpdateDeviceSettingsPolicyOnChange(t *testing.T) {
+	setup()
+	defer teardown()
+
+	before := strings.Replace(strings.Replace(nonDefaultDeviceSettingsPolicyJson,
+		`"precedence": 10`, `"precedence": 20`, 1),
+		`"description":"Test Description"`, `"description":"Old Description"`, 1)
+
+	var methods []string
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("content-type", "application/json")
+		result := nonDefaultDeviceSettingsPolicyJson
+		if r.Method == http.MethodGet {
+			result = before
+		}
+		fmt.Fprintf(w, `{"success": true, "errors": null, "messages": null, "result": %s}`, result)
+	})
+
+	var changes []DeviceSettingsPolicyFieldChange
+	_, err := client.UpdateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceSettingsPolicyParams{
+		PolicyID:    &deviceSettingsPolicyID,
+		Precedence:  IntPtr(10),
+		Description: StringPtr("Test Description"),
+		OnChange: func(c []DeviceSettingsPolicyFieldChange) {
+			changes = c
+		},
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{http.MethodGet, http.MethodPatch}, methods)
+		assert.Equal(t, []DeviceSettingsPolicyFieldChange{
+			{Field: "description", Before: json.RawMessage(`"Old Description"`), After: json.RawMessage(`"Test Description"`)},
+			{Field: "precedence", Before: json.RawMessage(`20`), After: json.RawMessage(`10`)},
+		}, changes)
+	}
+
+	// Without a callback the policy isn't fetched first.
+	methods = nil
+	_, err = client.UpdateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceSettingsPolicyParams{
+		PolicyID: &deviceSettingsPolicyID,
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{http.MethodPatch}, methods)
+	}
+}
+
+func TestDiffDeviceSettingsPolicies(t *testing.T) {
+	before := DeviceSettingsPolicy{Name: StringPtr("test"), SupportURL: StringPtr("https://support.example.com"), Include: &[]SplitTunnel{}}
+	after := DeviceSettingsPolicy{Name: StringPtr("test"), CaptivePortal: IntPtr(180)}
+
+	assert.Equal(t, []DeviceSettingsPolicyFieldChange{
+		{Field: "captive_portal", Before: json.RawMessage(`null`), After: json.RawMessage(`180`)},
+		{Field: "support_url", Before: json.RawMessage(`"https://support.example.com"`), After: json.RawMessage(`null`)},
+	}, DiffDeviceSettingsPolicies(before, after))
+
+	assert.Empty(t, DiffDeviceSettingsPolicies(before, before))
+
+	before.FallbackDomains = &[]FallbackDomain{{Suffix: "corp.example.com"}}
+	after = before
+	after.FallbackDomains = &[]FallbackDomain{{Suffix: "Corp.Example.com."}}
+	assert.Empty(t, DiffDeviceSettingsPolicies(before, after))
+}
+
+func TestSetRegisterInterfaceIPWithDNS(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var requests []string
+	handler := func(result string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, map[string]interface{}{"register_interface_ip_with_dns": true}, body)
+			requests = append(requests, r.URL.Path)
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{
+				"success": true,
+				"errors": null,
+				"messages": null,
+				"result": %s
+			}`, result)
+		}
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", handler(defaultDeviceSettingsPolicyJson))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, handler(nonDefaultDeviceSettingsPolicyJson))
+
+	_, err := client.SetRegisterInterfaceIPWithDNS(context.Background(), AccountIdentifier(testAccountID), SetRegisterInterfaceIPWithDNSParams{
+		PolicyID: deviceSettingsPolicyID,
+		Enabled:  true,
+	})
+	assert.NoError(t, err)
+
+	_, err = client.SetRegisterInterfaceIPWithDNS(context.Background(), AccountIdentifier(testAccountID), SetRegisterInterfaceIPWithDNSParams{
+		Enabled: true,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"/accounts/" + testAccountID + "/devices/policy/" + deviceSettingsPolicyID,
+		"/accounts/" + testAccountID + "/devices/policy",
+	}, requests)
+}
+
 func TestDeleteDeviceSettingsPolicy(t *testing.T) {
 	setup()
 	defer teardown()
@@ -421,3 +652,1013 @@ func TestListDeviceSettingsPolicies(t *testing.T) {
 		assert.Len(t, actual, 1)
 	}
 }
+
+func TestVerifyDeviceAPIPermissions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, defaultDeviceSettingsPolicyJson)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", handler)
+
+	err := client.VerifyDeviceAPIPermissions(context.Background(), AccountIdentifier(testAccountID), VerifyDeviceAPIPermissionsParams{})
+	assert.NoError(t, err)
+}
+
+func TestVerifyDeviceAPIPermissionsForbidden(t *testing.T) {
+	setup()
+	defer teardown()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, `{
+			"success": false,
+			"errors": [{"code": 10000, "message": "Authentication error"}],
+			"messages": [],
+			"result": null
+		}`)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", handler)
+
+	err := client.VerifyDeviceAPIPermissions(context.Background(), AccountIdentifier(testAccountID), VerifyDeviceAPIPermissionsParams{})
+	assert.ErrorIs(t, err, ErrInsufficientDevicePermissions)
+	assert.ErrorContains(t, err, "Authentication error")
+}
+
+func TestDeviceSettingsPoliciesEqual(t *testing.T) {
+	copyOf := func(p DeviceSettingsPolicy) DeviceSettingsPolicy { return p }
+
+	assert.True(t, DeviceSettingsPoliciesEqual(nonDefaultDeviceSettingsPolicy, copyOf(nonDefaultDeviceSettingsPolicy)))
+	assert.False(t, DeviceSettingsPoliciesEqual(defaultDeviceSettingsPolicy, nonDefaultDeviceSettingsPolicy))
+
+	// Distinct pointers to equal values are equal.
+	a := copyOf(nonDefaultDeviceSettingsPolicy)
+	a.Name = StringPtr("test")
+	assert.True(t, DeviceSettingsPoliciesEqual(a, nonDefaultDeviceSettingsPolicy))
+
+	// A nil pointer differs from a pointer to the zero value.
+	a.AllowUpdates = nil
+	b := copyOf(nonDefaultDeviceSettingsPolicy)
+	b.AllowUpdates = BoolPtr(false)
+	assert.False(t, DeviceSettingsPoliciesEqual(a, b))
+
+	// Nested list contents are compared.
+	a = copyOf(nonDefaultDeviceSettingsPolicy)
+	a.Exclude = &[]SplitTunnel{{Address: "10.0.0.0/8"}}
+	assert.False(t, DeviceSettingsPoliciesEqual(a, nonDefaultDeviceSettingsPolicy))
+
+	// Empty and unset lists are only equal when requested.
+	a = copyOf(nonDefaultDeviceSettingsPolicy)
+	a.Include = &[]SplitTunnel{}
+	a.FallbackDomains = &[]FallbackDomain{
+		{Suffix: "invalid", DNSServer: []string{}},
+		{Suffix: "test"},
+	}
+	assert.False(t, DeviceSettingsPoliciesEqual(a, nonDefaultDeviceSettingsPolicy))
+	assert.True(t, DeviceSettingsPoliciesEqual(a, nonDefaultDeviceSettingsPolicy, DeviceSettingsPolicyEquateEmpty()))
+
+	// Equating empty lists must not modify the compared policies.
+	assert.Equal(t, []string{}, (*a.FallbackDomains)[0].DNSServer)
+
+	// Fallback domains are only normalized when requested.
+	a = copyOf(nonDefaultDeviceSettingsPolicy)
+	a.FallbackDomains = &[]FallbackDomain{{Suffix: "INVALID."}, {Suffix: "test"}}
+	assert.False(t, DeviceSettingsPoliciesEqual(a, nonDefaultDeviceSettingsPolicy))
+	assert.True(t, DeviceSettingsPoliciesEqual(a, nonDefaultDeviceSettingsPolicy, DeviceSettingsPolicyNormalizeFallbackDomains()))
+}
+
+func TestScheduleDeviceSettingsPolicyUpdate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var updatedAt time.Time
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+		updatedAt = time.Now()
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, handler)
+
+	at := time.Now().Add(50 * time.Millisecond)
+	actual, err := client.ScheduleDeviceSettingsPolicyUpdate(context.Background(), AccountIdentifier(testAccountID), ScheduleDeviceSettingsPolicyUpdateParams{
+		At: at,
+		Update: UpdateDeviceSettingsPolicyParams{
+			PolicyID:   &deviceSettingsPolicyID,
+			Precedence: IntPtr(10),
+		},
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, nonDefaultDeviceSettingsPolicy, actual)
+		assert.False(t, updatedAt.Before(at))
+	}
+}
+
+func TestScheduleDeviceSettingsPolicyUpdateCancelled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("update should not be sent once the context is cancelled")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := client.ScheduleDeviceSettingsPolicyUpdate(ctx, AccountIdentifier(testAccountID), ScheduleDeviceSettingsPolicyUpdateParams{
+		At:     time.Now().Add(time.Hour),
+		Update: UpdateDeviceSettingsPolicyParams{PolicyID: &deviceSettingsPolicyID},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAnyDeviceSettingsPolicy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var pages []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+
+		policy := defaultDeviceSettingsPolicyJson
+		if page == "2" {
+			policy = nonDefaultDeviceSettingsPolicyJson
+		}
+
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [%s],
+			"result_info": {
+				"count": 1,
+				"page": %s,
+				"per_page": 1,
+				"total_count": 3
+			}
+		}`, policy, page)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", handler)
+
+	found, policy, err := client.AnyDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), func(p DeviceSettingsPolicy) bool {
+		return !p.Default
+	})
+
+	if assert.NoError(t, err) {
+		assert.True(t, found)
+		assert.Equal(t, &nonDefaultDeviceSettingsPolicy, policy)
+		assert.Equal(t, []string{"1", "2"}, pages)
+	}
+
+	pages = nil
+	policy, err = client.FindDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), func(p DeviceSettingsPolicy) bool {
+		return p.ServiceModeV2 != nil && p.ServiceModeV2.Mode == proxy
+	})
+
+	if assert.NoError(t, err) {
+		assert.Nil(t, policy)
+		assert.Equal(t, []string{"1", "2", "3"}, pages)
+	}
+}
+
+func TestGetDeviceSettingsPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var requests int32
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success": false, "errors": [{"code": 1001, "message": "not found"}], "messages": []}`)
+	})
+
+	actual, err := client.GetDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), GetDeviceSettingsPoliciesParams{
+		PolicyIDs:   []string{deviceSettingsPolicyID, "missing", deviceSettingsPolicyID},
+		Concurrency: 2,
+	})
+
+	assert.Equal(t, map[string]DeviceSettingsPolicy{deviceSettingsPolicyID: nonDefaultDeviceSettingsPolicy}, actual)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	var batchErr *DeviceBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Len(t, batchErr.Errors, 1)
+		var notFoundErr *NotFoundError
+		assert.ErrorAs(t, batchErr.Errors["missing"], &notFoundErr)
+	}
+}
+
+func TestGetDeviceSettingsPolicyGatewayDetails(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	})
+
+	locations := `[{"id": "loc-1", "name": "office", "doh_subdomain": "t1235"}]`
+	mux.HandleFunc("/accounts/"+testAccountID+"/gateway/locations", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, locations)
+	})
+
+	actual, err := client.GetDeviceSettingsPolicyGatewayDetails(context.Background(), AccountIdentifier(testAccountID), GetDeviceSettingsPolicyGatewayDetailsParams{PolicyID: deviceSettingsPolicyID})
+	if assert.NoError(t, err) {
+		assert.Equal(t, deviceSettingsPolicyID, actual.PolicyID)
+		assert.Equal(t, "t1235", actual.GatewayUniqueID)
+		if assert.NotNil(t, actual.Location) {
+			assert.Equal(t, "loc-1", actual.Location.ID)
+			assert.Equal(t, "office", actual.Location.Name)
+		}
+	}
+
+	locations = `[{"id": "loc-2", "name": "branch", "doh_subdomain": "other"}]`
+	_, err = client.GetDeviceSettingsPolicyGatewayDetails(context.Background(), AccountIdentifier(testAccountID), GetDeviceSettingsPolicyGatewayDetailsParams{PolicyID: deviceSettingsPolicyID})
+	assert.ErrorIs(t, err, ErrDeviceSettingsPolicyGatewayNotFound)
+}
+
+func TestCreateDeviceSettingsPolicyAtPosition(t *testing.T) {
+	setup()
+	defer teardown()
+
+	policies := `[{"policy_id": "a", "precedence": 20}, {"policy_id": "b", "precedence": 40}]`
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s,
+			"result_info": {"page": 1, "per_page": 20, "count": 2, "total_count": 2, "total_pages": 1}
+		}`, policies)
+	})
+
+	var precedence int
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		var body CreateDeviceSettingsPolicyParams
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		precedence = *body.Precedence
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	})
+
+	create := func(position DeviceSettingsPolicyPosition) error {
+		_, err := client.CreateDeviceSettingsPolicyAtPosition(context.Background(), AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyAtPositionParams{
+			Policy:   CreateDeviceSettingsPolicyParams{Name: StringPtr("test"), Match: StringPtr(deviceSettingsPolicyMatch), Precedence: IntPtr(1000)},
+			Position: position,
+		})
+		return err
+	}
+
+	if assert.NoError(t, create(DeviceSettingsPolicyPositionTop)) {
+		assert.Equal(t, 10, precedence)
+	}
+
+	if assert.NoError(t, create(DeviceSettingsPolicyPositionBottom)) {
+		assert.Equal(t, 50, precedence)
+	}
+
+	policies = `[]`
+	if assert.NoError(t, create(DeviceSettingsPolicyPositionTop)) {
+		assert.Equal(t, 10, precedence)
+	}
+
+	policies = `[{"policy_id": "a", "precedence": 1}]`
+	assert.ErrorIs(t, create(DeviceSettingsPolicyPositionTop), ErrNoDeviceSettingsPolicyPrecedenceAvailable)
+	assert.ErrorContains(t, create("middle"), "invalid device settings policy position")
+}
+
+func TestGetDeviceSettingsEffective(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {"default": true, "enabled": true, "support_url": "https://support.example.com", "captive_portal": 180}
+		}`)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {"policy_id": %q, "name": "proxy", "captive_portal": 60, "service_mode_v2": {"mode": "proxy", "port": 3000}}
+		}`, deviceSettingsPolicyID)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/settings", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {"gateway_proxy_enabled": false, "gateway_udp_proxy_enabled": false}
+		}`)
+	})
+
+	actual, err := client.GetDeviceSettingsEffective(context.Background(), AccountIdentifier(testAccountID), GetDeviceSettingsEffectiveParams{PolicyID: deviceSettingsPolicyID})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 60, *actual.Policy.CaptivePortal)
+		assert.Nil(t, actual.Policy.SupportURL, "unset fields are not taken from the default policy")
+		assert.Len(t, actual.Overrides, 1)
+		assert.Contains(t, actual.Overrides, "service_mode_v2")
+	}
+
+	actual, err = client.GetDeviceSettingsEffective(context.Background(), AccountIdentifier(testAccountID), GetDeviceSettingsEffectiveParams{})
+	if assert.NoError(t, err) {
+		assert.True(t, actual.Policy.Default)
+		assert.Equal(t, "https://support.example.com", *actual.Policy.SupportURL)
+		assert.Empty(t, actual.Overrides)
+	}
+
+	_, err = client.GetDeviceSettingsEffective(context.Background(), ZoneIdentifier(testZoneID), GetDeviceSettingsEffectiveParams{})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+}
+
+// TestDeviceFunctions_ConcurrentUse shares a single client and a single set of
+// params between goroutines. Run it with -race to detect shared mutable state.
+func TestDeviceFunctions_ConcurrentUse(t *testing.T) {
+	setup(Headers(http.Header{"X-Shared": make([]string, 0, 4)}))
+	defer teardown()
+
+	respond := func(result string, resultInfo string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s%s}`, result, resultInfo)
+		}
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		respond(fmt.Sprintf("[%s]", nonDefaultDeviceSettingsPolicyJson), fmt.Sprintf(`, "result_info": {"page": %s, "per_page": 1, "count": 1, "total_count": 2, "total_pages": 2}`, page))(w, r)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", respond(defaultDeviceSettingsPolicyJson, ""))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/exclude", respond(`[{"address": "10.0.0.0/8"}]`, ""))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/fallback_domains", respond(`[{"suffix": "example.com"}]`, ""))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", respond(`[{"id": "device"}]`, ""))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/posture", respond(`[{"id": "rule", "type": "file", "name": "rule"}]`, ""))
+
+	ctx := context.Background()
+	rc := AccountIdentifier(testAccountID)
+	params := ListDeviceSettingsPoliciesParams{}
+
+	calls := []func() error{
+		func() error {
+			policies, _, err := client.ListDeviceSettingsPolicies(ctx, rc, params)
+			if err == nil && len(policies) != 2 {
+				err = fmt.Errorf("expected 2 policies, got %d", len(policies))
+			}
+			return err
+		},
+		func() error {
+			_, err := client.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+			return err
+		},
+		func() error {
+			_, err := client.ListSplitTunnels(ctx, testAccountID, "exclude")
+			return err
+		},
+		func() error {
+			_, err := client.ListFallbackDomains(ctx, testAccountID)
+			return err
+		},
+		func() error {
+			_, err := client.ListTeamsDevices(ctx, testAccountID)
+			return err
+		},
+		func() error {
+			_, _, err := client.DevicePostureRules(ctx, testAccountID)
+			return err
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10*len(calls))
+	for i := 0; i < 10; i++ {
+		for _, call := range calls {
+			wg.Add(1)
+			go func(call func() error) {
+				defer wg.Done()
+				errs <- call()
+			}(call)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, ListDeviceSettingsPoliciesParams{}, params)
+}
+
+func TestUpdateDeviceSettingsPolicy_Reset(t *testing.T) {
+	setup()
+	defer teardown()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]interface{}{
+			"name":               "engineering",
+			"support_url":        nil,
+			"captive_portal":     nil,
+			"exclude_office_ips": nil,
+		}, body)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": %s
+		}`, nonDefaultDeviceSettingsPolicyJson)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, handler)
+
+	_, err := client.UpdateDeviceSettingsPolicy(context.Background(), AccountIdentifier(testAccountID), UpdateDeviceSettingsPolicyParams{
+		PolicyID: &deviceSettingsPolicyID,
+		Name:     StringPtr("engineering"),
+		Reset:    []DeviceSettingsPolicyResettableField{DeviceSettingsPolicyResetSupportURL, DeviceSettingsPolicyResetCaptivePortal},
+	})
+	assert.NoError(t, err)
+
+	_, err = json.Marshal(UpdateDeviceSettingsPolicyParams{
+		SupportURL: StringPtr("https://support.example.com"),
+		Reset:      []DeviceSettingsPolicyResettableField{DeviceSettingsPolicyResetSupportURL},
+	})
+	assert.Error(t, err)
+
+	_, err = json.Marshal(UpdateDefaultDeviceSettingsPolicyParams{
+		Reset: []DeviceSettingsPolicyResettableField{"name"},
+	})
+	assert.Error(t, err)
+
+	body, err := json.Marshal(UpdateDefaultDeviceSettingsPolicyParams{AutoConnect: IntPtr(0)})
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"auto_connect": 0, "exclude_office_ips": null}`, string(body))
+	}
+}
+
+func TestListDeviceSettingsPolicies_ResolveSplitTunnelMode(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var fetched int32
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"policy_id": "include-policy", "default": false, "precedence": 10},
+				{"policy_id": "known-policy", "default": false, "precedence": 20, "exclude": [{"address": "10.0.0.0/8"}]},
+				{"default": true}
+			],
+			"result_info": {"count": 3, "page": 1, "per_page": 20, "total_count": 3}
+		}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/include-policy", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {"policy_id": "include-policy", "default": false, "include": [{"address": "192.0.2.0/24"}]}
+		}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {"default": true, "exclude": [{"address": "100.64.0.0/10"}]}
+		}`)
+	})
+
+	policies, _, err := client.ListDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), ListDeviceSettingsPoliciesParams{
+		ResolveSplitTunnelMode: true,
+	})
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+		var modes []string
+		for _, policy := range policies {
+			modes = append(modes, policy.SplitTunnelMode())
+		}
+		assert.Equal(t, []string{"include", "exclude", "exclude"}, modes)
+	}
+}
+
+func TestDeviceSettingsPolicyFingerprint(t *testing.T) {
+	a := DeviceSettingsPolicy{
+		PolicyID: StringPtr("a842fa8a-a583-482e-9cd9-eb43362949fd"),
+		Name:     StringPtr("engineering"),
+		Exclude: &[]SplitTunnel{
+			{Address: "192.0.2.0/24"},
+			{Host: "example.com"},
+			{Address: "10.0.0.0/8"},
+		},
+		FallbackDomains: &[]FallbackDomain{
+			{Suffix: "Corp.Example.com."},
+			{Suffix: "intranet", DNSServer: []string{}},
+		},
+		Include: &[]SplitTunnel{},
+	}
+	b := DeviceSettingsPolicy{
+		PolicyID: StringPtr("2f4ab5a4-c8b4-4b6e-a6e3-d4d5b1a7c1f2"),
+		Name:     StringPtr("engineering"),
+		Exclude: &[]SplitTunnel{
+			{Address: "10.0.0.0/8"},
+			{Address: "192.0.2.0/24"},
+			{Host: "example.com"},
+		},
+		FallbackDomains: &[]FallbackDomain{
+			{Suffix: "intranet"},
+			{Suffix: "corp.example.com"},
+		},
+	}
+
+	assert.Len(t, DeviceSettingsPolicyFingerprint(a), 64)
+	assert.Equal(t, DeviceSettingsPolicyFingerprint(a), DeviceSettingsPolicyFingerprint(b))
+	assert.Equal(t, "Corp.Example.com.", (*a.FallbackDomains)[0].Suffix)
+	assert.Equal(t, "192.0.2.0/24", (*a.Exclude)[0].Address)
+
+	b.Name = StringPtr("finance")
+	assert.NotEqual(t, DeviceSettingsPolicyFingerprint(a), DeviceSettingsPolicyFingerprint(b))
+}
+
+func TestDeviceSettingsPolicy_ToParams(t *testing.T) {
+	policy := DeviceSettingsPolicy{
+		PolicyID:        StringPtr(deviceSettingsPolicyID),
+		Name:            StringPtr("engineering"),
+		Match:           StringPtr(`identity.email == "test@example.com"`),
+		Precedence:      IntPtr(10),
+		Enabled:         BoolPtr(true),
+		ServiceModeV2:   &ServiceModeV2{Mode: proxy, Port: 8080},
+		CaptivePortal:   IntPtr(180),
+		Include:         &[]SplitTunnel{{Address: "10.0.0.0/8"}},
+		GatewayUniqueID: StringPtr("t1235"),
+		Default:         false,
+	}
+
+	update := policy.ToUpdateParams()
+	assert.Equal(t, UpdateDeviceSettingsPolicyParams{
+		PolicyID:      StringPtr(deviceSettingsPolicyID),
+		Name:          StringPtr("engineering"),
+		Match:         StringPtr(`identity.email == "test@example.com"`),
+		Precedence:    IntPtr(10),
+		Enabled:       BoolPtr(true),
+		ServiceModeV2: &ServiceModeV2{Mode: proxy, Port: 8080},
+		CaptivePortal: IntPtr(180),
+	}, update)
+
+	*update.CaptivePortal = 60
+	update.ServiceModeV2.Port = 40000
+	assert.Equal(t, 180, *policy.CaptivePortal)
+	assert.Equal(t, 8080, policy.ServiceModeV2.Port)
+
+	create := policy.ToCreateParams()
+	assert.Equal(t, "engineering", *create.Name)
+	assert.Equal(t, 10, *create.Precedence)
+
+	def := policy.ToUpdateDefaultParams()
+	assert.Equal(t, 180, *def.CaptivePortal)
+}
+
+func TestMinimalDeviceSettingsPolicyPatch(t *testing.T) {
+	current := DeviceSettingsPolicy{
+		PolicyID:         StringPtr(deviceSettingsPolicyID),
+		Name:             StringPtr("engineering"),
+		Precedence:       IntPtr(10),
+		CaptivePortal:    IntPtr(180),
+		SupportURL:       StringPtr("https://support.example.com"),
+		AllowModeSwitch:  BoolPtr(false),
+		ExcludeOfficeIps: BoolPtr(true),
+		ServiceModeV2:    &ServiceModeV2{Mode: "warp"},
+		Include:          &[]SplitTunnel{},
+	}
+
+	desired := current
+	desired.Precedence = IntPtr(20)
+	desired.AllowModeSwitch = BoolPtr(true)
+	desired.SupportURL = nil
+	desired.ServiceModeV2 = &ServiceModeV2{Mode: "warp"}
+	desired.Include = &[]SplitTunnel{{Address: "10.0.0.0/8"}}
+
+	patch := MinimalDeviceSettingsPolicyPatch(current, desired)
+	assert.Equal(t, UpdateDeviceSettingsPolicyParams{
+		PolicyID:         StringPtr(deviceSettingsPolicyID),
+		Precedence:       IntPtr(20),
+		AllowModeSwitch:  BoolPtr(true),
+		ExcludeOfficeIps: BoolPtr(true),
+		Reset:            []DeviceSettingsPolicyResettableField{DeviceSettingsPolicyResetSupportURL},
+	}, patch)
+
+	b, err := json.Marshal(patch)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"precedence": 20, "allow_mode_switch": true, "exclude_office_ips": true, "support_url": null}`, string(b))
+	}
+
+	assert.Equal(t, UpdateDeviceSettingsPolicyParams{
+		PolicyID:         StringPtr(deviceSettingsPolicyID),
+		ExcludeOfficeIps: BoolPtr(true),
+	}, MinimalDeviceSettingsPolicyPatch(current, current))
+
+	// Fallback domains stored by the API in normalized form are no drift.
+	current.FallbackDomains = &[]FallbackDomain{{Suffix: "corp.example.com", DNSServer: []string{"2001:db8::1"}}}
+	desired = current
+	desired.FallbackDomains = &[]FallbackDomain{{Suffix: " Corp.Example.COM. ", DNSServer: []string{"2001:DB8:0::1"}}}
+	assert.Equal(t, UpdateDeviceSettingsPolicyParams{
+		PolicyID:         StringPtr(deviceSettingsPolicyID),
+		ExcludeOfficeIps: BoolPtr(true),
+	}, MinimalDeviceSettingsPolicyPatch(current, desired))
+}
+
+func TestValidateDeviceSettingsPolicyMode(t *testing.T) {
+	exclude := []SplitTunnel{{Address: "10.0.0.0/8"}}
+	fallback := []FallbackDomain{{Suffix: "internal"}}
+
+	policy := DeviceSettingsPolicy{
+		ServiceModeV2:   &ServiceModeV2{Mode: postureOnly},
+		Include:         &[]SplitTunnel{},
+		Exclude:         &exclude,
+		FallbackDomains: &fallback,
+	}
+	assert.Equal(t, []DeviceSettingsPolicyModeWarning{
+		{Mode: postureOnly, Field: "exclude", Message: "exclude is ignored in posture_only mode"},
+		{Mode: postureOnly, Field: "fallback_domains", Message: "fallback_domains is ignored in posture_only mode"},
+	}, ValidateDeviceSettingsPolicyMode(policy))
+
+	policy.ServiceModeV2.Mode = warpTunnelOnly
+	warnings := ValidateDeviceSettingsPolicyMode(policy)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, "fallback_domains", warnings[0].Field)
+	}
+
+	policy.ServiceModeV2.Mode = warp
+	assert.Empty(t, ValidateDeviceSettingsPolicyMode(policy))
+	assert.Empty(t, ValidateDeviceSettingsPolicyMode(DeviceSettingsPolicy{Exclude: &exclude}))
+}
+
+func TestCreateDeviceSettingsPoliciesDefaults(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	bodies := make(map[string]map[string]interface{})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		bodies[body["name"].(string)] = body
+		mu.Unlock()
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{"success": true, "errors": null, "messages": null, "result": {"policy_id": "%s-id", "name": %q}}`, body["name"], body["name"])
+	})
+
+	policies, err := client.CreateDeviceSettingsPolicies(context.Background(), AccountIdentifier(testAccountID), CreateDeviceSettingsPoliciesParams{
+		Policies: []CreateDeviceSettingsPolicyParams{
+			{Name: StringPtr("inherits"), Match: StringPtr(deviceSettingsPolicyMatch)},
+			{Name: StringPtr("overrides"), Match: StringPtr(deviceSettingsPolicyMatch), AllowedToLeave: BoolPtr(true)},
+			{Name: StringPtr("invalid")},
+		},
+		Defaults: CreateDeviceSettingsPolicyParams{
+			AllowedToLeave: BoolPtr(false),
+			SupportURL:     StringPtr("https://support.example.com"),
+		},
+	})
+
+	var batchErr *DeviceBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.ErrorIs(t, batchErr.Errors["2"], ErrMissingDeviceSettingsPolicyMatch)
+		assert.Len(t, batchErr.Errors, 1)
+	}
+
+	if assert.Len(t, policies, 3) {
+		assert.Equal(t, "inherits-id", *policies[0].PolicyID)
+		assert.Equal(t, "overrides-id", *policies[1].PolicyID)
+		assert.Nil(t, policies[2].PolicyID)
+	}
+
+	assert.Equal(t, false, bodies["inherits"]["allowed_to_leave"])
+	assert.Equal(t, "https://support.example.com", bodies["inherits"]["support_url"])
+	assert.Equal(t, true, bodies["overrides"]["allowed_to_leave"])
+	assert.Equal(t, "https://support.example.com", bodies["overrides"]["support_url"])
+}
+
+func TestMergeDeviceSettingsPolicyDefaults(t *testing.T) {
+	defaults := CreateDeviceSettingsPolicyParams{
+		Name:          StringPtr("default name"),
+		Match:         StringPtr(deviceSettingsPolicyMatch),
+		Precedence:    IntPtr(10),
+		ServiceModeV2: &ServiceModeV2{Mode: "proxy", Port: 8080},
+		SupportURL:    StringPtr("https://support.example.com"),
+	}
+
+	a := mergeDeviceSettingsPolicyDefaults(CreateDeviceSettingsPolicyParams{Name: StringPtr("a")}, defaults)
+	b := mergeDeviceSettingsPolicyDefaults(CreateDeviceSettingsPolicyParams{Name: StringPtr("b")}, defaults)
+
+	assert.Equal(t, "a", *a.Name)
+	assert.Nil(t, a.Match)
+	assert.Nil(t, a.Precedence)
+	assert.Equal(t, &ServiceModeV2{Mode: "proxy", Port: 8080}, a.ServiceModeV2)
+	assert.Equal(t, "https://support.example.com", *a.SupportURL)
+
+	// Values are copied, not shared between policies.
+	a.ServiceModeV2.Port = 9090
+	*a.SupportURL = "https://a.example.com"
+	assert.Equal(t, 8080, b.ServiceModeV2.Port)
+	assert.Equal(t, "https://support.example.com", *b.SupportURL)
+	assert.Equal(t, "https://support.example.com", *defaults.SupportURL)
+}
+
+func TestVerifyServiceMode(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": null, "messages": null, "result": {"default": true}}`)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+deviceSettingsPolicyID, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": {"policy_id": %q, "service_mode_v2": {"mode": "proxy", "port": 3000}}
+		}`, deviceSettingsPolicyID)
+	})
+
+	ok, actual, err := client.VerifyServiceMode(context.Background(), AccountIdentifier(testAccountID), VerifyServiceModeParams{
+		PolicyID: deviceSettingsPolicyID,
+		Expected: ServiceModeV2{Mode: "proxy", Port: 3000},
+	})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ServiceModeV2{Mode: "proxy", Port: 3000}, actual)
+
+	ok, actual, err = client.VerifyServiceMode(context.Background(), AccountIdentifier(testAccountID), VerifyServiceModeParams{
+		PolicyID: deviceSettingsPolicyID,
+		Expected: ServiceModeV2{Mode: "posture_only"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, ServiceMode("proxy"), actual.Mode)
+
+	// The default policy has no mode set, so it runs in warp mode.
+	ok, actual, err = client.VerifyServiceMode(context.Background(), AccountIdentifier(testAccountID), VerifyServiceModeParams{Expected: ServiceModeV2{Mode: "warp"}})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ServiceModeV2{}, actual)
+
+	_, _, err = client.VerifyServiceMode(context.Background(), ZoneIdentifier(testZoneID), VerifyServiceModeParams{})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+}
+
+func TestCaptivePortalConfig(t *testing.T) {
+	testCases := map[string]struct {
+		captivePortal *int
+		expected      CaptivePortalConfig
+		value         int
+	}{
+		"unset":    {nil, CaptivePortalConfig{}, 0},
+		"disabled": {IntPtr(0), CaptivePortalConfig{}, 0},
+		"negative": {IntPtr(-1), CaptivePortalConfig{}, 0},
+		"enabled":  {IntPtr(300), CaptivePortalConfig{Enabled: true, TimeoutSeconds: 300}, 300},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual := DeviceSettingsPolicy{CaptivePortal: tc.captivePortal}.CaptivePortalConfig()
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.value, *actual.Value())
+		})
+	}
+
+	assert.Equal(t, DefaultCaptivePortalTimeoutSeconds, *CaptivePortalConfig{Enabled: true}.Value())
+}
+
+func TestUpsertDeviceSettingsPolicyByName(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"policy_id": "laptops-id", "name": "laptops", "match": %q, "support_url": "https://support.example.com", "exclude_office_ips": false},
+				{"policy_id": "duplicate-1", "name": "duplicate", "match": %q},
+				{"policy_id": "duplicate-2", "name": "duplicate", "match": %q}
+			],
+			"result_info": {"page": 1, "per_page": 20, "count": 3, "total_count": 3}
+		}`, deviceSettingsPolicyMatch, deviceSettingsPolicyMatch, deviceSettingsPolicyMatch)
+	})
+
+	var created map[string]interface{}
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": null, "messages": null, "result": {"policy_id": "new-id", "name": "new"}}`)
+	})
+
+	var patched map[string]interface{}
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/laptops-id", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method, "Expected method 'PATCH', got %s", r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&patched))
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": null, "messages": null, "result": {"policy_id": "laptops-id", "name": "laptops"}}`)
+	})
+
+	ctx := context.Background()
+	laptops := CreateDeviceSettingsPolicyParams{
+		Name:             StringPtr("laptops"),
+		Match:            StringPtr(deviceSettingsPolicyMatch),
+		SupportURL:       StringPtr("https://support.example.com"),
+		ExcludeOfficeIps: BoolPtr(false),
+	}
+
+	// Unchanged policies are not updated.
+	policy, isNew, err := client.UpsertDeviceSettingsPolicyByName(ctx, AccountIdentifier(testAccountID), laptops)
+	if assert.NoError(t, err) {
+		assert.False(t, isNew)
+		assert.Equal(t, "laptops-id", *policy.PolicyID)
+		assert.Nil(t, patched)
+	}
+
+	laptops.SupportURL = StringPtr("https://help.example.com")
+	policy, isNew, err = client.UpsertDeviceSettingsPolicyByName(ctx, AccountIdentifier(testAccountID), laptops)
+	if assert.NoError(t, err) {
+		assert.False(t, isNew)
+		assert.Equal(t, "laptops-id", *policy.PolicyID)
+		assert.Equal(t, map[string]interface{}{"support_url": "https://help.example.com", "exclude_office_ips": false}, patched)
+	}
+
+	policy, isNew, err = client.UpsertDeviceSettingsPolicyByName(ctx, AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{
+		Name:  StringPtr("new"),
+		Match: StringPtr(deviceSettingsPolicyMatch),
+	})
+	if assert.NoError(t, err) {
+		assert.True(t, isNew)
+		assert.Equal(t, "new-id", *policy.PolicyID)
+		assert.Equal(t, "new", created["name"])
+	}
+
+	_, _, err = client.UpsertDeviceSettingsPolicyByName(ctx, AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{Name: StringPtr("duplicate")})
+	assert.ErrorIs(t, err, ErrAmbiguousDeviceSettingsPolicyName)
+
+	_, _, err = client.UpsertDeviceSettingsPolicyByName(ctx, AccountIdentifier(testAccountID), CreateDeviceSettingsPolicyParams{})
+	assert.ErrorIs(t, err, ErrMissingDeviceSettingsPolicyName)
+
+	_, _, err = client.UpsertDeviceSettingsPolicyByName(ctx, ZoneIdentifier(testZoneID), laptops)
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+}
+
+func TestValidateDeviceSettingsPolicyPrecedences(t *testing.T) {
+	policy := func(id string, precedence *int) DeviceSettingsPolicy {
+		return DeviceSettingsPolicy{PolicyID: StringPtr(id), Precedence: precedence}
+	}
+
+	assert.Empty(t, ValidateDeviceSettingsPolicyPrecedences([]DeviceSettingsPolicy{
+		policy("a", IntPtr(10)),
+		policy("b", IntPtr(20)),
+		{Default: true},
+	}))
+
+	// Consecutive precedences are a normal ordering.
+	assert.Empty(t, ValidateDeviceSettingsPolicyPrecedences([]DeviceSettingsPolicy{
+		policy("a", IntPtr(1)),
+		policy("b", IntPtr(2)),
+		policy("c", IntPtr(3)),
+		{Default: true},
+	}))
+
+	issues := ValidateDeviceSettingsPolicyPrecedences([]DeviceSettingsPolicy{
+		policy("top", IntPtr(1)),
+		policy("a", IntPtr(10)),
+		policy("b", IntPtr(11)),
+		policy("c", IntPtr(20)),
+		policy("d", IntPtr(20)),
+		policy("unordered", nil),
+		policy("negative", IntPtr(-1)),
+		{Default: true, Precedence: IntPtr(15)},
+	})
+
+	actual := make([]PolicyPrecedenceIssueType, 0, len(issues))
+	for _, issue := range issues {
+		actual = append(actual, issue.Type)
+	}
+	assert.Equal(t, []PolicyPrecedenceIssueType{
+		PolicyPrecedenceIssueInvalid,
+		PolicyPrecedenceIssueMissing,
+		PolicyPrecedenceIssueDefaultPosition,
+		PolicyPrecedenceIssueDuplicate,
+	}, actual)
+
+	assert.Equal(t, "negative", *issues[0].Policies[0].PolicyID)
+	assert.Equal(t, "unordered", *issues[1].Policies[0].PolicyID)
+	assert.Equal(t, 15, issues[2].Precedence)
+	assert.Equal(t, "policies c, d share precedence 20", issues[3].Message)
+}
+
+func TestDeviceSettingsPolicyWARPRequired(t *testing.T) {
+	required := DeviceSettingsPolicy{SwitchLocked: BoolPtr(true), AllowedToLeave: BoolPtr(false), AllowModeSwitch: BoolPtr(false)}
+	assert.True(t, required.WARPRequired())
+
+	// Mode switching defaults to disallowed.
+	required.AllowModeSwitch = nil
+	assert.True(t, required.WARPRequired())
+
+	testCases := map[string]DeviceSettingsPolicy{
+		"defaults":         {},
+		"switch unlocked":  {SwitchLocked: BoolPtr(false), AllowedToLeave: BoolPtr(false)},
+		"allowed to leave": {SwitchLocked: BoolPtr(true), AllowedToLeave: BoolPtr(true)},
+		"leave by default": {SwitchLocked: BoolPtr(true)},
+		"mode switch":      {SwitchLocked: BoolPtr(true), AllowedToLeave: BoolPtr(false), AllowModeSwitch: BoolPtr(true)},
+	}
+	for name, policy := range testCases {
+		assert.False(t, policy.WARPRequired(), name)
+	}
+}
@@ -0,0 +1,183 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeviceSettingsPolicyEventType is the kind of change reported by
+// WatchDeviceSettingsPolicies.
+type DeviceSettingsPolicyEventType string
+
+const (
+	DeviceSettingsPolicyCreated DeviceSettingsPolicyEventType = "created"
+	DeviceSettingsPolicyUpdated DeviceSettingsPolicyEventType = "updated"
+	DeviceSettingsPolicyDeleted DeviceSettingsPolicyEventType = "deleted"
+	// DeviceSettingsPolicyWatchError reports a failed poll. Watching
+	// continues with the next poll.
+	DeviceSettingsPolicyWatchError DeviceSettingsPolicyEventType = "error"
+)
+
+// DeviceSettingsPolicyEvent is a change of a device settings policy observed
+// by WatchDeviceSettingsPolicies.
+type DeviceSettingsPolicyEvent struct {
+	Type DeviceSettingsPolicyEventType
+	// Policy is the policy after the change. It is nil for deleted policies
+	// and errors.
+	Policy *DeviceSettingsPolicy
+	// Previous is the policy before the change. It is nil for created
+	// policies and errors.
+	Previous *DeviceSettingsPolicy
+	// Err is the error of a failed poll.
+	Err error
+}
+
+type WatchDeviceSettingsPoliciesParams struct {
+	// Interval is the time between two polls. Defaults to one minute.
+	Interval time.Duration
+}
+
+// WatchDeviceSettingsPolicies polls the device settings policies of the
+// account every Interval and emits an event for each policy created, updated
+// or deleted since the previous poll, until ctx is cancelled and the channel
+// is closed.
+//
+// The watch is poll based, not pushed by the API: changes are seen at most
+// Interval late, and a policy changed and changed back between two polls
+// produces no event. Policies are compared with
+// DeviceSettingsPolicyFingerprint and, as the default policy, are identified
+// by their policy ID. The policies are listed once before returning, which
+// sets the baseline without emitting events; an error is returned if that
+// fails. Later failed polls are reported as DeviceSettingsPolicyWatchError
+// events. Events must be consumed, as polling waits for them to be received.
+//
+// API reference: https://api.cloudflare.com/#devices-list-device-settings-policies
+func (api *API) WatchDeviceSettingsPolicies(ctx context.Context, rc *ResourceContainer, params WatchDeviceSettingsPoliciesParams) (<-chan DeviceSettingsPolicyEvent, error) {
+	if rc.Level != AccountRouteLevel {
+		return nil, fmt.Errorf(errInvalidResourceContainerAccess, rc.Level)
+	}
+
+	if params.Interval <= 0 {
+		params.Interval = time.Minute
+	}
+
+	snapshot, err := api.deviceSettingsPolicySnapshot(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan DeviceSettingsPolicyEvent)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(params.Interval)
+		defer ticker.Stop()
+
+		emit := func(event DeviceSettingsPolicyEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := api.deviceSettingsPolicySnapshot(ctx, rc)
+			if err != nil {
+				if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+					return
+				}
+				if !emit(DeviceSettingsPolicyEvent{Type: DeviceSettingsPolicyWatchError, Err: err}) {
+					return
+				}
+				continue
+			}
+
+			for _, event := range diffDeviceSettingsPolicySnapshots(snapshot, current) {
+				if !emit(event) {
+					return
+				}
+			}
+			snapshot = current
+		}
+	}()
+
+	return events, nil
+}
+
+// deviceSettingsPolicySnapshotEntry is a policy along with its fingerprint.
+type deviceSettingsPolicySnapshotEntry struct {
+	policy      DeviceSettingsPolicy
+	fingerprint string
+}
+
+// deviceSettingsPolicySnapshot is the list of policies in the order returned
+// by the API, indexed by their ID.
+type deviceSettingsPolicySnapshot struct {
+	ids     []string
+	entries map[string]deviceSettingsPolicySnapshotEntry
+}
+
+func (api *API) deviceSettingsPolicySnapshot(ctx context.Context, rc *ResourceContainer) (deviceSettingsPolicySnapshot, error) {
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return deviceSettingsPolicySnapshot{}, err
+	}
+
+	snapshot := deviceSettingsPolicySnapshot{entries: make(map[string]deviceSettingsPolicySnapshotEntry, len(policies))}
+	for _, policy := range policies {
+		id := "default"
+		if !policy.Default && policy.PolicyID != nil {
+			id = *policy.PolicyID
+		}
+
+		if _, ok := snapshot.entries[id]; !ok {
+			snapshot.ids = append(snapshot.ids, id)
+		}
+		snapshot.entries[id] = deviceSettingsPolicySnapshotEntry{
+			policy:      policy,
+			fingerprint: DeviceSettingsPolicyFingerprint(policy),
+		}
+	}
+
+	return snapshot, nil
+}
+
+// diffDeviceSettingsPolicySnapshots returns the events turning previous into
+// current: updates and creations in the order of current, then deletions in
+// the order of previous.
+func diffDeviceSettingsPolicySnapshots(previous, current deviceSettingsPolicySnapshot) []DeviceSettingsPolicyEvent {
+	var events []DeviceSettingsPolicyEvent
+
+	for _, id := range current.ids {
+		entry := current.entries[id]
+		policy := entry.policy
+
+		old, ok := previous.entries[id]
+		switch {
+		case !ok:
+			events = append(events, DeviceSettingsPolicyEvent{Type: DeviceSettingsPolicyCreated, Policy: &policy})
+		case old.fingerprint != entry.fingerprint:
+			oldPolicy := old.policy
+			events = append(events, DeviceSettingsPolicyEvent{Type: DeviceSettingsPolicyUpdated, Policy: &policy, Previous: &oldPolicy})
+		}
+	}
+
+	for _, id := range previous.ids {
+		if _, ok := current.entries[id]; !ok {
+			oldPolicy := previous.entries[id].policy
+			events = append(events, DeviceSettingsPolicyEvent{Type: DeviceSettingsPolicyDeleted, Previous: &oldPolicy})
+		}
+	}
+
+	return events
+}
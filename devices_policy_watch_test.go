@@ -0,0 +1,65 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchDeviceSettingsPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	responses := []string{
+		`[{"default": true}, {"policy_id": "a", "name": "a", "precedence": 10}, {"policy_id": "b", "name": "b", "precedence": 20}]`,
+		`[{"default": true}, {"policy_id": "a", "name": "a", "precedence": 10}, {"policy_id": "b", "name": "b", "precedence": 20}]`,
+		`[{"default": true}, {"policy_id": "a", "name": "a", "precedence": 15}, {"policy_id": "c", "name": "c", "precedence": 30}]`,
+	}
+	var calls int32
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		call := int(atomic.AddInt32(&calls, 1)) - 1
+		if call >= len(responses) {
+			call = len(responses) - 1
+		}
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `{"success": true, "errors": null, "messages": null, "result": %s}`, responses[call])
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.WatchDeviceSettingsPolicies(ctx, AccountIdentifier(testAccountID), WatchDeviceSettingsPoliciesParams{Interval: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	var received []DeviceSettingsPolicyEvent
+	for len(received) < 3 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for events")
+		}
+	}
+	cancel()
+
+	assert.Equal(t, DeviceSettingsPolicyUpdated, received[0].Type)
+	assert.Equal(t, "a", *received[0].Policy.PolicyID)
+	assert.Equal(t, 10, *received[0].Previous.Precedence)
+	assert.Equal(t, 15, *received[0].Policy.Precedence)
+
+	assert.Equal(t, DeviceSettingsPolicyCreated, received[1].Type)
+	assert.Equal(t, "c", *received[1].Policy.PolicyID)
+	assert.Nil(t, received[1].Previous)
+
+	assert.Equal(t, DeviceSettingsPolicyDeleted, received[2].Type)
+	assert.Equal(t, "b", *received[2].Previous.PolicyID)
+	assert.Nil(t, received[2].Policy)
+
+	for range events {
+	}
+}
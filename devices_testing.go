@@ -0,0 +1,127 @@
+//go:build cloudflare_testing
+
+package cloudflare
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// This file provides test doubles for the HTTP transport used by the client.
+// It is only compiled with the `cloudflare_testing` build tag, e.g.
+//
+//	go test -tags cloudflare_testing ./...
+//
+// A typical device test creates a RequestRecorder, builds a client with
+// NewTestAPI and asserts on the recorded requests:
+//
+//	recorder := &cloudflare.RequestRecorder{}
+//	api, _ := cloudflare.NewTestAPI(recorder)
+//	_, _ = api.ListTeamsDevices(ctx, "account")
+//	// recorder.Requests()[0].URI == "/accounts/account/devices"
+
+// testAPIBaseURL is the base URL of clients returned by NewTestAPI. It has no
+// path so recorded URIs are the ones passed to the request methods.
+const testAPIBaseURL = "https://api.cloudflare.test"
+
+// RoundTripFunc adapts a function to an http.RoundTripper.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RecordedRequest is a request captured by a RequestRecorder.
+type RecordedRequest struct {
+	Method string
+	// URI is the path and query of the request.
+	URI    string
+	Header http.Header
+	Body   []byte
+}
+
+// RequestRecorder is an http.RoundTripper that records every request. The
+// response is produced by Handler, or is an empty successful API response when
+// Handler is nil. It is safe for concurrent use.
+type RequestRecorder struct {
+	Handler RoundTripFunc
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *RequestRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, RecordedRequest{
+		Method: req.Method,
+		URI:    req.URL.RequestURI(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	r.mu.Unlock()
+
+	if r.Handler == nil {
+		resp := JSONResponse(http.StatusOK, `{"success": true, "errors": [], "messages": [], "result": null}`)
+		resp.Request = req
+		return resp, nil
+	}
+
+	resp, err := r.Handler(req)
+	// The client reads the request back from error responses.
+	if resp != nil && resp.Request == nil {
+		resp.Request = req
+	}
+
+	return resp, err
+}
+
+// Requests returns a copy of the requests recorded so far.
+func (r *RequestRecorder) Requests() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requests := make([]RecordedRequest, len(r.requests))
+	copy(requests, r.requests)
+
+	return requests
+}
+
+// JSONResponse returns an HTTP response with the given status code and JSON
+// body.
+func JSONResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		Status:     http.StatusText(statusCode),
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+// NewTestAPI returns an API client sending all requests through transport.
+// Retries and rate limiting are disabled; opts are applied afterwards and may
+// override this.
+func NewTestAPI(transport http.RoundTripper, opts ...Option) (*API, error) {
+	opts = append([]Option{
+		UsingRateLimit(100000),
+		UsingRetryPolicy(0, 0, 0),
+		BaseURL(testAPIBaseURL),
+		HTTPClient(&http.Client{Transport: transport}),
+	}, opts...)
+
+	return NewWithAPIToken("test-token", opts...)
+}
@@ -0,0 +1,124 @@
+//go:build cloudflare_testing
+
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestRecorder(t *testing.T) {
+	recorder := &RequestRecorder{
+		Handler: func(req *http.Request) (*http.Response, error) {
+			return JSONResponse(http.StatusOK, `{"success": true, "errors": [], "messages": [], "result": [{"address": "10.0.0.0/8"}]}`), nil
+		},
+	}
+
+	api, err := NewTestAPI(recorder)
+	require.NoError(t, err)
+
+	actual, err := api.UpdateSplitTunnel(context.Background(), testAccountID, "exclude", []SplitTunnel{{Address: "10.0.0.0/8"}})
+	require.NoError(t, err)
+	assert.Equal(t, []SplitTunnel{{Address: "10.0.0.0/8"}}, actual)
+
+	requests := recorder.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPut, requests[0].Method)
+	assert.Equal(t, "/accounts/"+testAccountID+"/devices/policy/exclude", requests[0].URI)
+	assert.JSONEq(t, `[{"address": "10.0.0.0/8"}]`, string(requests[0].Body))
+	assert.Equal(t, "Bearer test-token", requests[0].Header.Get("Authorization"))
+}
+
+func TestRequestRecorderDefaultResponse(t *testing.T) {
+	recorder := &RequestRecorder{}
+
+	api, err := NewTestAPI(recorder)
+	require.NoError(t, err)
+
+	err = api.DeleteDevicePostureRule(context.Background(), testAccountID, "rule")
+	require.NoError(t, err)
+	assert.Equal(t, "/accounts/"+testAccountID+"/devices/posture/rule", recorder.Requests()[0].URI)
+}
+
+func TestSetDeviceSettingsPolicyField(t *testing.T) {
+	recorder := &RequestRecorder{
+		Handler: func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/broken") {
+				return JSONResponse(http.StatusBadRequest, `{"success": false, "errors": [{"code": 1000, "message": "bad request"}], "messages": []}`), nil
+			}
+			return JSONResponse(http.StatusOK, `{"success": true, "errors": null, "messages": null, "result": {"captive_portal": 300}}`), nil
+		},
+	}
+
+	api, err := NewTestAPI(recorder)
+	require.NoError(t, err)
+
+	bodies := func(requests []RecordedRequest) map[string]string {
+		bodies := make(map[string]string, len(requests))
+		for _, req := range requests {
+			assert.Equal(t, http.MethodPatch, req.Method)
+			bodies[req.URI] = string(req.Body)
+		}
+		return bodies
+	}
+
+	ctx := context.Background()
+	rc := AccountIdentifier(testAccountID)
+	results, err := api.SetDeviceSettingsPolicyField(ctx, rc, SetDeviceSettingsPolicyFieldParams{
+		PolicyIDs:   []string{"", deviceSettingsPolicyID, "broken"},
+		Field:       "captive_portal",
+		Value:       300,
+		Concurrency: 2,
+	})
+
+	var batchErr *DeviceBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Len(t, batchErr.Errors, 1)
+		assert.Contains(t, batchErr.Errors, "broken")
+	}
+	assert.Len(t, results, 2)
+	assert.Equal(t, 300, *results["default"].CaptivePortal)
+	assert.Equal(t, 300, *results[deviceSettingsPolicyID].CaptivePortal)
+	assert.Equal(t, map[string]string{
+		"/accounts/" + testAccountID + "/devices/policy":                           `{"captive_portal":300}`,
+		"/accounts/" + testAccountID + "/devices/policy/" + deviceSettingsPolicyID: `{"captive_portal":300}`,
+		"/accounts/" + testAccountID + "/devices/policy/broken":                    `{"captive_portal":300}`,
+	}, bodies(recorder.Requests()))
+
+	sent := len(recorder.Requests())
+	_, err = api.SetDeviceSettingsPolicyField(ctx, rc, SetDeviceSettingsPolicyFieldParams{PolicyIDs: []string{deviceSettingsPolicyID}, Field: "support_url"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"/accounts/" + testAccountID + "/devices/policy/" + deviceSettingsPolicyID: `{"support_url":null}`,
+	}, bodies(recorder.Requests()[sent:]))
+
+	invalid := map[string]struct {
+		policyIDs []string
+		field     string
+		value     interface{}
+	}{
+		"unknown field":        {[]string{deviceSettingsPolicyID}, "unknown", true},
+		"ignored field":        {[]string{deviceSettingsPolicyID}, "-", true},
+		"wrong type":           {[]string{deviceSettingsPolicyID}, "captive_portal", "300"},
+		"not resettable":       {[]string{deviceSettingsPolicyID}, "allow_updates", nil},
+		"empty match":          {[]string{deviceSettingsPolicyID}, "match", ""},
+		"match of the default": {[]string{deviceSettingsPolicyID, "default"}, "match", deviceSettingsPolicyMatch},
+	}
+	for name, tc := range invalid {
+		t.Run(name, func(t *testing.T) {
+			sent := len(recorder.Requests())
+			_, err := api.SetDeviceSettingsPolicyField(ctx, rc, SetDeviceSettingsPolicyFieldParams{PolicyIDs: tc.policyIDs, Field: tc.field, Value: tc.value})
+			assert.Error(t, err)
+			assert.Len(t, recorder.Requests(), sent)
+		})
+	}
+
+	_, err = api.SetDeviceSettingsPolicyField(ctx, ZoneIdentifier(testZoneID), SetDeviceSettingsPolicyFieldParams{Field: "captive_portal", Value: 300})
+	assert.EqualError(t, err, fmt.Sprintf(errInvalidResourceContainerAccess, ZoneRouteLevel))
+}
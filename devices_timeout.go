@@ -0,0 +1,19 @@
+package cloudflare
+
+import "strings"
+
+// isDeviceRequestURI reports whether uri is that of a device endpoint, i.e.
+// under /accounts/<id>/devices or /accounts/<id>/dex, the requests bounded by
+// UsingDeviceRequestTimeout.
+func isDeviceRequestURI(uri string) bool {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+
+	segments := strings.Split(strings.Trim(uri, "/"), "/")
+	if len(segments) < 3 || segments[0] != string(AccountRouteRoot) {
+		return false
+	}
+
+	return segments[2] == "devices" || segments[2] == "dex"
+}
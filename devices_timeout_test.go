@@ -0,0 +1,53 @@
+package cloudflare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsingDeviceRequestTimeout(t *testing.T) {
+	setup(UsingDeviceRequestTimeout(20 * time.Millisecond))
+	defer teardown()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/gateway/lists", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": []}`)
+	})
+
+	_, err := client.ListTeamsDevices(context.Background(), testAccountID)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	// Other endpoints are not bounded.
+	_, _, err = client.ListTeamsLists(context.Background(), AccountIdentifier(testAccountID), ListTeamListsParams{})
+	assert.NoError(t, err)
+
+	// A shorter caller deadline still wins.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.ListTeamsDevices(ctx, testAccountID)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIsDeviceRequestURI(t *testing.T) {
+	assert.True(t, isDeviceRequestURI("/accounts/abc/devices"))
+	assert.True(t, isDeviceRequestURI("/accounts/abc/devices/policy/123/include"))
+	assert.True(t, isDeviceRequestURI("/accounts/abc/dex/fleet-status/devices?page=2"))
+	assert.False(t, isDeviceRequestURI("/accounts/abc/gateway/lists"))
+	assert.False(t, isDeviceRequestURI("/zones/abc/devices/policy/certificates"))
+}
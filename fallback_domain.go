@@ -2,10 +2,11 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-
-	"github.com/goccy/go-json"
+	"strings"
 )
 
 // FallbackDomainResponse represents the response from the get fallback
@@ -22,6 +23,54 @@ type FallbackDomain struct {
 	DNSServer   []string `json:"dns_server,omitempty"`
 }
 
+// NormalizeFallbackDomains returns a copy of domains normalized the same way
+// the API stores them, so that a desired list can be compared with the one
+// returned by the API. The order of the entries is preserved and the
+// following rules are applied to every entry:
+//
+//   - the suffix has surrounding whitespace and trailing dots removed and is
+//     lowercased, e.g. " Corp.Example.COM. " becomes "corp.example.com";
+//   - DNS servers that are valid IP addresses are rewritten in their canonical
+//     form, e.g. "2001:DB8:0::1" becomes "2001:db8::1"; other values are kept
+//     unchanged;
+//   - an empty DNS server list is replaced by nil.
+//
+// Descriptions are left untouched.
+func NormalizeFallbackDomains(domains []FallbackDomain) []FallbackDomain {
+	if domains == nil {
+		return nil
+	}
+
+	normalized := make([]FallbackDomain, len(domains))
+	for i, domain := range domains {
+		domain.Suffix = normalizeFallbackDomainSuffix(domain.Suffix)
+
+		if len(domain.DNSServer) == 0 {
+			domain.DNSServer = nil
+		} else {
+			servers := make([]string, len(domain.DNSServer))
+			for j, server := range domain.DNSServer {
+				server = strings.TrimSpace(server)
+				if ip := net.ParseIP(server); ip != nil {
+					server = ip.String()
+				}
+				servers[j] = server
+			}
+			domain.DNSServer = servers
+		}
+
+		normalized[i] = domain
+	}
+
+	return normalized
+}
+
+// normalizeFallbackDomainSuffix returns suffix normalized as by
+// NormalizeFallbackDomains, for matching entries by suffix.
+func normalizeFallbackDomainSuffix(suffix string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(suffix), "."))
+}
+
 // ListFallbackDomains returns all fallback domains within an account.
 //
 // API reference: https://api.cloudflare.com/#devices-get-local-domain-fallback-list
@@ -34,7 +83,7 @@ func (api *API) ListFallbackDomains(ctx context.Context, accountID string) ([]Fa
 	}
 
 	var fallbackDomainResponse FallbackDomainResponse
-	err = json.Unmarshal(res, &fallbackDomainResponse)
+	err = api.unmarshalDeviceResponse(res, &fallbackDomainResponse)
 	if err != nil {
 		return []FallbackDomain{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -54,7 +103,7 @@ func (api *API) ListFallbackDomainsDeviceSettingsPolicy(ctx context.Context, acc
 	}
 
 	var fallbackDomainResponse FallbackDomainResponse
-	err = json.Unmarshal(res, &fallbackDomainResponse)
+	err = api.unmarshalDeviceResponse(res, &fallbackDomainResponse)
 	if err != nil {
 		return []FallbackDomain{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -64,8 +113,16 @@ func (api *API) ListFallbackDomainsDeviceSettingsPolicy(ctx context.Context, acc
 
 // UpdateFallbackDomain updates the existing fallback domain policy.
 //
+// The update replaces the whole list: any domain missing from domains is
+// removed. Use AddFallbackDomains or RemoveFallbackDomains to change
+// individual entries.
+//
 // API reference: https://api.cloudflare.com/#devices-set-local-domain-fallback-list
 func (api *API) UpdateFallbackDomain(ctx context.Context, accountID string, domains []FallbackDomain) ([]FallbackDomain, error) {
+	if err := checkDeviceListLimit("fallback domains", len(domains), api.fallbackDomainLimit); err != nil {
+		return []FallbackDomain{}, err
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/policy/fallback_domains", AccountRouteRoot, accountID)
 
 	res, err := api.makeRequestContext(ctx, http.MethodPut, uri, domains)
@@ -74,7 +131,7 @@ func (api *API) UpdateFallbackDomain(ctx context.Context, accountID string, doma
 	}
 
 	var fallbackDomainResponse FallbackDomainResponse
-	err = json.Unmarshal(res, &fallbackDomainResponse)
+	err = api.unmarshalDeviceResponse(res, &fallbackDomainResponse)
 	if err != nil {
 		return []FallbackDomain{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -84,8 +141,16 @@ func (api *API) UpdateFallbackDomain(ctx context.Context, accountID string, doma
 
 // UpdateFallbackDomainDeviceSettingsPolicy updates the existing fallback domain policy for a specific device settings policy.
 //
+// The update replaces the whole list: any domain missing from domains is
+// removed. Use AddFallbackDomains or RemoveFallbackDomains to change
+// individual entries.
+//
 // API reference: https://api.cloudflare.com/#devices-set-local-domain-fallback-list
 func (api *API) UpdateFallbackDomainDeviceSettingsPolicy(ctx context.Context, accountID, policyID string, domains []FallbackDomain) ([]FallbackDomain, error) {
+	if err := checkDeviceListLimit("fallback domains", len(domains), api.fallbackDomainLimit); err != nil {
+		return []FallbackDomain{}, err
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/policy/%s/fallback_domains", AccountRouteRoot, accountID, policyID)
 
 	res, err := api.makeRequestContext(ctx, http.MethodPut, uri, domains)
@@ -94,7 +159,7 @@ func (api *API) UpdateFallbackDomainDeviceSettingsPolicy(ctx context.Context, ac
 	}
 
 	var fallbackDomainResponse FallbackDomainResponse
-	err = json.Unmarshal(res, &fallbackDomainResponse)
+	err = api.unmarshalDeviceResponse(res, &fallbackDomainResponse)
 	if err != nil {
 		return []FallbackDomain{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -131,3 +196,134 @@ func (api *API) RestoreFallbackDomainDefaultsDeviceSettingsPolicy(ctx context.Co
 
 	return nil
 }
+
+// UpdateFallbackDomainDNSServers replaces the DNS servers of a single
+// fallback domain, leaving the other entries untouched. The current list is
+// fetched, the entry matching suffix is modified and the whole list is written
+// back. Suffixes are matched after normalization, see NormalizeFallbackDomains.
+// An empty policyID targets the account level (default) list.
+//
+// API reference: https://api.cloudflare.com/#devices-set-local-domain-fallback-list
+func (api *API) UpdateFallbackDomainDNSServers(ctx context.Context, accountID, policyID, suffix string, dnsServers []string) ([]FallbackDomain, error) {
+	if normalizeFallbackDomainSuffix(suffix) == "" {
+		return []FallbackDomain{}, errors.New("fallback domain suffix must not be empty")
+	}
+
+	for _, server := range dnsServers {
+		if net.ParseIP(server) == nil {
+			return []FallbackDomain{}, fmt.Errorf("invalid fallback domain DNS server IP address: %q", server)
+		}
+	}
+
+	domains, err := api.listFallbackDomains(ctx, accountID, policyID)
+	if err != nil {
+		return []FallbackDomain{}, err
+	}
+
+	found := false
+	for i := range domains {
+		if normalizeFallbackDomainSuffix(domains[i].Suffix) == normalizeFallbackDomainSuffix(suffix) {
+			domains[i].DNSServer = dnsServers
+			found = true
+			break
+		}
+	}
+	if !found {
+		return []FallbackDomain{}, fmt.Errorf("fallback domain suffix %q not found", suffix)
+	}
+
+	return api.ReplaceFallbackDomains(ctx, accountID, policyID, domains)
+}
+
+// ReplaceFallbackDomains replaces the complete fallback domain list with
+// domains. This is a full replacement, not a merge: every existing entry that
+// is not part of domains is deleted, and an empty domains removes all
+// entries. An empty policyID targets the account level (default) list.
+//
+// API reference: https://api.cloudflare.com/#devices-set-local-domain-fallback-list
+func (api *API) ReplaceFallbackDomains(ctx context.Context, accountID, policyID string, domains []FallbackDomain) ([]FallbackDomain, error) {
+	if policyID == "" {
+		return api.UpdateFallbackDomain(ctx, accountID, domains)
+	}
+
+	return api.UpdateFallbackDomainDeviceSettingsPolicy(ctx, accountID, policyID, domains)
+}
+
+// AddFallbackDomains adds domains to the existing fallback domain list,
+// keeping the entries already present. An existing entry with the same suffix
+// as one of domains, once normalized as by NormalizeFallbackDomains, is
+// overwritten in place, so that e.g. "Example.com." doesn't duplicate
+// "example.com". An empty policyID targets the account level (default) list.
+//
+// The list is read and written back in full, so concurrent modifications
+// between the two requests are lost.
+//
+// API reference: https://api.cloudflare.com/#devices-set-local-domain-fallback-list
+func (api *API) AddFallbackDomains(ctx context.Context, accountID, policyID string, domains []FallbackDomain) ([]FallbackDomain, error) {
+	existing, err := api.listFallbackDomains(ctx, accountID, policyID)
+	if err != nil {
+		return []FallbackDomain{}, err
+	}
+
+	index := make(map[string]int, len(existing))
+	for i, domain := range existing {
+		index[normalizeFallbackDomainSuffix(domain.Suffix)] = i
+	}
+
+	for _, domain := range domains {
+		suffix := normalizeFallbackDomainSuffix(domain.Suffix)
+		if i, ok := index[suffix]; ok {
+			existing[i] = domain
+			continue
+		}
+		index[suffix] = len(existing)
+		existing = append(existing, domain)
+	}
+
+	return api.ReplaceFallbackDomains(ctx, accountID, policyID, existing)
+}
+
+// RemoveFallbackDomains removes the entries matching suffixes from the
+// existing fallback domain list, keeping all others. Suffixes are matched
+// after normalization, see NormalizeFallbackDomains, and those that are not in
+// the list are ignored. An empty policyID targets the account level (default)
+// list.
+//
+// The list is read and written back in full, so concurrent modifications
+// between the two requests are lost.
+//
+// API reference: https://api.cloudflare.com/#devices-set-local-domain-fallback-list
+func (api *API) RemoveFallbackDomains(ctx context.Context, accountID, policyID string, suffixes []string) ([]FallbackDomain, error) {
+	existing, err := api.listFallbackDomains(ctx, accountID, policyID)
+	if err != nil {
+		return []FallbackDomain{}, err
+	}
+
+	remove := make(map[string]bool, len(suffixes))
+	for _, suffix := range suffixes {
+		remove[normalizeFallbackDomainSuffix(suffix)] = true
+	}
+
+	domains := make([]FallbackDomain, 0, len(existing))
+	for _, domain := range existing {
+		if !remove[normalizeFallbackDomainSuffix(domain.Suffix)] {
+			domains = append(domains, domain)
+		}
+	}
+
+	if len(domains) == len(existing) {
+		return existing, nil
+	}
+
+	return api.ReplaceFallbackDomains(ctx, accountID, policyID, domains)
+}
+
+// listFallbackDomains returns the fallback domains of a device settings
+// policy, or of the account when policyID is empty.
+func (api *API) listFallbackDomains(ctx context.Context, accountID, policyID string) ([]FallbackDomain, error) {
+	if policyID == "" {
+		return api.ListFallbackDomains(ctx, accountID)
+	}
+
+	return api.ListFallbackDomainsDeviceSettingsPolicy(ctx, accountID, policyID)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -234,3 +235,228 @@ func TestUpdateFallbackDomainDeviceSettingsPolicy(t *testing.T) {
 		assert.Equal(t, domains, actual)
 	}
 }
+
+func TestUpdateFallbackDomainDNSServers(t *testing.T) {
+	setup()
+	defer teardown()
+
+	policyID := "a842fa8a-a583-482e-9cd9-eb43362949fd"
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, `
+    {
+      "success": true,
+      "errors": [],
+      "messages": [],
+      "result": [
+        {
+          "suffix": "example_one.com",
+          "dns_server": ["192.168.0.1"]
+        },
+        {
+          "suffix": "example_two.com",
+          "dns_server": ["192.168.0.2"]
+        }
+      ]
+    }
+    `)
+		case http.MethodPut:
+			var body []FallbackDomain
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, []FallbackDomain{
+				{Suffix: "example_one.com", DNSServer: []string{"192.168.0.1"}},
+				{Suffix: "example_two.com", DNSServer: []string{"10.0.0.1", "2001:db8::1"}},
+			}, body)
+			fmt.Fprintf(w, `
+    {
+      "success": true,
+      "errors": [],
+      "messages": [],
+      "result": [
+        {
+          "suffix": "example_one.com",
+          "dns_server": ["192.168.0.1"]
+        },
+        {
+          "suffix": "example_two.com",
+          "dns_server": ["10.0.0.1", "2001:db8::1"]
+        }
+      ]
+    }
+    `)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+policyID+"/fallback_domains", handler)
+
+	want := []FallbackDomain{
+		{Suffix: "example_one.com", DNSServer: []string{"192.168.0.1"}},
+		{Suffix: "example_two.com", DNSServer: []string{"10.0.0.1", "2001:db8::1"}},
+	}
+
+	actual, err := client.UpdateFallbackDomainDNSServers(context.Background(), testAccountID, policyID, "Example_Two.com.", []string{"10.0.0.1", "2001:db8::1"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, actual)
+	}
+
+	_, err = client.UpdateFallbackDomainDNSServers(context.Background(), testAccountID, policyID, "missing.com", []string{"10.0.0.1"})
+	assert.ErrorContains(t, err, "not found")
+
+	_, err = client.UpdateFallbackDomainDNSServers(context.Background(), testAccountID, policyID, "example_two.com", []string{"not-an-ip"})
+	assert.ErrorContains(t, err, "invalid fallback domain DNS server")
+}
+
+func TestAddAndRemoveFallbackDomains(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var put []FallbackDomain
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, `
+    {
+      "success": true,
+      "errors": [],
+      "messages": [],
+      "result": [
+        {
+          "suffix": "example_one.com"
+        },
+        {
+          "suffix": "example_two.com",
+          "dns_server": ["192.168.0.2"]
+        }
+      ]
+    }
+    `)
+		case http.MethodPut:
+			put = nil
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&put))
+			result, _ := json.Marshal(put)
+			fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": %s}`, result)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/fallback_domains", handler)
+
+	actual, err := client.AddFallbackDomains(context.Background(), testAccountID, "", []FallbackDomain{
+		{Suffix: "example_two.com", DNSServer: []string{"10.0.0.1"}},
+		{Suffix: "example_three.com"},
+	})
+	want := []FallbackDomain{
+		{Suffix: "example_one.com"},
+		{Suffix: "example_two.com", DNSServer: []string{"10.0.0.1"}},
+		{Suffix: "example_three.com"},
+	}
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, put)
+		assert.Equal(t, want, actual)
+	}
+
+	actual, err = client.RemoveFallbackDomains(context.Background(), testAccountID, "", []string{"example_one.com", "missing.com"})
+	want = []FallbackDomain{{Suffix: "example_two.com", DNSServer: []string{"192.168.0.2"}}}
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, put)
+		assert.Equal(t, want, actual)
+	}
+
+	put = nil
+	_, err = client.RemoveFallbackDomains(context.Background(), testAccountID, "", []string{"missing.com"})
+	if assert.NoError(t, err) {
+		assert.Nil(t, put, "expected no update when nothing is removed")
+	}
+
+	// Suffixes are matched once normalized.
+	actual, err = client.AddFallbackDomains(context.Background(), testAccountID, "", []FallbackDomain{
+		{Suffix: "Example_Two.com.", DNSServer: []string{"10.0.0.1"}},
+	})
+	want = []FallbackDomain{
+		{Suffix: "example_one.com"},
+		{Suffix: "Example_Two.com.", DNSServer: []string{"10.0.0.1"}},
+	}
+	if assert.NoError(t, err) {
+		assert.Equal(t, want, actual)
+	}
+
+	actual, err = client.RemoveFallbackDomains(context.Background(), testAccountID, "", []string{"EXAMPLE_ONE.COM."})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []FallbackDomain{{Suffix: "example_two.com", DNSServer: []string{"192.168.0.2"}}}, actual)
+	}
+}
+
+func TestNormalizeFallbackDomains(t *testing.T) {
+	domains := []FallbackDomain{
+		{Suffix: " Corp.Example.COM. ", Description: "Corp", DNSServer: []string{"2001:DB8:0::1", " 10.0.0.1"}},
+		{Suffix: "example.org", DNSServer: []string{}},
+	}
+
+	want := []FallbackDomain{
+		{Suffix: "corp.example.com", Description: "Corp", DNSServer: []string{"2001:db8::1", "10.0.0.1"}},
+		{Suffix: "example.org"},
+	}
+
+	actual := NormalizeFallbackDomains(domains)
+	assert.Equal(t, want, actual)
+	assert.Equal(t, want, NormalizeFallbackDomains(actual))
+	assert.Equal(t, " Corp.Example.COM. ", domains[0].Suffix, "input must not be modified")
+	assert.Nil(t, NormalizeFallbackDomains(nil))
+}
+
+func TestUpdateFallbackDomain_Limit(t *testing.T) {
+	setup(UsingDeviceListLimits(2, 1))
+	defer teardown()
+
+	requests := 0
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/fallback_domains", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": []}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/exclude", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": []}`)
+	})
+
+	domains := []FallbackDomain{{Suffix: "a.example.com"}, {Suffix: "b.example.com"}, {Suffix: "c.example.com"}}
+	_, err := client.UpdateFallbackDomain(context.Background(), testAccountID, domains)
+	assert.ErrorIs(t, err, ErrDeviceListLimitExceeded)
+	assert.EqualError(t, err, "3 fallback domains exceed the limit of 2")
+
+	_, err = client.UpdateSplitTunnel(context.Background(), testAccountID, "exclude", []SplitTunnel{{Address: "10.0.0.0/8"}, {Address: "192.0.2.0/24"}})
+	assert.ErrorIs(t, err, ErrDeviceListLimitExceeded)
+	assert.Equal(t, 0, requests)
+
+	_, err = client.UpdateFallbackDomain(context.Background(), testAccountID, domains[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestUpdateFallbackDomain_NoLimitByDefault(t *testing.T) {
+	setup()
+	defer teardown()
+
+	requests := 0
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/fallback_domains", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": []}`)
+	})
+
+	domains := make([]FallbackDomain, 2000)
+	for i := range domains {
+		domains[i] = FallbackDomain{Suffix: fmt.Sprintf("%d.example.com", i)}
+	}
+	_, err := client.UpdateFallbackDomain(context.Background(), testAccountID, domains)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
@@ -82,6 +82,57 @@ func BaseURL(baseURL string) Option {
 	}
 }
 
+// UsingStrictSplitTunnelValidation rejects split tunnel updates for which
+// ValidateSplitTunnelEntries, or ValidateSplitTunnelDoesNotBreakGateway for
+// exclude lists, reports warnings, returning a *SplitTunnelValidationError
+// before any request is made.
+func UsingStrictSplitTunnelValidation(strict bool) Option {
+	return func(api *API) error {
+		api.strictSplitTunnelValidation = strict
+		return nil
+	}
+}
+
+// UsingStrictDeviceDecoding makes the device, device settings policy, split
+// tunnel, fallback domain and device posture methods fail when a result has a
+// field the library doesn't model yet, with an error naming the field. It is
+// meant to detect API changes in integration tests and is off by default, as
+// it breaks on any field Cloudflare adds.
+func UsingStrictDeviceDecoding(strict bool) Option {
+	return func(api *API) error {
+		api.strictDeviceDecoding = strict
+		return nil
+	}
+}
+
+// UsingDeviceListLimits sets the maximum number of fallback domains and of
+// split tunnel entries accepted by a single update, e.g. to match the limits
+// of the plan of the account. Updates exceeding a limit are rejected with a
+// *DeviceListLimitError before any request is made. The limits are off by
+// default, leaving it to the API to reject lists that are too long; a limit
+// of zero or less disables the check.
+func UsingDeviceListLimits(fallbackDomains, splitTunnelEntries int) Option {
+	return func(api *API) error {
+		api.fallbackDomainLimit = fallbackDomains
+		api.splitTunnelLimit = splitTunnelEntries
+		return nil
+	}
+}
+
+// UsingDeviceRequestTimeout bounds every request made by the device, device
+// settings policy, split tunnel, fallback domain, device posture and DEX
+// methods to d, retries included, as a safety net for callers that don't set
+// a deadline on their context. It composes with the caller's deadline: the
+// shorter of the two wins. Methods making several requests apply d to each
+// request rather than to the whole call. Zero or less disables the timeout,
+// which is the default.
+func UsingDeviceRequestTimeout(d time.Duration) Option {
+	return func(api *API) error {
+		api.deviceRequestTimeout = d
+		return nil
+	}
+}
+
 func Debug(debug bool) Option {
 	return func(api *API) error {
 		api.Debug = debug
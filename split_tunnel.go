@@ -1,11 +1,16 @@
 package cloudflare
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
-
-	"github.com/goccy/go-json"
+	"net/netip"
+	"regexp"
+	"strings"
 )
 
 // SplitTunnelResponse represents the response from the get split
@@ -22,6 +27,30 @@ type SplitTunnel struct {
 	Description string `json:"description,omitempty"`
 }
 
+// RecommendedSplitTunnelExcludes is the exclude list Cloudflare applies to new
+// accounts. It covers the RFC 1918 private ranges, link-local, multicast and
+// other non-routable addresses that should never be sent through WARP.
+//
+// Source: https://developers.cloudflare.com/cloudflare-one/connections/connect-devices/warp/configure-warp/route-traffic/split-tunnels/#default-ip-addresses
+var RecommendedSplitTunnelExcludes = []SplitTunnel{
+	{Address: "10.0.0.0/8"},
+	{Address: "100.64.0.0/10"},
+	{Address: "169.254.0.0/16"},
+	{Address: "172.16.0.0/12"},
+	{Address: "192.0.0.0/24"},
+	{Address: "192.168.0.0/16"},
+	{Address: "224.0.0.0/24"},
+	{Address: "240.0.0.0/4"},
+	{Address: "255.255.255.255/32"},
+	{Address: "fe80::/10"},
+	{Address: "fd00::/8"},
+	{Address: "ff01::/16"},
+	{Address: "ff02::/16"},
+	{Address: "ff03::/16"},
+	{Address: "ff04::/16"},
+	{Address: "ff05::/16"},
+}
+
 // ListSplitTunnel returns all include or exclude split tunnel  within an account.
 //
 // API reference for include: https://api.cloudflare.com/#device-policy-get-split-tunnel-include-list
@@ -35,7 +64,7 @@ func (api *API) ListSplitTunnels(ctx context.Context, accountID string, mode str
 	}
 
 	var splitTunnelResponse SplitTunnelResponse
-	err = json.Unmarshal(res, &splitTunnelResponse)
+	err = api.unmarshalDeviceResponse(res, &splitTunnelResponse)
 	if err != nil {
 		return []SplitTunnel{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -48,6 +77,10 @@ func (api *API) ListSplitTunnels(ctx context.Context, accountID string, mode str
 // API reference for include: https://api.cloudflare.com/#device-policy-set-split-tunnel-include-list
 // API reference for exclude: https://api.cloudflare.com/#device-policy-set-split-tunnel-exclude-list
 func (api *API) UpdateSplitTunnel(ctx context.Context, accountID string, mode string, tunnels []SplitTunnel) ([]SplitTunnel, error) {
+	if err := api.validateSplitTunnelUpdate(mode, tunnels); err != nil {
+		return []SplitTunnel{}, err
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/policy/%s", AccountRouteRoot, accountID, mode)
 
 	res, err := api.makeRequestContext(ctx, http.MethodPut, uri, tunnels)
@@ -56,7 +89,7 @@ func (api *API) UpdateSplitTunnel(ctx context.Context, accountID string, mode st
 	}
 
 	var splitTunnelResponse SplitTunnelResponse
-	err = json.Unmarshal(res, &splitTunnelResponse)
+	err = api.unmarshalDeviceResponse(res, &splitTunnelResponse)
 	if err != nil {
 		return []SplitTunnel{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -77,7 +110,7 @@ func (api *API) ListSplitTunnelsDeviceSettingsPolicy(ctx context.Context, accoun
 	}
 
 	var splitTunnelResponse SplitTunnelResponse
-	err = json.Unmarshal(res, &splitTunnelResponse)
+	err = api.unmarshalDeviceResponse(res, &splitTunnelResponse)
 	if err != nil {
 		return []SplitTunnel{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -90,6 +123,10 @@ func (api *API) ListSplitTunnelsDeviceSettingsPolicy(ctx context.Context, accoun
 // API reference for include: https://api.cloudflare.com/#device-policy-set-split-tunnel-include-list
 // API reference for exclude: https://api.cloudflare.com/#device-policy-set-split-tunnel-exclude-list
 func (api *API) UpdateSplitTunnelDeviceSettingsPolicy(ctx context.Context, accountID, policyID string, mode string, tunnels []SplitTunnel) ([]SplitTunnel, error) {
+	if err := api.validateSplitTunnelUpdate(mode, tunnels); err != nil {
+		return []SplitTunnel{}, err
+	}
+
 	uri := fmt.Sprintf("/%s/%s/devices/policy/%s/%s", AccountRouteRoot, accountID, policyID, mode)
 
 	res, err := api.makeRequestContext(ctx, http.MethodPut, uri, tunnels)
@@ -98,10 +135,672 @@ func (api *API) UpdateSplitTunnelDeviceSettingsPolicy(ctx context.Context, accou
 	}
 
 	var splitTunnelResponse SplitTunnelResponse
-	err = json.Unmarshal(res, &splitTunnelResponse)
+	err = api.unmarshalDeviceResponse(res, &splitTunnelResponse)
 	if err != nil {
 		return []SplitTunnel{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
 	return splitTunnelResponse.Result, nil
 }
+
+// ResetSplitTunnelToRecommended replaces the exclude list with
+// RecommendedSplitTunnelExcludes. An empty policyID targets the account level
+// (default) list.
+//
+// API reference: https://api.cloudflare.com/#device-policy-set-split-tunnel-exclude-list
+func (api *API) ResetSplitTunnelToRecommended(ctx context.Context, accountID, policyID string) ([]SplitTunnel, error) {
+	return api.updateSplitTunnels(ctx, accountID, policyID, "exclude", GetRecommendedSplitTunnelExcludes())
+}
+
+// GetRecommendedSplitTunnelExcludes returns a copy of
+// RecommendedSplitTunnelExcludes, the exclude list Cloudflare recommends, to
+// compare against an account's own list before resetting it with
+// ResetSplitTunnelToRecommended. The copy can be changed freely.
+func GetRecommendedSplitTunnelExcludes() []SplitTunnel {
+	tunnels := make([]SplitTunnel, len(RecommendedSplitTunnelExcludes))
+	copy(tunnels, RecommendedSplitTunnelExcludes)
+
+	return tunnels
+}
+
+// DiffSplitTunnelEntries compares the split tunnel list tunnels to
+// reference, e.g. the recommended exclude list, and returns the entries of
+// reference missing from tunnels and the entries of tunnels that reference
+// doesn't have. Entries are compared by address and host only; addresses
+// are compared as masked prefixes and hosts case insensitively.
+func DiffSplitTunnelEntries(tunnels, reference []SplitTunnel) (missing, extra []SplitTunnel) {
+	key := func(tunnel SplitTunnel) string {
+		address := tunnel.Address
+		if prefix, err := parseSplitTunnelAddress(address); err == nil {
+			address = prefix.String()
+		}
+		return address + "|" + normalizeSplitTunnelHost(tunnel.Host)
+	}
+
+	have := make(map[string]bool, len(tunnels))
+	for _, tunnel := range tunnels {
+		have[key(tunnel)] = true
+	}
+	want := make(map[string]bool, len(reference))
+	for _, tunnel := range reference {
+		want[key(tunnel)] = true
+		if !have[key(tunnel)] {
+			missing = append(missing, tunnel)
+		}
+	}
+	for _, tunnel := range tunnels {
+		if !want[key(tunnel)] {
+			extra = append(extra, tunnel)
+		}
+	}
+
+	return missing, extra
+}
+
+// defaultSplitTunnelBatchID identifies the default policy in the errors of
+// ApplySplitTunnelDeltaToAllPolicies.
+const defaultSplitTunnelBatchID = "default"
+
+// ApplySplitTunnelDeltaParams describes a change applied to the split tunnel
+// lists of every device settings policy.
+type ApplySplitTunnelDeltaParams struct {
+	// Mode is the list to modify, "include" or "exclude".
+	Mode string
+	// Add holds entries appended to each list unless an entry with the same
+	// address or host is already present.
+	Add []SplitTunnel
+	// Remove holds entries removed from each list, matched by address or
+	// host.
+	Remove []SplitTunnel
+	// IncludeDefault also applies the change to the default policy.
+	IncludeDefault bool
+	// Concurrency is the maximum number of policies updated in parallel.
+	// Defaults to 4.
+	Concurrency int
+}
+
+// ApplySplitTunnelDeltaToAllPolicies adds and removes split tunnel entries in
+// every custom device settings policy of an account, and optionally the
+// default policy. Each list is read, modified and written back; lists that are
+// left unchanged are not written. Failures are returned as a
+// *DeviceBatchError keyed by policy ID, with the default policy keyed as
+// "default".
+//
+// API reference for include: https://api.cloudflare.com/#device-policy-set-split-tunnel-include-list
+// API reference for exclude: https://api.cloudflare.com/#device-policy-set-split-tunnel-exclude-list
+func (api *API) ApplySplitTunnelDeltaToAllPolicies(ctx context.Context, accountID string, params ApplySplitTunnelDeltaParams) error {
+	if params.Mode != "include" && params.Mode != "exclude" {
+		return fmt.Errorf("invalid split tunnel mode: %q", params.Mode)
+	}
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, AccountIdentifier(accountID), ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(policies)+1)
+	if params.IncludeDefault {
+		ids = append(ids, defaultSplitTunnelBatchID)
+	}
+	for _, policy := range policies {
+		if !policy.Default && policy.PolicyID != nil {
+			ids = append(ids, *policy.PolicyID)
+		}
+	}
+
+	errs := runDeviceBatch(ctx, uniqueDeviceBatchIDs(ids), params.Concurrency, func(ctx context.Context, id string) error {
+		policyID := id
+		if id == defaultSplitTunnelBatchID {
+			policyID = ""
+		}
+
+		tunnels, err := api.listSplitTunnels(ctx, accountID, policyID, params.Mode)
+		if err != nil {
+			return err
+		}
+
+		tunnels, changed := applySplitTunnelDelta(tunnels, params.Add, params.Remove)
+		if !changed {
+			return nil
+		}
+
+		_, err = api.updateSplitTunnels(ctx, accountID, policyID, params.Mode, tunnels)
+		return err
+	})
+
+	return newDeviceBatchError(errs)
+}
+
+// applySplitTunnelDelta returns tunnels without the entries of remove and with
+// the entries of add that aren't present yet, and whether anything changed.
+func applySplitTunnelDelta(tunnels, add, remove []SplitTunnel) ([]SplitTunnel, bool) {
+	key := func(t SplitTunnel) string {
+		if t.Address != "" {
+			return "address:" + t.Address
+		}
+		return "host:" + strings.ToLower(t.Host)
+	}
+
+	removed := make(map[string]bool, len(remove))
+	for _, t := range remove {
+		removed[key(t)] = true
+	}
+
+	result := make([]SplitTunnel, 0, len(tunnels)+len(add))
+	present := make(map[string]bool, len(tunnels)+len(add))
+	for _, t := range tunnels {
+		if removed[key(t)] {
+			continue
+		}
+		present[key(t)] = true
+		result = append(result, t)
+	}
+	changed := len(result) != len(tunnels)
+
+	for _, t := range add {
+		if present[key(t)] {
+			continue
+		}
+		present[key(t)] = true
+		result = append(result, t)
+		changed = true
+	}
+
+	return result, changed
+}
+
+// listSplitTunnels returns the split tunnel list of a device settings
+// policy, or of the account when policyID is empty.
+func (api *API) listSplitTunnels(ctx context.Context, accountID, policyID, mode string) ([]SplitTunnel, error) {
+	if policyID == "" {
+		return api.ListSplitTunnels(ctx, accountID, mode)
+	}
+
+	return api.ListSplitTunnelsDeviceSettingsPolicy(ctx, accountID, policyID, mode)
+}
+
+// updateSplitTunnels replaces the split tunnel list of a device settings
+// policy, or of the account when policyID is empty.
+func (api *API) updateSplitTunnels(ctx context.Context, accountID, policyID, mode string, tunnels []SplitTunnel) ([]SplitTunnel, error) {
+	if policyID == "" {
+		return api.UpdateSplitTunnel(ctx, accountID, mode, tunnels)
+	}
+
+	return api.UpdateSplitTunnelDeviceSettingsPolicy(ctx, accountID, policyID, mode, tunnels)
+}
+
+// SplitTunnelWarningType identifies the kind of problem found in a split
+// tunnel list.
+type SplitTunnelWarningType string
+
+const (
+	SplitTunnelWarningInvalidAddress   SplitTunnelWarningType = "invalid_address"
+	SplitTunnelWarningDuplicateAddress SplitTunnelWarningType = "duplicate_address"
+	SplitTunnelWarningContainedAddress SplitTunnelWarningType = "contained_address"
+	SplitTunnelWarningDuplicateHost    SplitTunnelWarningType = "duplicate_host"
+	SplitTunnelWarningContainedHost    SplitTunnelWarningType = "contained_host"
+	SplitTunnelWarningGatewayAddress   SplitTunnelWarningType = "gateway_address"
+	SplitTunnelWarningGatewayHost      SplitTunnelWarningType = "gateway_host"
+)
+
+// SplitTunnelWarning describes a non-fatal problem with a split tunnel entry.
+// Other is set when the problem is caused by another entry of the list.
+type SplitTunnelWarning struct {
+	Type    SplitTunnelWarningType
+	Entry   SplitTunnel
+	Other   *SplitTunnel
+	Message string
+}
+
+// SplitTunnelValidationError is returned by the split tunnel update methods
+// when strict validation is enabled and the list has warnings.
+type SplitTunnelValidationError struct {
+	Warnings []SplitTunnelWarning
+}
+
+func (e *SplitTunnelValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Warnings))
+	for _, w := range e.Warnings {
+		msgs = append(msgs, w.Message)
+	}
+
+	return "split tunnel validation failed: " + strings.Join(msgs, "; ")
+}
+
+// ValidateSplitTunnelEntries reports entries of a split tunnel list that are
+// malformed, duplicated or made redundant by another entry. Addresses may be
+// given as CIDR blocks or single IP addresses. As CIDR blocks can only
+// overlap by one containing the other, overlapping ranges are reported as
+// SplitTunnelWarningContainedAddress. Hosts are compared case insensitively
+// and a wildcard host (`*.example.com`) is considered to contain all of its
+// subdomains.
+func ValidateSplitTunnelEntries(entries []SplitTunnel) []SplitTunnelWarning {
+	var warnings []SplitTunnelWarning
+
+	type prefixEntry struct {
+		prefix netip.Prefix
+		entry  SplitTunnel
+	}
+	var prefixes []prefixEntry
+
+	type hostEntry struct {
+		host  string
+		entry SplitTunnel
+	}
+	var hosts []hostEntry
+
+	for _, entry := range entries {
+		if entry.Address != "" {
+			prefix, err := parseSplitTunnelAddress(entry.Address)
+			if err != nil {
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningInvalidAddress,
+					Entry:   entry,
+					Message: fmt.Sprintf("%q is not a valid IP address or CIDR block", entry.Address),
+				})
+				continue
+			}
+			prefixes = append(prefixes, prefixEntry{prefix: prefix, entry: entry})
+		}
+
+		if entry.Host != "" {
+			hosts = append(hosts, hostEntry{host: normalizeSplitTunnelHost(entry.Host), entry: entry})
+		}
+	}
+
+	for i := range prefixes {
+		for j := i + 1; j < len(prefixes); j++ {
+			a, b := prefixes[i], prefixes[j]
+			switch {
+			case a.prefix == b.prefix:
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningDuplicateAddress,
+					Entry:   b.entry,
+					Other:   &prefixes[i].entry,
+					Message: fmt.Sprintf("%q duplicates %q", b.entry.Address, a.entry.Address),
+				})
+			case prefixContains(a.prefix, b.prefix):
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningContainedAddress,
+					Entry:   b.entry,
+					Other:   &prefixes[i].entry,
+					Message: fmt.Sprintf("%q is contained in %q", b.entry.Address, a.entry.Address),
+				})
+			case prefixContains(b.prefix, a.prefix):
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningContainedAddress,
+					Entry:   a.entry,
+					Other:   &prefixes[j].entry,
+					Message: fmt.Sprintf("%q is contained in %q", a.entry.Address, b.entry.Address),
+				})
+			}
+		}
+	}
+
+	for i := range hosts {
+		for j := i + 1; j < len(hosts); j++ {
+			a, b := hosts[i], hosts[j]
+			switch {
+			case a.host == b.host:
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningDuplicateHost,
+					Entry:   b.entry,
+					Other:   &hosts[i].entry,
+					Message: fmt.Sprintf("%q duplicates %q", b.entry.Host, a.entry.Host),
+				})
+			case hostContains(a.host, b.host):
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningContainedHost,
+					Entry:   b.entry,
+					Other:   &hosts[i].entry,
+					Message: fmt.Sprintf("%q is covered by %q", b.entry.Host, a.entry.Host),
+				})
+			case hostContains(b.host, a.host):
+				warnings = append(warnings, SplitTunnelWarning{
+					Type:    SplitTunnelWarningContainedHost,
+					Entry:   a.entry,
+					Other:   &hosts[j].entry,
+					Message: fmt.Sprintf("%q is covered by %q", a.entry.Host, b.entry.Host),
+				})
+			}
+		}
+	}
+
+	return warnings
+}
+
+// CloudflareGatewayRanges are the address ranges of the Cloudflare services
+// the WARP client depends on: the WARP tunnel endpoints, the Gateway DNS
+// resolvers and the client orchestration API. Excluding them from the tunnel,
+// or including them in an include list by mistake, breaks DNS filtering and
+// traffic inspection. The list is a snapshot of
+// https://developers.cloudflare.com/cloudflare-one/connections/connect-devices/warp/deployment/firewall/
+// and should be updated when those ranges change.
+var CloudflareGatewayRanges = []SplitTunnel{
+	{Address: "162.159.36.0/24", Description: "Gateway DNS resolvers"},
+	{Address: "172.64.36.0/23", Description: "Gateway DNS resolvers"},
+	{Address: "162.159.137.105/32", Description: "WARP client orchestration API"},
+	{Address: "162.159.138.105/32", Description: "WARP client orchestration API"},
+	{Address: "162.159.192.0/24", Description: "WARP tunnel endpoints"},
+	{Address: "162.159.193.0/24", Description: "WARP tunnel endpoints"},
+	{Address: "162.159.197.0/24", Description: "WARP tunnel endpoints"},
+	{Address: "2606:4700:100::/48", Description: "WARP tunnel endpoints"},
+	{Address: "2606:4700:102::/48", Description: "WARP tunnel endpoints"},
+	{Address: "2606:4700:4700::/48", Description: "Gateway DNS resolvers"},
+	{Address: "2a06:98c1:54::/48", Description: "WARP client orchestration API"},
+}
+
+// CloudflareGatewayHosts are the domains of the Cloudflare services the WARP
+// client depends on, see CloudflareGatewayRanges.
+var CloudflareGatewayHosts = []string{
+	"cloudflare-gateway.com",
+	"cloudflareclient.com",
+	"cloudflare-dns.com",
+}
+
+// ValidateSplitTunnelDoesNotBreakGateway reports the exclude entries of policy
+// that overlap CloudflareGatewayRanges or cover one of
+// CloudflareGatewayHosts. Traffic to those services must go through the
+// tunnel for Gateway DNS and inspection to work, so such an entry is almost
+// always a mistake, e.g. a broad range that happens to contain the DNS
+// resolvers.
+//
+// The findings are warnings: the API accepts the list regardless. With
+// UsingStrictSplitTunnelValidation the split tunnel update methods reject
+// exclude lists with such entries instead.
+func ValidateSplitTunnelDoesNotBreakGateway(policy DeviceSettingsPolicy) []SplitTunnelWarning {
+	if policy.Exclude == nil {
+		return nil
+	}
+
+	return validateSplitTunnelGatewayEntries(*policy.Exclude)
+}
+
+func validateSplitTunnelGatewayEntries(entries []SplitTunnel) []SplitTunnelWarning {
+	var warnings []SplitTunnelWarning
+
+	for _, entry := range entries {
+		if entry.Address != "" {
+			prefix, err := parseSplitTunnelAddress(entry.Address)
+			if err == nil {
+				for i, gateway := range CloudflareGatewayRanges {
+					gatewayPrefix := netip.MustParsePrefix(gateway.Address)
+					if prefixContains(prefix, gatewayPrefix) || prefixContains(gatewayPrefix, prefix) {
+						warnings = append(warnings, SplitTunnelWarning{
+							Type:    SplitTunnelWarningGatewayAddress,
+							Entry:   entry,
+							Other:   &CloudflareGatewayRanges[i],
+							Message: fmt.Sprintf("%q overlaps %s %q", entry.Address, gateway.Description, gateway.Address),
+						})
+						break
+					}
+				}
+			}
+		}
+
+		if entry.Host != "" {
+			host := normalizeSplitTunnelHost(entry.Host)
+			for _, gateway := range CloudflareGatewayHosts {
+				if host == gateway || strings.HasSuffix(host, "."+gateway) || hostContains(host, gateway) {
+					warnings = append(warnings, SplitTunnelWarning{
+						Type:    SplitTunnelWarningGatewayHost,
+						Entry:   entry,
+						Message: fmt.Sprintf("%q covers the Cloudflare service domain %q", entry.Host, gateway),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return warnings
+}
+
+var (
+	// ErrUnknownSplitTunnelMode is returned by IsCIDRTunneled for policies
+	// without a known include or exclude list.
+	ErrUnknownSplitTunnelMode = errors.New("the split tunnel mode of the device settings policy is unknown")
+	// ErrCIDRPartiallyTunneled is returned by IsCIDRTunneled when only part
+	// of the traffic to a CIDR block goes through WARP.
+	ErrCIDRPartiallyTunneled = errors.New("traffic to the CIDR block is only partially tunneled")
+)
+
+// IsCIDRTunneledOption configures IsCIDRTunneled.
+type IsCIDRTunneledOption func(*isCIDRTunneledOptions)
+
+type isCIDRTunneledOptions struct {
+	ctx      context.Context
+	resolver *net.Resolver
+}
+
+// IsCIDRTunneledResolveHosts resolves the host entries of the split tunnel
+// list with resolver, or net.DefaultResolver when nil, so that the addresses
+// they currently resolve to are taken into account. Wildcard hosts can't be
+// resolved and are still ignored.
+func IsCIDRTunneledResolveHosts(ctx context.Context, resolver *net.Resolver) IsCIDRTunneledOption {
+	return func(o *isCIDRTunneledOptions) {
+		o.ctx = ctx
+		o.resolver = resolver
+		if o.resolver == nil {
+			o.resolver = net.DefaultResolver
+		}
+	}
+}
+
+// IsCIDRTunneled reports whether traffic from devices using policy to the
+// CIDR block, or single IP address, cidr goes through WARP according to the
+// split tunnel mode and entries of the policy. In include mode it is
+// tunneled when an include entry contains cidr, and in exclude mode when no
+// exclude entry overlaps it.
+//
+// ErrCIDRPartiallyTunneled is returned, with false, when an entry covers
+// only part of cidr, and ErrUnknownSplitTunnelMode when the policy has
+// neither list, as is often the case for policies returned by
+// ListDeviceSettingsPolicies. Host entries are ignored unless
+// IsCIDRTunneledResolveHosts is given.
+func IsCIDRTunneled(policy DeviceSettingsPolicy, cidr string, opts ...IsCIDRTunneledOption) (bool, error) {
+	o := isCIDRTunneledOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	target, err := parseSplitTunnelAddress(cidr)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR block %q: %w", cidr, err)
+	}
+
+	var entries []SplitTunnel
+	mode := policy.SplitTunnelMode()
+	switch mode {
+	case "include":
+		entries = *policy.Include
+	case "exclude":
+		entries = *policy.Exclude
+	default:
+		return false, ErrUnknownSplitTunnelMode
+	}
+
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if entry.Address != "" {
+			if prefix, err := parseSplitTunnelAddress(entry.Address); err == nil {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+
+		host := normalizeSplitTunnelHost(entry.Host)
+		if host == "" || o.resolver == nil || strings.HasPrefix(host, "*.") {
+			continue
+		}
+
+		addrs, err := o.resolver.LookupNetIP(o.ctx, "ip", host)
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve split tunnel host %q: %w", entry.Host, err)
+		}
+		for _, addr := range addrs {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	// CIDR blocks can only overlap by one containing the other.
+	covered, partial := false, false
+	for _, prefix := range prefixes {
+		switch {
+		case prefixContains(prefix, target):
+			covered = true
+		case prefixContains(target, prefix):
+			partial = true
+		}
+	}
+
+	if covered {
+		return mode == "include", nil
+	}
+	if partial {
+		return false, ErrCIDRPartiallyTunneled
+	}
+
+	return mode == "exclude", nil
+}
+
+// Text formats read by ParseSplitTunnelEntries.
+const (
+	// SplitTunnelFormatList is one CIDR block, IP address or host per line.
+	SplitTunnelFormatList = "list"
+	// SplitTunnelFormatCIDR is one CIDR block or IP address per line.
+	SplitTunnelFormatCIDR = "cidr"
+	// SplitTunnelFormatHosts is the hosts file format: an IP address
+	// followed by one or more host names per line.
+	SplitTunnelFormatHosts = "hosts"
+)
+
+// splitTunnelHostPattern matches the host names accepted in a split tunnel
+// entry, optionally with a leading wildcard label.
+var splitTunnelHostPattern = regexp.MustCompile(`^(\*\.)?([a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?\.)*[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?\.?$`)
+
+// ParseSplitTunnelEntries reads split tunnel entries from r in one of the
+// SplitTunnelFormat text formats, e.g. to feed an existing network ACL file
+// to UpdateSplitTunnel. Blank lines and everything after a `#` are ignored,
+// except that the comment at the end of a line in the list and CIDR formats
+// becomes the Description of its entry. In the hosts format, each host name
+// of a line becomes a host entry and the address is only validated.
+//
+// Every entry is validated and the first invalid one is reported with its
+// line number. Entries are returned in order, duplicates included; use
+// ValidateSplitTunnelEntries to find redundant ones.
+func ParseSplitTunnelEntries(r io.Reader, format string) ([]SplitTunnel, error) {
+	switch format {
+	case SplitTunnelFormatList, SplitTunnelFormatCIDR, SplitTunnelFormatHosts:
+	default:
+		return nil, fmt.Errorf("unsupported split tunnel format %q", format)
+	}
+
+	var tunnels []SplitTunnel
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, comment, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if format == SplitTunnelFormatHosts {
+			if _, err := netip.ParseAddr(fields[0]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid address %q", line, fields[0])
+			}
+			if len(fields) == 1 {
+				return nil, fmt.Errorf("line %d: missing host name after %q", line, fields[0])
+			}
+			for _, host := range fields[1:] {
+				if !splitTunnelHostPattern.MatchString(host) {
+					return nil, fmt.Errorf("line %d: invalid host %q", line, host)
+				}
+				tunnels = append(tunnels, SplitTunnel{Host: host})
+			}
+			continue
+		}
+
+		if len(fields) > 1 {
+			return nil, fmt.Errorf("line %d: expected a single entry, found %q", line, strings.TrimSpace(text))
+		}
+
+		tunnel := SplitTunnel{Description: strings.TrimSpace(comment)}
+		if _, err := parseSplitTunnelAddress(fields[0]); err == nil {
+			tunnel.Address = fields[0]
+		} else if format == SplitTunnelFormatList && splitTunnelHostPattern.MatchString(fields[0]) && !strings.Contains(fields[0], "/") {
+			tunnel.Host = fields[0]
+		} else {
+			return nil, fmt.Errorf("line %d: invalid entry %q", line, fields[0])
+		}
+		tunnels = append(tunnels, tunnel)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tunnels, nil
+}
+
+// validateSplitTunnelUpdate returns a *DeviceListLimitError if tunnels
+// exceeds the split tunnel limit, and a *SplitTunnelValidationError for
+// tunnels if strict split tunnel validation is enabled and the list has
+// warnings. Exclude lists are also checked for Cloudflare service ranges.
+func (api *API) validateSplitTunnelUpdate(mode string, tunnels []SplitTunnel) error {
+	if err := checkDeviceListLimit("split tunnel entries", len(tunnels), api.splitTunnelLimit); err != nil {
+		return err
+	}
+
+	if !api.strictSplitTunnelValidation {
+		return nil
+	}
+
+	warnings := ValidateSplitTunnelEntries(tunnels)
+	if mode == "exclude" {
+		warnings = append(warnings, validateSplitTunnelGatewayEntries(tunnels)...)
+	}
+
+	if len(warnings) > 0 {
+		return &SplitTunnelValidationError{Warnings: warnings}
+	}
+
+	return nil
+}
+
+// parseSplitTunnelAddress parses a CIDR block or single IP address into its
+// masked prefix.
+func parseSplitTunnelAddress(address string) (netip.Prefix, error) {
+	if strings.Contains(address, "/") {
+		prefix, err := netip.ParsePrefix(address)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// prefixContains reports whether b lies entirely within a.
+func prefixContains(a, b netip.Prefix) bool {
+	return a.Bits() <= b.Bits() && a.Contains(b.Addr())
+}
+
+func normalizeSplitTunnelHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// hostContains reports whether the wildcard host a covers host b.
+func hostContains(a, b string) bool {
+	if !strings.HasPrefix(a, "*.") {
+		return false
+	}
+
+	return strings.HasSuffix(b, a[1:])
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -298,3 +301,303 @@ func TestSplitTunnelsDeviceSettingsPolicy(t *testing.T) {
 		assert.Equal(t, want, actual)
 	}
 }
+
+func TestResetSplitTunnelToRecommended(t *testing.T) {
+	setup()
+	defer teardown()
+
+	policyID := "a842fa8a-a583-482e-9cd9-eb43362949fd"
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method, "Expected method 'PUT', got %s", r.Method)
+
+		var body []SplitTunnel
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, RecommendedSplitTunnelExcludes, body)
+
+		res, _ := json.Marshal(SplitTunnelResponse{Response: Response{Success: true}, Result: body})
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, string(res))
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/"+policyID+"/exclude", handler)
+
+	actual, err := client.ResetSplitTunnelToRecommended(context.Background(), testAccountID, policyID)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, RecommendedSplitTunnelExcludes, actual)
+	}
+}
+
+func TestValidateSplitTunnelEntries(t *testing.T) {
+	entries := []SplitTunnel{
+		{Address: "10.0.0.0/8"},
+		{Address: "10.1.0.0/16"},
+		{Address: "192.168.0.1"},
+		{Address: "192.168.0.1/32"},
+		{Address: "fd00::/8"},
+		{Address: "not-an-address"},
+		{Host: "*.example.com"},
+		{Host: "intranet.Example.com."},
+		{Host: "example.org"},
+		{Host: "EXAMPLE.org"},
+	}
+
+	warnings := ValidateSplitTunnelEntries(entries)
+
+	types := make([]SplitTunnelWarningType, 0, len(warnings))
+	for _, w := range warnings {
+		types = append(types, w.Type)
+	}
+
+	assert.Equal(t, []SplitTunnelWarningType{
+		SplitTunnelWarningInvalidAddress,
+		SplitTunnelWarningContainedAddress,
+		SplitTunnelWarningDuplicateAddress,
+		SplitTunnelWarningContainedHost,
+		SplitTunnelWarningDuplicateHost,
+	}, types)
+
+	assert.Equal(t, SplitTunnel{Address: "10.1.0.0/16"}, warnings[1].Entry)
+	assert.Equal(t, &SplitTunnel{Address: "10.0.0.0/8"}, warnings[1].Other)
+	assert.Equal(t, `"10.1.0.0/16" is contained in "10.0.0.0/8"`, warnings[1].Message)
+
+	// The recommended list carries the broadcast address separately even
+	// though 240.0.0.0/4 already covers it.
+	recommended := ValidateSplitTunnelEntries(RecommendedSplitTunnelExcludes)
+	if assert.Len(t, recommended, 1) {
+		assert.Equal(t, SplitTunnel{Address: "255.255.255.255/32"}, recommended[0].Entry)
+	}
+}
+
+func TestUpdateSplitTunnelStrictValidation(t *testing.T) {
+	setup(UsingStrictSplitTunnelValidation(true))
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/exclude", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("strict validation should reject the update before it is sent")
+	})
+
+	_, err := client.UpdateSplitTunnel(context.Background(), testAccountID, "exclude", []SplitTunnel{
+		{Address: "10.0.0.0/8"},
+		{Address: "10.1.0.0/16"},
+	})
+
+	var validationErr *SplitTunnelValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Len(t, validationErr.Warnings, 1)
+	}
+}
+
+func TestValidateSplitTunnelDoesNotBreakGateway(t *testing.T) {
+	exclude := []SplitTunnel{
+		{Address: "10.0.0.0/8"},
+		{Address: "172.64.0.0/13"},
+		{Address: "162.159.193.10"},
+		{Address: "2606:4700::/32"},
+		{Host: "example.com"},
+		{Host: "*.cloudflareclient.com"},
+	}
+	policy := DeviceSettingsPolicy{Exclude: &exclude}
+
+	warnings := ValidateSplitTunnelDoesNotBreakGateway(policy)
+	if assert.Len(t, warnings, 4) {
+		assert.Equal(t, SplitTunnelWarningGatewayAddress, warnings[0].Type)
+		assert.Equal(t, SplitTunnel{Address: "172.64.0.0/13"}, warnings[0].Entry)
+		assert.Equal(t, "172.64.36.0/23", warnings[0].Other.Address)
+		assert.Equal(t, SplitTunnel{Address: "162.159.193.10"}, warnings[1].Entry)
+		assert.Equal(t, SplitTunnel{Address: "2606:4700::/32"}, warnings[2].Entry)
+		assert.Equal(t, SplitTunnelWarningGatewayHost, warnings[3].Type)
+	}
+
+	assert.Empty(t, ValidateSplitTunnelDoesNotBreakGateway(DeviceSettingsPolicy{}))
+	assert.Empty(t, validateSplitTunnelGatewayEntries(RecommendedSplitTunnelExcludes))
+}
+
+func TestUpdateSplitTunnelStrictGatewayValidation(t *testing.T) {
+	setup(UsingStrictSplitTunnelValidation(true))
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/include", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": [{"address": "172.64.36.0/24"}]}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/exclude", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("strict validation should reject the update before it is sent")
+	})
+
+	tunnels := []SplitTunnel{{Address: "172.64.36.0/24"}}
+
+	_, err := client.UpdateSplitTunnel(context.Background(), testAccountID, "include", tunnels)
+	assert.NoError(t, err)
+
+	_, err = client.UpdateSplitTunnel(context.Background(), testAccountID, "exclude", tunnels)
+	var validationErr *SplitTunnelValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, SplitTunnelWarningGatewayAddress, validationErr.Warnings[0].Type)
+	}
+}
+
+func TestApplySplitTunnelDeltaToAllPolicies(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [{"policy_id": "policy-1"}, {"policy_id": "policy-2"}, {"policy_id": "policy-3"}],
+			"result_info": {"page": 1, "per_page": 20, "count": 3, "total_count": 3, "total_pages": 1}
+		}`)
+	})
+
+	var mu sync.Mutex
+	updates := map[string][]SplitTunnel{}
+	handler := func(id string, current []SplitTunnel) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			body := current
+			switch r.Method {
+			case http.MethodGet:
+			case http.MethodPut:
+				body = nil
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				mu.Lock()
+				updates[id] = body
+				mu.Unlock()
+			default:
+				t.Errorf("unexpected method %s", r.Method)
+			}
+			res, _ := json.Marshal(SplitTunnelResponse{Response: Response{Success: true}, Result: body})
+			fmt.Fprint(w, string(res))
+		}
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/exclude", handler("default", []SplitTunnel{{Address: "10.0.0.0/8"}}))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/policy-1/exclude", handler("policy-1", []SplitTunnel{{Address: "10.0.0.0/8"}, {Host: "old.example.com"}}))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/policy-2/exclude", handler("policy-2", []SplitTunnel{{Address: "203.0.113.0/24"}}))
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy/policy-3/exclude", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"success": false, "errors": [{"code": 1000, "message": "boom"}]}`)
+	})
+
+	err := client.ApplySplitTunnelDeltaToAllPolicies(context.Background(), testAccountID, ApplySplitTunnelDeltaParams{
+		Mode:        "exclude",
+		Add:         []SplitTunnel{{Address: "203.0.113.0/24", Description: "SaaS"}},
+		Remove:      []SplitTunnel{{Host: "old.example.com"}},
+		Concurrency: 2,
+	})
+
+	var batchErr *DeviceBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Len(t, batchErr.Errors, 1)
+		assert.Contains(t, batchErr.Errors, "policy-3")
+	}
+
+	assert.Equal(t, map[string][]SplitTunnel{
+		"policy-1": {{Address: "10.0.0.0/8"}, {Address: "203.0.113.0/24", Description: "SaaS"}},
+	}, updates, "the default policy is skipped and unchanged lists are not written")
+}
+
+func TestApplySplitTunnelDeltaToAllPoliciesInvalidMode(t *testing.T) {
+	err := client.ApplySplitTunnelDeltaToAllPolicies(context.Background(), testAccountID, ApplySplitTunnelDeltaParams{Mode: "both"})
+	assert.ErrorContains(t, err, "invalid split tunnel mode")
+}
+
+func TestIsCIDRTunneled(t *testing.T) {
+	include := DeviceSettingsPolicy{Include: &[]SplitTunnel{{Address: "10.0.0.0/8"}, {Host: "localhost"}}}
+	exclude := DeviceSettingsPolicy{Exclude: &[]SplitTunnel{{Address: "10.2.0.0/16"}, {Address: "192.168.1.1"}}}
+
+	testCases := map[string]struct {
+		policy   DeviceSettingsPolicy
+		cidr     string
+		expected bool
+		err      error
+	}{
+		"included":           {include, "10.2.0.0/16", true, nil},
+		"not included":       {include, "172.16.0.0/12", false, nil},
+		"partially included": {include, "10.0.0.0/7", false, ErrCIDRPartiallyTunneled},
+		"host not resolved":  {include, "127.0.0.1", false, nil},
+		"excluded":           {exclude, "10.2.3.0/24", false, nil},
+		"not excluded":       {exclude, "10.3.0.0/16", true, nil},
+		"partially excluded": {exclude, "192.168.1.0/24", false, ErrCIDRPartiallyTunneled},
+		"unknown mode":       {DeviceSettingsPolicy{}, "10.0.0.0/8", false, ErrUnknownSplitTunnelMode},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := IsCIDRTunneled(tc.policy, tc.cidr)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+
+	_, err := IsCIDRTunneled(include, "10.0.0.0/33")
+	assert.Error(t, err)
+
+	actual, err := IsCIDRTunneled(include, "127.0.0.1", IsCIDRTunneledResolveHosts(context.Background(), nil))
+	assert.NoError(t, err)
+	assert.True(t, actual)
+}
+
+func TestGetRecommendedSplitTunnelExcludes(t *testing.T) {
+	recommended := GetRecommendedSplitTunnelExcludes()
+	assert.Equal(t, RecommendedSplitTunnelExcludes, recommended)
+
+	// The result is a copy.
+	recommended[0].Address = "10.1.0.0/16"
+	assert.Equal(t, "10.0.0.0/8", RecommendedSplitTunnelExcludes[0].Address)
+
+	custom := append([]SplitTunnel{{Address: "203.0.113.0/24"}, {Host: "VPN.example.com"}}, RecommendedSplitTunnelExcludes[1:]...)
+	missing, extra := DiffSplitTunnelEntries(custom, RecommendedSplitTunnelExcludes)
+	assert.Equal(t, []SplitTunnel{{Address: "10.0.0.0/8"}}, missing)
+	assert.Equal(t, []SplitTunnel{{Address: "203.0.113.0/24"}, {Host: "VPN.example.com"}}, extra)
+
+	missing, extra = DiffSplitTunnelEntries([]SplitTunnel{{Address: "10.1.2.3/8"}}, []SplitTunnel{{Address: "10.0.0.0/8"}})
+	assert.Empty(t, missing)
+	assert.Empty(t, extra)
+}
+
+func TestParseSplitTunnelEntries(t *testing.T) {
+	input := `# office networks
+10.0.0.0/8   # corporate
+192.168.1.1
+
+*.example.com
+intranet.example.com # wiki
+`
+	tunnels, err := ParseSplitTunnelEntries(strings.NewReader(input), SplitTunnelFormatList)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []SplitTunnel{
+			{Address: "10.0.0.0/8", Description: "corporate"},
+			{Address: "192.168.1.1"},
+			{Host: "*.example.com"},
+			{Host: "intranet.example.com", Description: "wiki"},
+		}, tunnels)
+	}
+
+	_, err = ParseSplitTunnelEntries(strings.NewReader("10.0.0.0/8\nexample.com\n"), SplitTunnelFormatCIDR)
+	assert.EqualError(t, err, `line 2: invalid entry "example.com"`)
+
+	_, err = ParseSplitTunnelEntries(strings.NewReader("10.0.0.0/33\n"), SplitTunnelFormatList)
+	assert.EqualError(t, err, `line 1: invalid entry "10.0.0.0/33"`)
+
+	hosts := "127.0.0.1 localhost\n10.1.2.3 db.internal db # primary\n"
+	tunnels, err = ParseSplitTunnelEntries(strings.NewReader(hosts), SplitTunnelFormatHosts)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []SplitTunnel{{Host: "localhost"}, {Host: "db.internal"}, {Host: "db"}}, tunnels)
+	}
+
+	_, err = ParseSplitTunnelEntries(strings.NewReader("db.internal 10.1.2.3\n"), SplitTunnelFormatHosts)
+	assert.EqualError(t, err, `line 1: invalid address "db.internal"`)
+
+	_, err = ParseSplitTunnelEntries(strings.NewReader(""), "csv")
+	assert.EqualError(t, err, `unsupported split tunnel format "csv"`)
+}
@@ -2,10 +2,12 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-
-	"github.com/goccy/go-json"
+	"sort"
+	"strings"
+	"time"
 )
 
 type TeamsDevicesList struct {
@@ -41,6 +43,40 @@ type TeamsDeviceListItem struct {
 	RevokedAt        string   `json:"revoked_at,omitempty"`
 }
 
+// DeviceType is the normalised platform of a device.
+type DeviceType string
+
+const (
+	DeviceTypeWindows  DeviceType = "windows"
+	DeviceTypeMac      DeviceType = "mac"
+	DeviceTypeLinux    DeviceType = "linux"
+	DeviceTypeIOS      DeviceType = "ios"
+	DeviceTypeAndroid  DeviceType = "android"
+	DeviceTypeChromeOS DeviceType = "chromeos"
+	DeviceTypeUnknown  DeviceType = "unknown"
+)
+
+// NormalizeDeviceType maps the platform reported for a device onto a
+// DeviceType. Unrecognised platforms are reported as DeviceTypeUnknown.
+func NormalizeDeviceType(deviceType string) DeviceType {
+	switch strings.ToLower(strings.TrimSpace(deviceType)) {
+	case "windows", "win":
+		return DeviceTypeWindows
+	case "mac", "macos", "darwin", "osx":
+		return DeviceTypeMac
+	case "linux":
+		return DeviceTypeLinux
+	case "ios", "ipados":
+		return DeviceTypeIOS
+	case "android":
+		return DeviceTypeAndroid
+	case "chromeos", "chrome os":
+		return DeviceTypeChromeOS
+	default:
+		return DeviceTypeUnknown
+	}
+}
+
 type UserItem struct {
 	ID    string `json:"id,omitempty"`
 	Name  string `json:"name,omitempty"`
@@ -59,7 +95,7 @@ func (api *API) ListTeamsDevices(ctx context.Context, accountID string) ([]Teams
 	}
 
 	var response TeamsDevicesList
-	err = json.Unmarshal(res, &response)
+	err = api.unmarshalDeviceResponse(res, &response)
 	if err != nil {
 		return []TeamsDeviceListItem{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
@@ -79,13 +115,187 @@ func (api *API) RevokeTeamsDevices(ctx context.Context, accountID string, device
 	}
 
 	result := Response{}
-	if err := json.Unmarshal(res, &result); err != nil {
+	if err := api.unmarshalDeviceResponse(res, &result); err != nil {
 		return result, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
 	return result, err
 }
 
+// defaultRevokeDevicesBatchSize is the number of devices revoked per request
+// by RevokeDevices when no batch size is given.
+const defaultRevokeDevicesBatchSize = 100
+
+type RevokeDevicesParams struct {
+	DeviceIDs []string
+	// BatchSize is the number of devices revoked per request. Defaults to
+	// 100. It is halved, down to a single device, every time a batch is
+	// rate limited.
+	BatchSize int
+	// MaxRateLimitWaits is the number of consecutive rate limited batches
+	// after which RevokeDevices gives up. Defaults to 10.
+	MaxRateLimitWaits int
+	// RateLimitWait is the time waited after a rate limited batch when the
+	// API doesn't send a Retry-After header. Defaults to 10 seconds.
+	RateLimitWait time.Duration
+}
+
+// RevokeDevicesResult reports the progress of RevokeDevices.
+type RevokeDevicesResult struct {
+	// Revoked holds the IDs of the revoked devices.
+	Revoked []string
+	// Remaining holds the IDs that were not revoked when RevokeDevices
+	// returned early.
+	Remaining []string
+	// Duration is the time RevokeDevices took, including rate limit waits.
+	Duration time.Duration
+	// Throughput is the effective number of devices revoked per second.
+	Throughput float64
+}
+
+// RevokeDevices revokes many devices in batches. When a batch is rate
+// limited, RevokeDevices waits as long as the Retry-After header of the API
+// asks, or RateLimitWait, and retries with a batch half the size. Duplicate
+// and empty IDs are ignored.
+//
+// RevokeDevices returns once every device is revoked or when it can't make
+// progress: on an error other than rate limiting, after MaxRateLimitWaits
+// consecutive rate limited batches, or when ctx is done. The result is
+// returned in every case, with the devices left to revoke in Remaining.
+//
+// API reference: https://api.cloudflare.com/#devices-revoke-devices
+func (api *API) RevokeDevices(ctx context.Context, accountID string, params RevokeDevicesParams) (RevokeDevicesResult, error) {
+	if params.BatchSize < 1 {
+		params.BatchSize = defaultRevokeDevicesBatchSize
+	}
+	if params.MaxRateLimitWaits < 1 {
+		params.MaxRateLimitWaits = 10
+	}
+	if params.RateLimitWait <= 0 {
+		params.RateLimitWait = 10 * time.Second
+	}
+
+	start := time.Now()
+	remaining := uniqueDeviceBatchIDs(params.DeviceIDs)
+	result := RevokeDevicesResult{}
+
+	finish := func(err error) (RevokeDevicesResult, error) {
+		result.Remaining = remaining
+		result.Duration = time.Since(start)
+		if seconds := result.Duration.Seconds(); seconds > 0 {
+			result.Throughput = float64(len(result.Revoked)) / seconds
+		}
+		return result, err
+	}
+
+	batchSize := params.BatchSize
+	waits := 0
+	for len(remaining) > 0 {
+		size := batchSize
+		if size > len(remaining) {
+			size = len(remaining)
+		}
+
+		_, err := api.RevokeTeamsDevices(ctx, accountID, remaining[:size])
+		if err == nil {
+			result.Revoked = append(result.Revoked, remaining[:size]...)
+			remaining = remaining[size:]
+			waits = 0
+			continue
+		}
+
+		wait, limited := revokeDevicesRateLimitWait(err)
+		if !limited {
+			return finish(err)
+		}
+
+		waits++
+		if waits > params.MaxRateLimitWaits {
+			return finish(err)
+		}
+
+		if batchSize > 1 {
+			batchSize /= 2
+		}
+		if wait <= 0 {
+			wait = params.RateLimitWait
+		}
+
+		api.logger.Printf("Revoking devices is rate limited, waiting %s before revoking %d more", wait, batchSize)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return finish(ctx.Err())
+		}
+	}
+
+	return finish(nil)
+}
+
+// ErrMissingDeviceUserEmail is returned by RevokeDevicesByUser when no email
+// address is given.
+var ErrMissingDeviceUserEmail = errors.New("missing required device user email")
+
+// RevokeDevicesByUserResult reports the devices of a user revoked by
+// RevokeDevicesByUser.
+type RevokeDevicesByUserResult struct {
+	RevokeDevicesResult
+	// Found is the number of active devices the user had enrolled.
+	Found int
+}
+
+// RevokeDevicesByUser revokes every device enrolled by the user with email,
+// e.g. when offboarding them. Devices that are deleted or already revoked
+// are skipped. The devices are revoked with RevokeDevices and default
+// parameters, so on failure the devices left to revoke are reported in
+// Remaining.
+//
+// API reference: https://api.cloudflare.com/#devices-revoke-devices
+func (api *API) RevokeDevicesByUser(ctx context.Context, accountID, email string) (RevokeDevicesByUserResult, error) {
+	if email == "" {
+		return RevokeDevicesByUserResult{}, ErrMissingDeviceUserEmail
+	}
+
+	devices, err := api.ListDevices(ctx, accountID, ListDevicesParams{UserEmail: email})
+	if err != nil {
+		return RevokeDevicesByUserResult{}, err
+	}
+
+	var ids []string
+	for _, device := range devices {
+		if device.RevokedAt != "" {
+			continue
+		}
+		ids = append(ids, device.ID)
+	}
+
+	result := RevokeDevicesByUserResult{Found: len(ids)}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	result.RevokeDevicesResult, err = api.RevokeDevices(ctx, accountID, RevokeDevicesParams{DeviceIDs: ids})
+
+	return result, err
+}
+
+// revokeDevicesRateLimitWait reports whether err is a rate limit error and
+// the delay the API asked for, if any.
+func revokeDevicesRateLimitWait(err error) (time.Duration, bool) {
+	var exceeded *rateLimitRetriesExceededError
+	if errors.As(err, &exceeded) {
+		return exceeded.retryAfter, true
+	}
+
+	var limited *RatelimitError
+	if errors.As(err, &limited) {
+		return 0, true
+	}
+
+	return 0, false
+}
+
 // GetTeamsDeviceDetails gets device details.
 //
 // API reference : https://api.cloudflare.com/#devices-device-details
@@ -98,10 +308,481 @@ func (api *API) GetTeamsDeviceDetails(ctx context.Context, accountID string, dev
 	}
 
 	var response TeamsDeviceDetail
-	err = json.Unmarshal(res, &response)
+	err = api.unmarshalDeviceResponse(res, &response)
 	if err != nil {
 		return TeamsDeviceListItem{}, fmt.Errorf("%s: %w", errUnmarshalError, err)
 	}
 
 	return response.Result, nil
 }
+
+// DeviceOSDistribution returns the number of devices in an account for each
+// platform. Deleted and revoked devices are not counted, as they no longer
+// connect.
+//
+// API reference : https://api.cloudflare.com/#devices-list-devices
+func (api *API) DeviceOSDistribution(ctx context.Context, accountID string) (map[DeviceType]int, error) {
+	devices, err := api.ListTeamsDevices(ctx, accountID)
+	if err != nil {
+		return map[DeviceType]int{}, err
+	}
+
+	distribution := make(map[DeviceType]int)
+	for _, device := range devices {
+		if device.Deleted || device.RevokedAt != "" {
+			continue
+		}
+		distribution[NormalizeDeviceType(device.DeviceType)]++
+	}
+
+	return distribution, nil
+}
+
+// ListDevicesForPolicy returns the devices that would be assigned to a device
+// settings policy. An empty policyID selects the default policy. Deleted
+// devices are skipped.
+//
+// The API has no endpoint reporting the policy of a device, so the match
+// expressions of all policies are evaluated client side using
+// EvaluateDeviceSettingsPolicyMatch. Only the user email, operating system and
+// operating system version are known for each device; expressions relying on
+// group membership, managed networks or device posture results are evaluated
+// as if the device had none of them.
+//
+// API reference: https://api.cloudflare.com/#devices-list-devices
+func (api *API) ListDevicesForPolicy(ctx context.Context, accountID, policyID string) ([]TeamsDeviceListItem, error) {
+	policies, err := api.deviceSettingsPoliciesWithDefault(ctx, accountID)
+	if err != nil {
+		return []TeamsDeviceListItem{}, err
+	}
+
+	if policyID != "" {
+		found := false
+		for _, policy := range policies {
+			if policy.PolicyID != nil && *policy.PolicyID == policyID && !policy.Default {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []TeamsDeviceListItem{}, fmt.Errorf("device settings policy %q not found", policyID)
+		}
+	}
+
+	devices, err := api.ListTeamsDevices(ctx, accountID)
+	if err != nil {
+		return []TeamsDeviceListItem{}, err
+	}
+
+	var matched []TeamsDeviceListItem
+	for _, device := range devices {
+		if device.Deleted {
+			continue
+		}
+
+		policy, err := evaluateDevicePolicy(policies, device)
+		if err != nil {
+			return []TeamsDeviceListItem{}, err
+		}
+
+		switch {
+		case policy == nil:
+		case policyID == "" && policy.Default:
+			matched = append(matched, device)
+		case policyID != "" && !policy.Default && policy.PolicyID != nil && *policy.PolicyID == policyID:
+			matched = append(matched, device)
+		}
+	}
+
+	return matched, nil
+}
+
+// DeviceCountsByPolicy returns the number of devices assigned to each device
+// settings policy of an account, keyed by policy ID, with the default policy
+// keyed as "default". Every policy is included, with a count of zero when no
+// device is assigned to it. Deleted devices are not counted.
+//
+// Policies are assigned like ListDevicesForPolicy does, with the same
+// limitations, but the devices and policies are only listed once for all
+// policies.
+//
+// API reference: https://api.cloudflare.com/#devices-list-devices
+func (api *API) DeviceCountsByPolicy(ctx context.Context, accountID string) (map[string]int, error) {
+	policies, err := api.deviceSettingsPoliciesWithDefault(ctx, accountID)
+	if err != nil {
+		return map[string]int{}, err
+	}
+
+	counts := make(map[string]int, len(policies))
+	for _, policy := range policies {
+		counts[devicePolicyCountKey(policy)] = 0
+	}
+
+	devices, err := api.ListTeamsDevices(ctx, accountID)
+	if err != nil {
+		return map[string]int{}, err
+	}
+
+	for _, device := range devices {
+		if device.Deleted {
+			continue
+		}
+
+		policy, err := evaluateDevicePolicy(policies, device)
+		if err != nil {
+			return map[string]int{}, err
+		}
+
+		if policy != nil {
+			counts[devicePolicyCountKey(*policy)]++
+		}
+	}
+
+	return counts, nil
+}
+
+// devicePolicyCountKey returns the key of a policy in the result of
+// DeviceCountsByPolicy.
+func devicePolicyCountKey(policy DeviceSettingsPolicy) string {
+	if policy.Default || policy.PolicyID == nil {
+		return "default"
+	}
+
+	return *policy.PolicyID
+}
+
+// deviceSettingsPoliciesWithDefault returns the custom device settings
+// policies of an account followed by its default policy.
+func (api *API) deviceSettingsPoliciesWithDefault(ctx context.Context, accountID string) ([]DeviceSettingsPolicy, error) {
+	rc := AccountIdentifier(accountID)
+
+	policies, _, err := api.ListDeviceSettingsPolicies(ctx, rc, ListDeviceSettingsPoliciesParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	defaultPolicy, err := api.GetDefaultDeviceSettingsPolicy(ctx, rc, GetDefaultDeviceSettingsPolicyParams{})
+	if err != nil {
+		return nil, err
+	}
+	defaultPolicy.Default = true
+
+	return append(policies, defaultPolicy), nil
+}
+
+// evaluateDevicePolicy returns the policy a device is assigned to, based on
+// the attributes known for it.
+func evaluateDevicePolicy(policies []DeviceSettingsPolicy, device TeamsDeviceListItem) (*DeviceSettingsPolicy, error) {
+	return EvaluateDeviceSettingsPolicyMatch(policies, DeviceMatchAttributes{
+		Email:     device.User.Email,
+		OSName:    string(NormalizeDeviceType(device.DeviceType)),
+		OSVersion: device.OSVersion,
+	})
+}
+
+// DeviceListDelta is the difference between the devices known to a caller
+// and the devices of an account.
+type DeviceListDelta struct {
+	// Changed holds the devices that are new or were updated after the time
+	// the caller knows them at.
+	Changed []TeamsDeviceListItem
+	// Removed holds the known device IDs that no longer exist or were
+	// deleted, in ascending order.
+	Removed []string
+}
+
+// ListDevicesIncremental compares the devices of an account with knownIDs,
+// the devices a caller already has mapped to the time they were last updated,
+// and returns only the differences.
+//
+// The API has no filter on the update time, so every device is listed and the
+// comparison is made client side using the Updated timestamp of each device.
+// Devices whose Updated timestamp can't be parsed are always reported as
+// changed.
+//
+// API reference: https://api.cloudflare.com/#devices-list-devices
+func (api *API) ListDevicesIncremental(ctx context.Context, accountID string, knownIDs map[string]time.Time) (DeviceListDelta, error) {
+	devices, err := api.ListTeamsDevices(ctx, accountID)
+	if err != nil {
+		return DeviceListDelta{}, err
+	}
+
+	delta := DeviceListDelta{}
+	present := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		if device.Deleted {
+			continue
+		}
+		present[device.ID] = true
+
+		known, ok := knownIDs[device.ID]
+		if !ok {
+			delta.Changed = append(delta.Changed, device)
+			continue
+		}
+
+		updated, err := time.Parse(time.RFC3339, device.Updated)
+		if err != nil || updated.After(known) {
+			delta.Changed = append(delta.Changed, device)
+		}
+	}
+
+	for id := range knownIDs {
+		if !present[id] {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+	sort.Strings(delta.Removed)
+
+	return delta, nil
+}
+
+// DeviceReconciliation is the drift between the devices enrolled in an
+// account and an external inventory.
+type DeviceReconciliation struct {
+	// Unexpected holds the enrolled devices that the inventory doesn't
+	// expect, either because they are missing from it or because it marks
+	// them as not to be enrolled.
+	Unexpected []TeamsDeviceListItem
+	// Missing holds the inventory keys expected to be enrolled that match
+	// no enrolled device, in ascending order.
+	Missing []string
+}
+
+// ReconcileDevices compares the devices enrolled in an account with expected,
+// an inventory mapping a device serial number or ID to whether the device
+// should be enrolled, and reports the differences. Nothing is changed.
+//
+// A device matches an inventory key equal to its serial number or to its ID.
+// Deleted and revoked devices are not considered enrolled.
+//
+// API reference: https://api.cloudflare.com/#devices-list-devices
+func (api *API) ReconcileDevices(ctx context.Context, accountID string, expected map[string]bool) (DeviceReconciliation, error) {
+	devices, err := api.ListTeamsDevices(ctx, accountID)
+	if err != nil {
+		return DeviceReconciliation{}, err
+	}
+
+	reconciliation := DeviceReconciliation{}
+	enrolled := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		if device.Deleted || device.RevokedAt != "" {
+			continue
+		}
+
+		matched := false
+		for _, key := range []string{device.SerialNumber, device.ID} {
+			if key == "" {
+				continue
+			}
+			if want, ok := expected[key]; ok {
+				enrolled[key] = true
+				matched = matched || want
+			}
+		}
+
+		if !matched {
+			reconciliation.Unexpected = append(reconciliation.Unexpected, device)
+		}
+	}
+
+	for key, want := range expected {
+		if want && !enrolled[key] {
+			reconciliation.Missing = append(reconciliation.Missing, key)
+		}
+	}
+	sort.Strings(reconciliation.Missing)
+
+	return reconciliation, nil
+}
+
+// DeviceUser is the identity a device is enrolled with.
+type DeviceUser struct {
+	ID    string
+	Name  string
+	Email string
+	// IDP is the identity provider the user last authenticated with. It is
+	// empty when Access hasn't seen an identity for the user yet.
+	IDP AccessUserIDP
+	// Groups are the identity provider groups of the user, as matched by
+	// the identity.groups selectors of device settings policies.
+	Groups []DeviceMatchGroup
+}
+
+// GetDeviceUser returns the user a device is enrolled with, along with the
+// identity provider and groups of the identity Access last saw for that user.
+// nil is returned without an error when the device has no user, as with
+// devices enrolled with a service token.
+//
+// API reference: https://api.cloudflare.com/#devices-device-details
+func (api *API) GetDeviceUser(ctx context.Context, accountID, deviceID string) (*DeviceUser, error) {
+	device, err := api.GetTeamsDeviceDetails(ctx, accountID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if device.User.ID == "" {
+		return nil, nil
+	}
+
+	user := &DeviceUser{
+		ID:    device.User.ID,
+		Name:  device.User.Name,
+		Email: device.User.Email,
+	}
+
+	identity, err := api.GetAccessUserLastSeenIdentity(ctx, AccountIdentifier(accountID), device.User.ID)
+	if err != nil {
+		var notFound *NotFoundError
+		if errors.As(err, &notFound) {
+			return user, nil
+		}
+		return nil, err
+	}
+
+	user.IDP = identity.IDP
+	for _, group := range identity.Groups {
+		user.Groups = append(user.Groups, DeviceMatchGroup{ID: group.ID, Name: group.Name, Email: group.Email})
+	}
+
+	return user, nil
+}
+
+// defaultDeviceLocationWindow is how far back ListDevices looks for the data
+// center devices connected through.
+const defaultDeviceLocationWindow = time.Hour
+
+// ListDevicesParams filters the devices returned by ListDevices.
+type ListDevicesParams struct {
+	// Colo only returns devices whose last connection seen by Digital
+	// Experience Monitoring went through the Cloudflare data center with
+	// this IATA code, e.g. "SJC".
+	Colo string
+	// Window is how far back connections are considered when filtering by
+	// Colo. Defaults to one hour.
+	Window time.Duration
+	// UserEmail only returns devices enrolled by the user with this email
+	// address, compared case insensitively.
+	UserEmail string
+}
+
+// DeviceFleetStatus is the connection status of a device as last reported to
+// Digital Experience Monitoring.
+type DeviceFleetStatus struct {
+	DeviceID  string `json:"deviceId"`
+	Colo      string `json:"colo"`
+	Mode      string `json:"mode"`
+	Platform  string `json:"platform"`
+	Status    string `json:"status"`
+	Version   string `json:"version"`
+	Timestamp string `json:"timestamp"`
+}
+
+// DeviceFleetStatusListResponse represents the response from the DEX fleet
+// status devices endpoint.
+type DeviceFleetStatusListResponse struct {
+	Response
+	Result     []DeviceFleetStatus `json:"result"`
+	ResultInfo `json:"result_info"`
+}
+
+// deviceFleetStatusParams are the query parameters of the DEX fleet status
+// devices endpoint. The endpoint can filter by colo, but all devices are
+// fetched so that a device is matched on its most recent colo only.
+type deviceFleetStatusParams struct {
+	TimeStart string `url:"time_start"`
+	TimeEnd   string `url:"time_end"`
+	ResultInfo
+}
+
+// ListDevices returns the devices of an account that aren't deleted,
+// optionally only those last connected through a given data center.
+//
+// Filtering by Colo relies on Digital Experience Monitoring data, so it is
+// only as accurate as that data: devices that didn't report their status
+// within Window, such as devices that are offline or have DEX disabled, are
+// never returned, and a device that roamed to another data center since its
+// last report is still listed under the previous one.
+//
+// API reference: https://api.cloudflare.com/#devices-list-devices
+func (api *API) ListDevices(ctx context.Context, accountID string, params ListDevicesParams) ([]TeamsDeviceListItem, error) {
+	var colo map[string]bool
+	if params.Colo != "" {
+		var err error
+		colo, err = api.deviceIDsByColo(ctx, accountID, params.Colo, params.Window)
+		if err != nil {
+			return []TeamsDeviceListItem{}, err
+		}
+	}
+
+	devices, err := api.ListTeamsDevices(ctx, accountID)
+	if err != nil {
+		return []TeamsDeviceListItem{}, err
+	}
+
+	var matched []TeamsDeviceListItem
+	for _, device := range devices {
+		if device.Deleted {
+			continue
+		}
+		if colo != nil && !colo[device.ID] {
+			continue
+		}
+		if params.UserEmail != "" && !strings.EqualFold(device.User.Email, params.UserEmail) {
+			continue
+		}
+		matched = append(matched, device)
+	}
+
+	return matched, nil
+}
+
+// deviceIDsByColo returns the IDs of the devices whose most recent fleet
+// status within window was reported through colo.
+func (api *API) deviceIDsByColo(ctx context.Context, accountID, colo string, window time.Duration) (map[string]bool, error) {
+	if window <= 0 {
+		window = defaultDeviceLocationWindow
+	}
+
+	end := time.Now().UTC()
+	params := deviceFleetStatusParams{
+		TimeStart:  end.Add(-window).Format(time.RFC3339),
+		TimeEnd:    end.Format(time.RFC3339),
+		ResultInfo: ResultInfo{Page: 1, PerPage: 50},
+	}
+
+	latest := make(map[string]DeviceFleetStatus)
+	for {
+		uri := buildURI(fmt.Sprintf("/%s/%s/dex/fleet-status/devices", AccountRouteRoot, accountID), params)
+		res, err := api.makeRequestContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var r DeviceFleetStatusListResponse
+		if err := api.unmarshalDeviceResponse(res, &r); err != nil {
+			return nil, fmt.Errorf("%s: %w", errUnmarshalError, err)
+		}
+
+		for _, status := range r.Result {
+			// RFC 3339 timestamps in UTC order lexically.
+			if current, ok := latest[status.DeviceID]; !ok || status.Timestamp > current.Timestamp {
+				latest[status.DeviceID] = status
+			}
+		}
+
+		params.ResultInfo = r.ResultInfo.Next()
+		if params.ResultInfo.Done() {
+			break
+		}
+	}
+
+	ids := make(map[string]bool)
+	for id, status := range latest {
+		if strings.EqualFold(status.Colo, colo) {
+			ids[id] = true
+		}
+	}
+
+	return ids, nil
+}
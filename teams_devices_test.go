@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -188,3 +190,415 @@ func TestGetTeamsDeviceDetails(t *testing.T) {
 		assert.Equal(t, want, actual)
 	}
 }
+
+func TestDeviceOSDistribution(t *testing.T) {
+	setup()
+	defer teardown()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprintf(w, `
+        {
+          "success": true,
+          "errors": [],
+          "messages": [],
+          "result": [
+            {"id": "1", "device_type": "windows"},
+            {"id": "2", "device_type": "mac"},
+            {"id": "3", "device_type": "macOS"},
+            {"id": "4", "device_type": "android"},
+            {"id": "5", "device_type": "toaster"},
+            {"id": "6", "device_type": ""},
+            {"id": "7", "device_type": "windows", "deleted": true},
+            {"id": "8", "device_type": "windows", "revoked_at": "2023-01-01T00:00:00Z"}
+          ]
+        }
+    `)
+	}
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", handler)
+
+	want := map[DeviceType]int{
+		DeviceTypeWindows: 1,
+		DeviceTypeMac:     2,
+		DeviceTypeAndroid: 1,
+		DeviceTypeUnknown: 2,
+	}
+
+	actual, err := client.DeviceOSDistribution(context.Background(), testAccountID)
+	require.NoError(t, err)
+	assert.Equal(t, want, actual)
+}
+
+func TestListDevicesForPolicy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policies", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"policy_id": "alice", "enabled": true, "precedence": 10, "match": "identity.email == \"alice@example.com\""},
+				{"policy_id": "macs", "enabled": true, "precedence": 20, "match": "os.name == \"mac\""}
+			],
+			"result_info": {"page": 1, "per_page": 20, "count": 2, "total_count": 2, "total_pages": 1}
+		}`)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/policy", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": null, "messages": null, "result": {"default": true}}`)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": null,
+			"messages": null,
+			"result": [
+				{"id": "1", "device_type": "mac", "user": {"email": "alice@example.com"}},
+				{"id": "2", "device_type": "mac", "user": {"email": "bob@example.com"}},
+				{"id": "3", "device_type": "windows", "user": {"email": "bob@example.com"}},
+				{"id": "4", "device_type": "mac", "user": {"email": "carol@example.com"}, "deleted": true}
+			]
+		}`)
+	})
+
+	ids := func(devices []TeamsDeviceListItem) []string {
+		var ids []string
+		for _, d := range devices {
+			ids = append(ids, d.ID)
+		}
+		return ids
+	}
+
+	devices, err := client.ListDevicesForPolicy(context.Background(), testAccountID, "macs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, ids(devices))
+
+	devices, err = client.ListDevicesForPolicy(context.Background(), testAccountID, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, ids(devices))
+
+	_, err = client.ListDevicesForPolicy(context.Background(), testAccountID, "missing")
+	assert.ErrorContains(t, err, "not found")
+
+	counts, err := client.DeviceCountsByPolicy(context.Background(), testAccountID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"alice": 1, "macs": 1, "default": 1}, counts)
+}
+
+func TestListDevicesIncremental(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"id": "unchanged", "updated": "2023-01-01T00:00:00Z"},
+				{"id": "updated", "updated": "2023-03-01T00:00:00Z"},
+				{"id": "new", "updated": "2023-03-01T00:00:00Z"},
+				{"id": "deleted", "deleted": true, "updated": "2023-03-01T00:00:00Z"}
+			]
+		}`)
+	})
+
+	known := map[string]time.Time{
+		"unchanged": time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		"updated":   time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		"deleted":   time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+		"gone":      time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	delta, err := client.ListDevicesIncremental(context.Background(), testAccountID, known)
+	require.NoError(t, err)
+
+	var changed []string
+	for _, device := range delta.Changed {
+		changed = append(changed, device.ID)
+	}
+	assert.Equal(t, []string{"updated", "new"}, changed)
+	assert.Equal(t, []string{"deleted", "gone"}, delta.Removed)
+}
+
+func TestReconcileDevices(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"id": "1", "serial_number": "SN-EXPECTED"},
+				{"id": "2", "serial_number": "SN-RETIRED"},
+				{"id": "3", "serial_number": "SN-UNKNOWN"},
+				{"id": "by-id"},
+				{"id": "5", "serial_number": "SN-REVOKED", "revoked_at": "2023-01-01T00:00:00Z"}
+			]
+		}`)
+	})
+
+	reconciliation, err := client.ReconcileDevices(context.Background(), testAccountID, map[string]bool{
+		"SN-EXPECTED": true,
+		"SN-RETIRED":  false,
+		"by-id":       true,
+		"SN-REVOKED":  true,
+		"SN-MISSING":  true,
+	})
+	require.NoError(t, err)
+
+	var unexpected []string
+	for _, device := range reconciliation.Unexpected {
+		unexpected = append(unexpected, device.ID)
+	}
+	assert.Equal(t, []string{"2", "3"}, unexpected)
+	assert.Equal(t, []string{"SN-MISSING", "SN-REVOKED"}, reconciliation.Missing)
+}
+
+func TestGetDeviceUser(t *testing.T) {
+	setup()
+	defer teardown()
+
+	device := func(id, user string) {
+		mux.HandleFunc("/accounts/"+testAccountID+"/devices/"+id, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+			w.Header().Set("content-type", "application/json")
+			fmt.Fprintf(w, `{"success": true, "errors": [], "messages": [], "result": {"id": %q, "user": %s}}`, id, user)
+		})
+	}
+	device("enrolled", `{"id": "user-1", "name": "John Appleseed", "email": "john@example.com"}`)
+	device("unseen", `{"id": "user-2", "name": "Jane Doe", "email": "jane@example.com"}`)
+	device("headless", `{}`)
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/users/user-1/last_seen_identity", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"email": "john@example.com",
+				"idp": {"id": "okta-1", "type": "okta"},
+				"groups": [{"id": "g1", "name": "engineering", "email": "eng@example.com"}]
+			}
+		}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/access/users/user-2/last_seen_identity", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success": false, "errors": [{"code": 404, "message": "not found"}], "messages": [], "result": null}`)
+	})
+
+	user, err := client.GetDeviceUser(context.Background(), testAccountID, "enrolled")
+	require.NoError(t, err)
+	assert.Equal(t, &DeviceUser{
+		ID:     "user-1",
+		Name:   "John Appleseed",
+		Email:  "john@example.com",
+		IDP:    AccessUserIDP{ID: "okta-1", Type: "okta"},
+		Groups: []DeviceMatchGroup{{ID: "g1", Name: "engineering", Email: "eng@example.com"}},
+	}, user)
+
+	user, err = client.GetDeviceUser(context.Background(), testAccountID, "unseen")
+	require.NoError(t, err)
+	assert.Equal(t, &DeviceUser{ID: "user-2", Name: "Jane Doe", Email: "jane@example.com"}, user)
+
+	user, err = client.GetDeviceUser(context.Background(), testAccountID, "headless")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+func TestStrictDeviceDecoding(t *testing.T) {
+	setup(UsingStrictDeviceDecoding(true))
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/known", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result_info": {"count": 1}, "result": {"id": "known", "name": "laptop"}}`)
+	})
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/drifted", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": {"id": "drifted", "brand_new_field": true}}`)
+	})
+
+	device, err := client.GetTeamsDeviceDetails(context.Background(), testAccountID, "known")
+	require.NoError(t, err)
+	assert.Equal(t, "laptop", device.Name)
+
+	_, err = client.GetTeamsDeviceDetails(context.Background(), testAccountID, "drifted")
+	assert.ErrorContains(t, err, "brand_new_field")
+
+	lenient, err := New("deadbeef", "cloudflare@example.org", UsingRetryPolicy(0, 0, 0), BaseURL(server.URL))
+	require.NoError(t, err)
+	_, err = lenient.GetTeamsDeviceDetails(context.Background(), testAccountID, "drifted")
+	assert.NoError(t, err)
+}
+
+func TestRevokeDevices(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var batches [][]string
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/revoke", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		var ids []string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&ids))
+		batches = append(batches, ids)
+		w.Header().Set("content-type", "application/json")
+		if len(batches) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"success": false, "errors": [{"code": 10000, "message": "rate limited"}], "messages": []}`)
+			return
+		}
+		if len(ids) > 0 && ids[0] == "broken" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"success": false, "errors": [{"code": 1000, "message": "bad request"}], "messages": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": null}`)
+	})
+
+	result, err := client.RevokeDevices(context.Background(), testAccountID, RevokeDevicesParams{
+		DeviceIDs:     []string{"1", "2", "3", "4", "2", ""},
+		BatchSize:     4,
+		RateLimitWait: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"1", "2", "3", "4"}, {"1", "2"}, {"3", "4"}}, batches)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, result.Revoked)
+	assert.Empty(t, result.Remaining)
+	assert.Greater(t, result.Throughput, 0.0)
+
+	result, err = client.RevokeDevices(context.Background(), testAccountID, RevokeDevicesParams{
+		DeviceIDs: []string{"broken", "5"},
+	})
+	assert.Error(t, err)
+	assert.Empty(t, result.Revoked)
+	assert.Equal(t, []string{"broken", "5"}, result.Remaining)
+}
+
+func TestRevokeDevicesByUser(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"id": "laptop", "user": {"email": "Alice@example.com"}},
+				{"id": "phone", "user": {"email": "alice@example.com"}},
+				{"id": "old", "user": {"email": "alice@example.com"}, "revoked_at": "2023-01-01T00:00:00Z"},
+				{"id": "gone", "user": {"email": "alice@example.com"}, "deleted": true},
+				{"id": "other", "user": {"email": "bob@example.com"}}
+			]
+		}`)
+	})
+
+	var revoked []string
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices/revoke", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method, "Expected method 'POST', got %s", r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&revoked))
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"success": true, "errors": [], "messages": [], "result": null}`)
+	})
+
+	result, err := client.RevokeDevicesByUser(context.Background(), testAccountID, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Found)
+	assert.Equal(t, []string{"laptop", "phone"}, result.Revoked)
+	assert.Equal(t, []string{"laptop", "phone"}, revoked)
+	assert.Empty(t, result.Remaining)
+
+	result, err = client.RevokeDevicesByUser(context.Background(), testAccountID, "carol@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Found)
+	assert.Empty(t, result.Revoked)
+
+	_, err = client.RevokeDevicesByUser(context.Background(), testAccountID, "")
+	assert.ErrorIs(t, err, ErrMissingDeviceUserEmail)
+}
+
+func TestListDevicesByColo(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"id": "device-sjc"},
+				{"id": "device-roamed"},
+				{"id": "device-lhr"},
+				{"id": "device-offline"},
+				{"id": "device-deleted", "deleted": true}
+			]
+		}`)
+	})
+
+	mux.HandleFunc("/accounts/"+testAccountID+"/dex/fleet-status/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "Expected method 'GET', got %s", r.Method)
+		assert.NotEmpty(t, r.URL.Query().Get("time_start"))
+		assert.NotEmpty(t, r.URL.Query().Get("time_end"))
+		w.Header().Set("content-type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{"deviceId": "device-roamed", "colo": "LAX", "timestamp": "2023-06-01T12:10:00Z"},
+					{"deviceId": "device-deleted", "colo": "SJC", "timestamp": "2023-06-01T12:10:00Z"}
+				],
+				"result_info": {"page": 2, "per_page": 3, "count": 2, "total_count": 5}
+			}`)
+			return
+		}
+		fmt.Fprint(w, `{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{"deviceId": "device-sjc", "colo": "SJC", "timestamp": "2023-06-01T12:00:00Z"},
+				{"deviceId": "device-roamed", "colo": "SJC", "timestamp": "2023-06-01T12:00:00Z"},
+				{"deviceId": "device-lhr", "colo": "LHR", "timestamp": "2023-06-01T12:00:00Z"}
+			],
+			"result_info": {"page": 1, "per_page": 3, "count": 3, "total_count": 5}
+		}`)
+	})
+
+	devices, err := client.ListDevices(context.Background(), testAccountID, ListDevicesParams{Colo: "sjc"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []TeamsDeviceListItem{{ID: "device-sjc"}}, devices)
+	}
+
+	devices, err = client.ListDevices(context.Background(), testAccountID, ListDevicesParams{})
+	if assert.NoError(t, err) {
+		assert.Len(t, devices, 4)
+	}
+}